@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseToken(t *testing.T) {
+	secret := []byte("secret")
+	token, err := SignToken(Claims{"sub": "alice", "tenant_id": "acme"}, secret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	claims, err := ParseToken(token, secret)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if claims.Subject() != "alice" {
+		t.Errorf("Expected subject alice, got %s", claims.Subject())
+	}
+	if claims.String("tenant_id") != "acme" {
+		t.Errorf("Expected tenant_id acme, got %s", claims.String("tenant_id"))
+	}
+}
+
+func TestParseTokenWrongSecret(t *testing.T) {
+	token, _ := SignToken(Claims{"sub": "alice"}, []byte("secret"))
+
+	if _, err := ParseToken(token, []byte("other")); err == nil {
+		t.Error("Expected error for token signed with a different secret")
+	}
+}
+
+func TestParseTokenExpired(t *testing.T) {
+	secret := []byte("secret")
+	token, _ := SignToken(Claims{"sub": "alice", "exp": float64(time.Now().Add(-time.Minute).Unix())}, secret)
+
+	if _, err := ParseToken(token, secret); err == nil {
+		t.Error("Expected error for expired token")
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	if !(Claims{"role": "admin"}).HasRole("admin") {
+		t.Error("Expected role claim to grant admin")
+	}
+	if !(Claims{"roles": []interface{}{"editor", "admin"}}).HasRole("admin") {
+		t.Error("Expected roles claim to grant admin")
+	}
+	if (Claims{"sub": "alice", "roles": "admin"}).HasRole("admin") {
+		t.Error("Expected a string roles claim not to grant admin")
+	}
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(2)
+	c.Set("a", []byte("1"), time.Minute)
+	c.Set("b", []byte("2"), time.Minute)
+	c.Get("a")
+	c.Set("c", []byte("3"), time.Minute)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+	if c.Len() != 2 || c.Evictions() != 1 {
+		t.Errorf("Expected 2 entries and 1 eviction, got %d and %d", c.Len(), c.Evictions())
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	now := time.Now()
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+	c.Set("a", []byte("1"), time.Minute)
+
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a to have expired")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Expected the expired entry to be removed, got %d entries", c.Len())
+	}
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// errLoadPanicked is what waiters see when the load they waited for panicked.
+var errLoadPanicked = errors.New("cache: load panicked")
+
+type flight struct {
+	wg    sync.WaitGroup
+	value []byte
+	err   error
+}
+
+// group collapses concurrent loads of the same key into one.
+type group struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// do runs load once for all concurrent callers with key, reporting whether
+// the result was shared with an earlier caller.
+func (g *group) do(key string, load func() ([]byte, error)) ([]byte, error, bool) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = map[string]*flight{}
+	}
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		f.wg.Wait()
+		return f.value, f.err, true
+	}
+	f := &flight{}
+	f.wg.Add(1)
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+		f.wg.Done()
+	}()
+	f.err = errLoadPanicked
+	f.value, f.err = load()
+	return f.value, f.err, false
+}
@@ -0,0 +1,354 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"test-server/handlers"
+	"test-server/models"
+	"test-server/outbox"
+	"test-server/reminders"
+	"test-server/tenant"
+)
+
+// Stats counts cache traffic. Collapsed misses waited for another caller's
+// load instead of querying themselves.
+type Stats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Collapsed     uint64 `json:"collapsed"`
+	Invalidations uint64 `json:"invalidations"`
+	Entries       int    `json:"entries"`
+	Evictions     uint64 `json:"evictions"`
+}
+
+// sizedBackend is implemented by backends that can report their size.
+type sizedBackend interface {
+	Len() int
+	Evictions() uint64
+}
+
+// TodoRepository caches GetByID, GetAll and GetAllVersion in front of another
+// handlers.TodoRepository. Entries are keyed by a per-tenant generation, so
+// a write invalidates everything cached for its tenant by moving to a new
+// generation; the old entries are never read again and age out.
+type TodoRepository struct {
+	handlers.TodoRepository
+	backend Backend
+	ttl     time.Duration
+	flights group
+
+	// epoch prefixes generation keys, so that changing it invalidates every
+	// tenant at once.
+	epochMu sync.RWMutex
+	epoch   string
+
+	hits, misses, collapsed, invalidations atomic.Uint64
+}
+
+func NewTodoRepository(next handlers.TodoRepository, backend Backend, ttl time.Duration) *TodoRepository {
+	c := &TodoRepository{TodoRepository: next, backend: backend, ttl: ttl}
+	c.epoch = newToken()
+	return c
+}
+
+func newToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (c *TodoRepository) generationKey(tenantID string) string {
+	c.epochMu.RLock()
+	defer c.epochMu.RUnlock()
+	return "gen|" + c.epoch + "|" + tenantID
+}
+
+// generation returns the tenant's current generation, starting a new one
+// if the backend lost it.
+func (c *TodoRepository) generation(tenantID string) string {
+	key := c.generationKey(tenantID)
+	if gen, ok := c.backend.Get(key); ok {
+		return string(gen)
+	}
+	gen := newToken()
+	// Generations outlive the entries that use them.
+	c.backend.Set(key, []byte(gen), 2*c.ttl)
+	return gen
+}
+
+// Invalidate drops everything cached for tenantID.
+func (c *TodoRepository) Invalidate(tenantID string) {
+	c.invalidations.Add(1)
+	c.backend.Set(c.generationKey(tenantID), []byte(newToken()), 2*c.ttl)
+}
+
+// InvalidateAll drops everything cached for every tenant.
+func (c *TodoRepository) InvalidateAll() {
+	c.invalidations.Add(1)
+	c.epochMu.Lock()
+	c.epoch = newToken()
+	c.epochMu.Unlock()
+}
+
+// Watch invalidates tenants as their todo events arrive, covering changes
+// made other than through this repository, until ctx is done.
+func (c *TodoRepository) Watch(ctx context.Context, source handlers.EventSource) {
+	for {
+		sub := source.Subscribe(1024)
+		if !c.follow(ctx, sub) {
+			sub.Close()
+			return
+		}
+		log.Printf("cache: fell behind the event bus, invalidating everything")
+		c.InvalidateAll()
+	}
+}
+
+// follow invalidates on each event until the subscription is dropped,
+// returning false once ctx is done.
+func (c *TodoRepository) follow(ctx context.Context, sub *outbox.Subscription) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case event, ok := <-sub.C:
+			if !ok {
+				return true
+			}
+			c.Invalidate(event.TenantID)
+		}
+	}
+}
+
+func (c *TodoRepository) Stats() Stats {
+	stats := Stats{
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Collapsed:     c.collapsed.Load(),
+		Invalidations: c.invalidations.Load(),
+	}
+	if sized, ok := c.backend.(sizedBackend); ok {
+		stats.Entries = sized.Len()
+		stats.Evictions = sized.Evictions()
+	}
+	return stats
+}
+
+// load returns the cached value for key in the caller's tenant, or loads,
+// encodes and stores it.
+func (c *TodoRepository) load(ctx context.Context, key string, into interface{}, fetch func(context.Context) (interface{}, error)) error {
+	tenantID := tenant.IDFromContext(ctx)
+	key = tenantID + "|" + c.generation(tenantID) + "|" + key
+
+	if data, ok := c.backend.Get(key); ok {
+		c.hits.Add(1)
+		return json.Unmarshal(data, into)
+	}
+	c.misses.Add(1)
+
+	data, err, shared := c.flights.do(key, func() ([]byte, error) {
+		// The load is shared, so one caller giving up must not fail the
+		// others.
+		value, err := fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode cache entry: %w", err)
+		}
+		c.backend.Set(key, data, c.ttl)
+		return data, nil
+	})
+	if shared {
+		c.collapsed.Add(1)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, into)
+}
+
+func (c *TodoRepository) GetByID(ctx context.Context, id int) (*models.Todo, error) {
+	var todo models.Todo
+	err := c.load(ctx, "todo|"+strconv.Itoa(id), &todo, func(ctx context.Context) (interface{}, error) {
+		return c.TodoRepository.GetByID(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func (c *TodoRepository) GetAll(ctx context.Context, filter models.TodoFilter) ([]models.Todo, error) {
+	// Whether a todo is overdue changes with the clock, not with writes.
+	if filter.Overdue {
+		return c.TodoRepository.GetAll(ctx, filter)
+	}
+	key, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	var todos []models.Todo
+	err = c.load(ctx, "todos|"+string(key), &todos, func(ctx context.Context) (interface{}, error) {
+		return c.TodoRepository.GetAll(ctx, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// GetAllVersion is cached alongside GetAll, under the same generation, so
+// that a list's ETag always describes the body it is served with.
+func (c *TodoRepository) GetAllVersion(ctx context.Context, filter models.TodoFilter) (*models.TodoListVersion, error) {
+	if filter.Overdue {
+		return c.TodoRepository.GetAllVersion(ctx, filter)
+	}
+	key, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	var version *models.TodoListVersion
+	err = c.load(ctx, "version|"+string(key), &version, func(ctx context.Context) (interface{}, error) {
+		return c.TodoRepository.GetAllVersion(ctx, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+func (c *TodoRepository) Create(ctx context.Context, req *models.CreateTodoRequest) (*models.Todo, error) {
+	defer c.Invalidate(tenant.IDFromContext(ctx))
+	return c.TodoRepository.Create(ctx, req)
+}
+
+func (c *TodoRepository) Update(ctx context.Context, id int, req *models.UpdateTodoRequest) (*models.Todo, error) {
+	defer c.Invalidate(tenant.IDFromContext(ctx))
+	return c.TodoRepository.Update(ctx, id, req)
+}
+
+func (c *TodoRepository) Delete(ctx context.Context, id int) error {
+	defer c.Invalidate(tenant.IDFromContext(ctx))
+	return c.TodoRepository.Delete(ctx, id)
+}
+
+func (c *TodoRepository) Move(ctx context.Context, id int, req *models.MoveTodoRequest) (*models.Todo, error) {
+	defer c.Invalidate(tenant.IDFromContext(ctx))
+	return c.TodoRepository.Move(ctx, id, req)
+}
+
+// Comments wraps next so that comment writes, which change a todo's
+// comment_count without emitting an event, invalidate the tenant.
+func (c *TodoRepository) Comments(next handlers.CommentRepository) handlers.CommentRepository {
+	return &commentRepository{CommentRepository: next, cache: c}
+}
+
+type commentRepository struct {
+	handlers.CommentRepository
+	cache *TodoRepository
+}
+
+func (r *commentRepository) CreateComment(ctx context.Context, todoID int, author string, req *models.CreateCommentRequest) (*models.Comment, error) {
+	defer r.cache.Invalidate(tenant.IDFromContext(ctx))
+	return r.CommentRepository.CreateComment(ctx, todoID, author, req)
+}
+
+func (r *commentRepository) UpdateComment(ctx context.Context, todoID, id int, author string, req *models.UpdateCommentRequest) (*models.Comment, error) {
+	defer r.cache.Invalidate(tenant.IDFromContext(ctx))
+	return r.CommentRepository.UpdateComment(ctx, todoID, id, author, req)
+}
+
+func (r *commentRepository) DeleteComment(ctx context.Context, todoID, id int, author string) error {
+	defer r.cache.Invalidate(tenant.IDFromContext(ctx))
+	return r.CommentRepository.DeleteComment(ctx, todoID, id, author)
+}
+
+// Lists wraps next so that list writes, which can move or remove the
+// list's todos, invalidate the tenant.
+func (c *TodoRepository) Lists(next handlers.ListRepository) handlers.ListRepository {
+	return &listRepository{ListRepository: next, cache: c}
+}
+
+type listRepository struct {
+	handlers.ListRepository
+	cache *TodoRepository
+}
+
+func (r *listRepository) UpdateList(ctx context.Context, id int, req *models.UpdateListRequest) (*models.List, error) {
+	defer r.cache.Invalidate(tenant.IDFromContext(ctx))
+	return r.ListRepository.UpdateList(ctx, id, req)
+}
+
+func (r *listRepository) DeleteList(ctx context.Context, id int, mode string) error {
+	defer r.cache.Invalidate(tenant.IDFromContext(ctx))
+	return r.ListRepository.DeleteList(ctx, id, mode)
+}
+
+// Revisions wraps next so that reverting a todo invalidates the tenant.
+func (c *TodoRepository) Revisions(next handlers.RevisionRepository) handlers.RevisionRepository {
+	return &revisionRepository{RevisionRepository: next, cache: c}
+}
+
+type revisionRepository struct {
+	handlers.RevisionRepository
+	cache *TodoRepository
+}
+
+func (r *revisionRepository) Revert(ctx context.Context, id, revision int) (*models.Todo, error) {
+	defer r.cache.Invalidate(tenant.IDFromContext(ctx))
+	return r.RevisionRepository.Revert(ctx, id, revision)
+}
+
+// Imports wraps next so that an import invalidates the tenant once its
+// todos are in.
+func (c *TodoRepository) Imports(next handlers.ImportRepository) handlers.ImportRepository {
+	return &importRepository{ImportRepository: next, cache: c}
+}
+
+type importRepository struct {
+	handlers.ImportRepository
+	cache *TodoRepository
+}
+
+func (r *importRepository) ImportTodos(ctx context.Context, todos []*models.CreateTodoRequest, batchSize int, progress func(int)) error {
+	defer r.cache.Invalidate(tenant.IDFromContext(ctx))
+	return r.ImportRepository.ImportTodos(ctx, todos, batchSize, progress)
+}
+
+// Reminders wraps next so that claiming a reminder, which sets
+// reminder_sent_at without emitting an event, invalidates the tenant. The
+// scheduler claims each reminder under its todo's tenant.
+func (c *TodoRepository) Reminders(next reminders.Store) reminders.Store {
+	return &reminderStore{Store: next, cache: c}
+}
+
+type reminderStore struct {
+	reminders.Store
+	cache *TodoRepository
+}
+
+func (r *reminderStore) ClaimReminder(ctx context.Context, id int, now time.Time) (bool, error) {
+	claimed, err := r.Store.ClaimReminder(ctx, id, now)
+	if claimed {
+		r.cache.Invalidate(tenant.IDFromContext(ctx))
+	}
+	return claimed, err
+}
+
+// ServeStats responds with the cache's Stats.
+func (c *TodoRepository) ServeStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.Stats())
+}
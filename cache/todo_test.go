@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"test-server/handlers"
+	"test-server/models"
+	"test-server/outbox"
+	"test-server/reminders"
+	"test-server/tenant"
+)
+
+// fakeRepository counts reads; the embedded interface panics on anything
+// the tests do not expect.
+type fakeRepository struct {
+	handlers.TodoRepository
+	reads   atomic.Int32
+	release chan struct{}
+}
+
+func (f *fakeRepository) GetByID(ctx context.Context, id int) (*models.Todo, error) {
+	f.reads.Add(1)
+	if f.release != nil {
+		<-f.release
+	}
+	return &models.Todo{ID: id, Title: tenant.IDFromContext(ctx)}, nil
+}
+
+func (f *fakeRepository) GetAll(ctx context.Context, filter models.TodoFilter) ([]models.Todo, error) {
+	f.reads.Add(1)
+	return []models.Todo{{ID: 1, Status: filter.Status}}, nil
+}
+
+func (f *fakeRepository) GetAllVersion(ctx context.Context, filter models.TodoFilter) (*models.TodoListVersion, error) {
+	f.reads.Add(1)
+	return &models.TodoListVersion{Count: int(f.reads.Load())}, nil
+}
+
+func (f *fakeRepository) Update(ctx context.Context, id int, req *models.UpdateTodoRequest) (*models.Todo, error) {
+	return &models.Todo{ID: id}, nil
+}
+
+func TestCacheServesRepeatedReads(t *testing.T) {
+	repo := &fakeRepository{}
+	c := NewTodoRepository(repo, NewLRU(100), time.Minute)
+	ctx := context.Background()
+
+	for range 3 {
+		todo, err := c.GetByID(ctx, 1)
+		if err != nil || todo.ID != 1 {
+			t.Fatalf("Unexpected result %+v, %v", todo, err)
+		}
+	}
+	c.GetAll(ctx, models.TodoFilter{Status: models.StatusDone})
+	todos, _ := c.GetAll(ctx, models.TodoFilter{Status: models.StatusDone})
+	c.GetAll(ctx, models.TodoFilter{Status: models.StatusTodo})
+
+	if repo.reads.Load() != 3 {
+		t.Errorf("Expected 3 repository reads, got %d", repo.reads.Load())
+	}
+	if len(todos) != 1 || todos[0].Status != models.StatusDone {
+		t.Errorf("Unexpected cached list %+v", todos)
+	}
+	if stats := c.Stats(); stats.Hits != 3 || stats.Misses != 3 {
+		t.Errorf("Expected 3 hits and 3 misses, got %+v", stats)
+	}
+}
+
+func TestCacheIsPerTenant(t *testing.T) {
+	repo := &fakeRepository{}
+	c := NewTodoRepository(repo, NewLRU(100), time.Minute)
+
+	acme, _ := c.GetByID(tenant.WithID(context.Background(), "acme"), 1)
+	globex, _ := c.GetByID(tenant.WithID(context.Background(), "globex"), 1)
+
+	if acme.Title != "acme" || globex.Title != "globex" {
+		t.Errorf("Expected each tenant to get its own todo, got %q and %q", acme.Title, globex.Title)
+	}
+}
+
+func TestCacheInvalidatesOnWrite(t *testing.T) {
+	repo := &fakeRepository{}
+	c := NewTodoRepository(repo, NewLRU(100), time.Minute)
+	acme := tenant.WithID(context.Background(), "acme")
+	globex := tenant.WithID(context.Background(), "globex")
+
+	c.GetByID(acme, 1)
+	c.GetByID(globex, 1)
+	c.Update(acme, 1, &models.UpdateTodoRequest{})
+	c.GetByID(acme, 1)
+	c.GetByID(globex, 1)
+
+	if repo.reads.Load() != 3 {
+		t.Errorf("Expected only the written tenant to be reloaded, got %d reads", repo.reads.Load())
+	}
+}
+
+func TestCacheCollapsesConcurrentMisses(t *testing.T) {
+	repo := &fakeRepository{release: make(chan struct{})}
+	c := NewTodoRepository(repo, NewLRU(100), time.Minute)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.GetByID(context.Background(), 1)
+		}()
+	}
+	// Let the callers pile up behind the first load.
+	for c.misses.Load() < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+
+	if repo.reads.Load() != 1 {
+		t.Errorf("Expected one repository read, got %d", repo.reads.Load())
+	}
+	if stats := c.Stats(); stats.Collapsed != 4 {
+		t.Errorf("Expected 4 collapsed misses, got %+v", stats)
+	}
+}
+
+func TestWatchInvalidatesOnEvents(t *testing.T) {
+	repo := &fakeRepository{}
+	c := NewTodoRepository(repo, NewLRU(100), time.Minute)
+	bus := outbox.NewBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Watch(ctx, bus)
+
+	acme := tenant.WithID(context.Background(), "acme")
+	c.GetByID(acme, 1)
+
+	// Wait for Watch to subscribe, then publish until it has seen an event.
+	for c.Stats().Invalidations == 0 {
+		bus.Publish(context.Background(), models.TodoEvent{TenantID: "acme"})
+		time.Sleep(time.Millisecond)
+	}
+	c.GetByID(acme, 1)
+
+	if repo.reads.Load() != 2 {
+		t.Errorf("Expected the event to invalidate the tenant, got %d reads", repo.reads.Load())
+	}
+}
+
+type fakeCommentRepository struct{ handlers.CommentRepository }
+
+func (fakeCommentRepository) DeleteComment(ctx context.Context, todoID, id int, author string) error {
+	return nil
+}
+
+type fakeReminderStore struct{ reminders.Store }
+
+func (fakeReminderStore) ClaimReminder(ctx context.Context, id int, now time.Time) (bool, error) {
+	return id == 1, nil
+}
+
+func TestCommentAndReminderWritesInvalidate(t *testing.T) {
+	repo := &fakeRepository{}
+	c := NewTodoRepository(repo, NewLRU(100), time.Minute)
+	acme := tenant.WithID(context.Background(), "acme")
+
+	c.GetByID(acme, 1)
+	c.Comments(fakeCommentRepository{}).DeleteComment(acme, 1, 1, "alice")
+	c.GetByID(acme, 1)
+	c.Reminders(fakeReminderStore{}).ClaimReminder(acme, 2, time.Now())
+	c.GetByID(acme, 1)
+	c.Reminders(fakeReminderStore{}).ClaimReminder(acme, 1, time.Now())
+	c.GetByID(acme, 1)
+
+	if repo.reads.Load() != 3 {
+		t.Errorf("Expected a reload after the comment and the claimed reminder only, got %d reads", repo.reads.Load())
+	}
+}
+
+type fakeListRepository struct{ handlers.ListRepository }
+
+func (fakeListRepository) DeleteList(ctx context.Context, id int, mode string) error {
+	return nil
+}
+
+type fakeRevisionRepository struct{ handlers.RevisionRepository }
+
+func (fakeRevisionRepository) Revert(ctx context.Context, id, revision int) (*models.Todo, error) {
+	return &models.Todo{ID: id}, nil
+}
+
+type fakeImportRepository struct{ handlers.ImportRepository }
+
+func (fakeImportRepository) ImportTodos(ctx context.Context, todos []*models.CreateTodoRequest, batchSize int, progress func(int)) error {
+	return nil
+}
+
+func TestListRevertAndImportWritesInvalidate(t *testing.T) {
+	repo := &fakeRepository{}
+	c := NewTodoRepository(repo, NewLRU(100), time.Minute)
+	acme := tenant.WithID(context.Background(), "acme")
+
+	c.GetByID(acme, 1)
+	c.Lists(fakeListRepository{}).DeleteList(acme, 1, "move")
+	c.GetByID(acme, 1)
+	c.Revisions(fakeRevisionRepository{}).Revert(acme, 1, 1)
+	c.GetByID(acme, 1)
+	c.Imports(fakeImportRepository{}).ImportTodos(acme, nil, 100, nil)
+	c.GetByID(acme, 1)
+
+	if repo.reads.Load() != 4 {
+		t.Errorf("Expected a reload after every write, got %d reads", repo.reads.Load())
+	}
+}
+
+func TestCacheServesVersionWithTheList(t *testing.T) {
+	repo := &fakeRepository{}
+	c := NewTodoRepository(repo, NewLRU(100), time.Minute)
+	acme := tenant.WithID(context.Background(), "acme")
+
+	first, _ := c.GetAllVersion(acme, models.TodoFilter{})
+	again, _ := c.GetAllVersion(acme, models.TodoFilter{})
+	if first.Count != again.Count {
+		t.Errorf("Expected the cached version, got %d then %d", first.Count, again.Count)
+	}
+
+	c.Invalidate("acme")
+	fresh, _ := c.GetAllVersion(acme, models.TodoFilter{})
+	if fresh.Count == first.Count {
+		t.Error("Expected the version to be reloaded after invalidation")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"test-server/middleware"
+	"test-server/routes"
+	"test-server/server"
+)
+
+func loadRouterConfig() (routes.Config, error) {
+	cfg := routes.DefaultConfig()
+
+	cfg.JWTSecret = []byte(getEnv("JWT_SECRET", ""))
+
+	cfg.Tenant = middleware.TenantConfig{
+		Header:     getEnv("TENANT_HEADER", "X-Tenant-ID"),
+		BaseDomain: getEnv("TENANT_BASE_DOMAIN", ""),
+		Claim:      getEnv("TENANT_CLAIM", "tenant_id"),
+		Required:   getEnvBool("TENANT_REQUIRED", false),
+	}
+
+	defaultLimit, routeLimits, err := middleware.ParseRateLimits(getEnv("RATE_LIMITS", ""))
+	if err != nil {
+		return cfg, fmt.Errorf("failed to parse rate limits: %w", err)
+	}
+	cfg.RateLimit = middleware.RateLimitConfig{
+		Default:      defaultLimit,
+		Routes:       routeLimits,
+		KeyBy:        getEnv("RATE_LIMIT_KEY", "ip"),
+		APIKeyHeader: getEnv("RATE_LIMIT_API_KEY_HEADER", "X-API-Key"),
+		APIKeys:      getEnvList("API_KEYS", nil),
+		TrustProxy:   getEnvBool("RATE_LIMIT_TRUST_PROXY", false),
+	}
+
+	cfg.Compression = middleware.CompressionConfig{
+		Disabled: !getEnvBool("COMPRESSION", true),
+		Level:    getEnvInt("COMPRESSION_LEVEL", 0),
+		MinSize:  getEnvInt("COMPRESSION_MIN_SIZE", 1024),
+	}
+
+	cfg.Idempotency = middleware.IdempotencyConfig{
+		TTL:          getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		APIKeyHeader: cfg.RateLimit.APIKeyHeader,
+		APIKeys:      cfg.RateLimit.APIKeys,
+		TrustProxy:   cfg.RateLimit.TrustProxy,
+	}
+
+	cfg.CORS.AllowedOrigins = getEnvList("CORS_ALLOWED_ORIGINS", nil)
+	cfg.CORS.AllowedMethods = getEnvList("CORS_ALLOWED_METHODS", cfg.CORS.AllowedMethods)
+	cfg.CORS.AllowedHeaders = getEnvList("CORS_ALLOWED_HEADERS", cfg.CORS.AllowedHeaders)
+	cfg.CORS.AllowCredentials = getEnvBool("CORS_ALLOW_CREDENTIALS", false)
+	cfg.CORS.MaxAge = getEnvDuration("CORS_MAX_AGE", cfg.CORS.MaxAge)
+	if err := cfg.CORS.Validate(); err != nil {
+		return cfg, err
+	}
+
+	cfg.Security.HSTSMaxAge = getEnvDuration("HSTS_MAX_AGE", 0)
+	cfg.Security.HSTSIncludeSubdomains = getEnvBool("HSTS_INCLUDE_SUBDOMAINS", false)
+	cfg.Security.TrustProxy = getEnvBool("TRUST_PROXY_HEADERS", false)
+
+	return cfg, nil
+}
+
+func loadServerConfig() server.Config {
+	return server.Config{
+		Addr:           ":" + getEnv("PORT", "8080"),
+		CertFile:       getEnv("TLS_CERT_FILE", ""),
+		KeyFile:        getEnv("TLS_KEY_FILE", ""),
+		ReloadInterval: getEnvDuration("TLS_RELOAD_INTERVAL", time.Minute),
+		MinVersion:     getEnv("TLS_MIN_VERSION", "1.2"),
+		CipherSuites:   getEnvList("TLS_CIPHER_SUITES", nil),
+		ClientCAFile:   getEnv("TLS_CLIENT_CA_FILE", ""),
+		ClientAuth:     getEnv("TLS_CLIENT_AUTH", "require"),
+		H2C:            getEnvBool("H2C", false),
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvDuration accepts Go durations ("90s", "1h") or plain seconds.
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultValue
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -41,15 +41,88 @@ func InitDB(config Config) error {
 	return nil
 }
 
+// CreateTables creates or upgrades every table the application uses.
+func CreateTables() error {
+	for _, create := range []func() error{CreateListTable, CreateTodoTable, CreateTagTables, CreateCommentTable, CreateAuditTable, CreateRevisionTable, CreateWebhookTables, CreateOutboxTable} {
+		if err := create(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// todoUpgrades bring todos tables created by earlier versions up to the
+// current schema. Entries are applied in order and skipped when present.
+var todoUpgrades = []struct {
+	column     string
+	definition string
+	// backfill runs once, right after the column is added.
+	backfill string
+}{
+	// Existing rows land in the default tenant.
+	{"tenant_id", "VARCHAR(64) NOT NULL DEFAULT 'default' AFTER id", ""},
+	{"due_at", "DATETIME NULL", ""},
+	{"remind_at", "DATETIME NULL", ""},
+	{"reminder_sent_at", "DATETIME NULL", ""},
+	{"status", "VARCHAR(20) NOT NULL DEFAULT 'todo'", "UPDATE todos SET status = 'done' WHERE completed = TRUE"},
+	{"priority", "VARCHAR(10) NOT NULL DEFAULT 'medium'", ""},
+	{"completed_at", "DATETIME NULL", "UPDATE todos SET completed_at = updated_at WHERE completed = TRUE"},
+	// NULL keeps existing todos in the inbox.
+	{"list_id", "INT NULL", ""},
+	{"parent_id", "INT NULL", ""},
+	// Positions sort bytewise, hence the binary collation. The backfill keeps
+	// the newest-first order with fixed-width base-36 keys ending in a
+	// non-zero digit.
+	{"position", "VARCHAR(64) CHARACTER SET ascii COLLATE ascii_bin NOT NULL DEFAULT ''",
+		"UPDATE todos SET position = CONCAT(LPAD(CONV(4294967295 - id, 10, 36), 7, '0'), 'V')"},
+	{"recurrence_rule", "VARCHAR(255) NULL", ""},
+	{"recurrence_tz", "VARCHAR(64) NULL", ""},
+	{"occurrence", "INT NOT NULL DEFAULT 1", ""},
+}
+
+var todoIndexes = []struct {
+	name       string
+	definition string
+}{
+	{"idx_todos_tenant", "INDEX idx_todos_tenant (tenant_id, created_at)"},
+	{"ft_todos_title_description", "FULLTEXT INDEX ft_todos_title_description (title, description)"},
+	{"idx_todos_remind_at", "INDEX idx_todos_remind_at (reminder_sent_at, remind_at)"},
+	{"idx_todos_status", "INDEX idx_todos_status (tenant_id, status)"},
+	{"idx_todos_list", "INDEX idx_todos_list (tenant_id, list_id)"},
+	{"idx_todos_position", "INDEX idx_todos_position (tenant_id, position)"},
+	// Deleting a todo deletes its subtasks.
+	{"fk_todos_parent", "CONSTRAINT fk_todos_parent FOREIGN KEY (parent_id) REFERENCES todos(id) ON DELETE CASCADE"},
+}
+
 func CreateTodoTable() error {
 	query := `
 	CREATE TABLE IF NOT EXISTS todos (
 		id INT AUTO_INCREMENT PRIMARY KEY,
+		tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
 		title VARCHAR(255) NOT NULL,
 		description TEXT,
 		completed BOOLEAN DEFAULT FALSE,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		due_at DATETIME NULL,
+		remind_at DATETIME NULL,
+		reminder_sent_at DATETIME NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'todo',
+		priority VARCHAR(10) NOT NULL DEFAULT 'medium',
+		completed_at DATETIME NULL,
+		list_id INT NULL,
+		parent_id INT NULL,
+		position VARCHAR(64) CHARACTER SET ascii COLLATE ascii_bin NOT NULL DEFAULT '',
+		recurrence_rule VARCHAR(255) NULL,
+		recurrence_tz VARCHAR(64) NULL,
+		occurrence INT NOT NULL DEFAULT 1,
+		INDEX idx_todos_tenant (tenant_id, created_at),
+		FULLTEXT INDEX ft_todos_title_description (title, description),
+		INDEX idx_todos_remind_at (reminder_sent_at, remind_at),
+		INDEX idx_todos_status (tenant_id, status),
+		INDEX idx_todos_list (tenant_id, list_id),
+		INDEX idx_todos_position (tenant_id, position),
+		CONSTRAINT fk_todos_parent FOREIGN KEY (parent_id) REFERENCES todos(id) ON DELETE CASCADE
 	)`
 
 	_, err := DB.Exec(query)
@@ -57,10 +130,267 @@ func CreateTodoTable() error {
 		return fmt.Errorf("failed to create todos table: %w", err)
 	}
 
+	for _, upgrade := range todoUpgrades {
+		added, err := addColumnIfMissing("todos", upgrade.column, upgrade.definition)
+		if err != nil {
+			return err
+		}
+		if added && upgrade.backfill != "" {
+			if _, err := DB.Exec(upgrade.backfill); err != nil {
+				return fmt.Errorf("failed to backfill todos.%s: %w", upgrade.column, err)
+			}
+		}
+	}
+	for _, index := range todoIndexes {
+		if err := addIndexIfMissing("todos", index.name, index.definition); err != nil {
+			return err
+		}
+	}
+
 	log.Println("Todos table created or already exists")
 	return nil
 }
 
+func CreateListTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS lists (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		tenant_id VARCHAR(64) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		UNIQUE KEY uq_lists_tenant_name (tenant_id, name)
+	)`
+
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create lists table: %w", err)
+	}
+
+	log.Println("Lists table created or already exists")
+	return nil
+}
+
+func CreateTagTables() error {
+	queries := []string{`
+	CREATE TABLE IF NOT EXISTS tags (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		tenant_id VARCHAR(64) NOT NULL,
+		name VARCHAR(50) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE KEY uq_tags_tenant_name (tenant_id, name)
+	)`, `
+	CREATE TABLE IF NOT EXISTS todo_tags (
+		todo_id INT NOT NULL,
+		tag_id INT NOT NULL,
+		PRIMARY KEY (todo_id, tag_id),
+		INDEX idx_todo_tags_tag (tag_id),
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	)`}
+
+	for _, query := range queries {
+		if _, err := DB.Exec(query); err != nil {
+			return fmt.Errorf("failed to create tag tables: %w", err)
+		}
+	}
+
+	log.Println("Tag tables created or already exist")
+	return nil
+}
+
+func CreateCommentTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS comments (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		tenant_id VARCHAR(64) NOT NULL,
+		todo_id INT NOT NULL,
+		author VARCHAR(255) NOT NULL,
+		body TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_comments_todo (todo_id, created_at),
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+	)`
+
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create comments table: %w", err)
+	}
+
+	log.Println("Comments table created or already exists")
+	return nil
+}
+
+// CreateAuditTable creates todo_audit. Entries keep the todo ID without a
+// foreign key so that they outlive the todo.
+func CreateAuditTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS todo_audit (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		tenant_id VARCHAR(64) NOT NULL,
+		todo_id INT NOT NULL,
+		actor VARCHAR(255) NOT NULL,
+		action VARCHAR(10) NOT NULL,
+		changes JSON NOT NULL,
+		request_id VARCHAR(128) NOT NULL DEFAULT '',
+		created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
+		INDEX idx_todo_audit_todo (tenant_id, todo_id, id),
+		INDEX idx_todo_audit_created (tenant_id, created_at)
+	)`
+
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create audit table: %w", err)
+	}
+
+	log.Println("Audit table created or already exists")
+	return nil
+}
+
+func CreateRevisionTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS todo_revisions (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		tenant_id VARCHAR(64) NOT NULL,
+		todo_id INT NOT NULL,
+		revision INT NOT NULL,
+		actor VARCHAR(255) NOT NULL,
+		snapshot JSON NOT NULL,
+		created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
+		UNIQUE KEY uq_todo_revisions (todo_id, revision),
+		FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+	)`
+
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create revisions table: %w", err)
+	}
+
+	log.Println("Revisions table created or already exists")
+	return nil
+}
+
+// CreateWebhookTables creates webhooks and webhook_deliveries, the
+// persistent delivery queue.
+func CreateWebhookTables() error {
+	queries := []string{`
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		tenant_id VARCHAR(64) NOT NULL,
+		url VARCHAR(2048) NOT NULL,
+		events JSON NOT NULL,
+		secret VARCHAR(255) NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_webhooks_tenant (tenant_id)
+	)`, `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		tenant_id VARCHAR(64) NOT NULL,
+		webhook_id INT NOT NULL,
+		event_id CHAR(32) NULL,
+		event_type VARCHAR(64) NOT NULL,
+		payload JSON NOT NULL,
+		status VARCHAR(16) NOT NULL DEFAULT 'pending',
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP(6) NOT NULL,
+		last_error TEXT NULL,
+		response_status INT NULL,
+		created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
+		delivered_at TIMESTAMP(6) NULL,
+		INDEX idx_webhook_deliveries_due (status, next_attempt_at),
+		INDEX idx_webhook_deliveries_webhook (tenant_id, webhook_id, id),
+		UNIQUE KEY uq_webhook_deliveries_event (webhook_id, event_id),
+		FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+	)`}
+
+	for _, query := range queries {
+		if _, err := DB.Exec(query); err != nil {
+			return fmt.Errorf("failed to create webhook tables: %w", err)
+		}
+	}
+
+	// Deliveries queued before events had IDs keep a NULL event_id, which
+	// the unique key ignores.
+	if _, err := addColumnIfMissing("webhook_deliveries", "event_id", "CHAR(32) NULL AFTER webhook_id"); err != nil {
+		return err
+	}
+	if err := addIndexIfMissing("webhook_deliveries", "uq_webhook_deliveries_event",
+		"UNIQUE KEY uq_webhook_deliveries_event (webhook_id, event_id)"); err != nil {
+		return err
+	}
+
+	log.Println("Webhook tables created or already exist")
+	return nil
+}
+
+// CreateOutboxTable creates outbox, which holds todo events from the
+// transaction that made the change until the relay has published them.
+func CreateOutboxTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS outbox (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		tenant_id VARCHAR(64) NOT NULL,
+		event_id CHAR(32) NOT NULL,
+		event_type VARCHAR(64) NOT NULL,
+		todo_id INT NOT NULL,
+		payload JSON NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP(6) NOT NULL,
+		last_error TEXT NULL,
+		created_at TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
+		published_at TIMESTAMP(6) NULL,
+		UNIQUE KEY uq_outbox_event (event_id),
+		INDEX idx_outbox_due (published_at, next_attempt_at),
+		INDEX idx_outbox_tenant (tenant_id, id)
+	)`
+
+	if _, err := DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to create outbox table: %w", err)
+	}
+	// EventsSince pages through one tenant's events by id.
+	if err := addIndexIfMissing("outbox", "idx_outbox_tenant",
+		"INDEX idx_outbox_tenant (tenant_id, id)"); err != nil {
+		return err
+	}
+
+	log.Println("Outbox table created or already exists")
+	return nil
+}
+
+// addColumnIfMissing reports whether the column had to be added.
+func addColumnIfMissing(table, column, definition string) (bool, error) {
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, table, column).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect %s.%s: %w", table, column, err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return false, fmt.Errorf("failed to add %s.%s: %w", table, column, err)
+	}
+	return true, nil
+}
+
+func addIndexIfMissing(table, index, definition string) error {
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?`, table, index).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to inspect index %s: %w", index, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD %s", table, definition)); err != nil {
+		return fmt.Errorf("failed to add index %s: %w", index, err)
+	}
+	return nil
+}
+
 func CloseDB() error {
 	if DB != nil {
 		return DB.Close()
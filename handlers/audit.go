@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"test-server/models"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditRepository interface {
+	GetTodoHistory(context.Context, int) ([]models.AuditEntry, error)
+	GetAuditLog(context.Context, models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+type AuditHandler struct {
+	repo AuditRepository
+}
+
+func NewAuditHandler(repo AuditRepository) *AuditHandler {
+	return &AuditHandler{repo: repo}
+}
+
+func (h *AuditHandler) GetTodoHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid todo ID")
+		return
+	}
+
+	entries, err := h.repo.GetTodoHistory(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, entries)
+}
+
+// GetAuditLog serves GET /api/audit, filtered by todo_id, actor, action,
+// request_id and a since/until time range, and paged with limit and before.
+func (h *AuditHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := h.repo.GetAuditLog(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, entries)
+}
+
+func parseAuditFilter(r *http.Request) (models.AuditFilter, error) {
+	filter := models.AuditFilter{Limit: defaultAuditLimit}
+	query := r.URL.Query()
+
+	if raw := query.Get("todo_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid todo_id")
+		}
+		filter.TodoID = &id
+	}
+
+	filter.Actor = query.Get("actor")
+	filter.RequestID = query.Get("request_id")
+
+	switch action := query.Get("action"); action {
+	case "", models.AuditCreate, models.AuditUpdate, models.AuditDelete:
+		filter.Action = action
+	default:
+		return filter, fmt.Errorf("action must be create, update or delete")
+	}
+
+	for name, dest := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if raw := query.Get(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*dest = &t
+		}
+	}
+
+	if raw := query.Get("before"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id < 1 {
+			return filter, fmt.Errorf("invalid before")
+		}
+		filter.BeforeID = id
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxAuditLimit)
+		}
+		filter.Limit = n
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"test-server/models"
+
+	"github.com/gorilla/mux"
+)
+
+type MockAuditRepository struct {
+	GetTodoHistoryFunc func(context.Context, int) ([]models.AuditEntry, error)
+	GetAuditLogFunc    func(context.Context, models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+func (m *MockAuditRepository) GetTodoHistory(ctx context.Context, id int) ([]models.AuditEntry, error) {
+	if m.GetTodoHistoryFunc != nil {
+		return m.GetTodoHistoryFunc(ctx, id)
+	}
+	return []models.AuditEntry{}, nil
+}
+
+func (m *MockAuditRepository) GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	if m.GetAuditLogFunc != nil {
+		return m.GetAuditLogFunc(ctx, filter)
+	}
+	return []models.AuditEntry{}, nil
+}
+
+func TestGetTodoHistory(t *testing.T) {
+	mockRepo := &MockAuditRepository{
+		GetTodoHistoryFunc: func(ctx context.Context, id int) ([]models.AuditEntry, error) {
+			if id != 3 {
+				t.Errorf("Expected todo 3, got %d", id)
+			}
+			return []models.AuditEntry{{ID: 1, TodoID: id, Action: models.AuditCreate}}, nil
+		},
+	}
+
+	handler := NewAuditHandler(mockRepo)
+
+	req := httptest.NewRequest("GET", "/api/todos/3/history", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	w := httptest.NewRecorder()
+
+	handler.GetTodoHistory(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestGetAuditLogFilters(t *testing.T) {
+	var got models.AuditFilter
+	mockRepo := &MockAuditRepository{
+		GetAuditLogFunc: func(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+			got = filter
+			return []models.AuditEntry{}, nil
+		},
+	}
+
+	handler := NewAuditHandler(mockRepo)
+
+	req := httptest.NewRequest("GET", "/api/audit?todo_id=3&actor=alice&action=delete&since=2026-01-01T00:00:00Z&limit=5", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAuditLog(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if got.TodoID == nil || *got.TodoID != 3 || got.Actor != "alice" || got.Action != models.AuditDelete ||
+		got.Since == nil || got.Until != nil || got.Limit != 5 {
+		t.Errorf("Unexpected filter %+v", got)
+	}
+}
+
+func TestGetAuditLogInvalidFilter(t *testing.T) {
+	for _, query := range []string{"action=rename", "since=yesterday", "limit=0", "before=x"} {
+		handler := NewAuditHandler(&MockAuditRepository{})
+
+		req := httptest.NewRequest("GET", "/api/audit?"+query, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetAuditLog(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", query, http.StatusBadRequest, w.Code)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"test-server/auth"
+	"test-server/markdown"
+	"test-server/models"
+
+	"github.com/gorilla/mux"
+)
+
+const maxCommentLength = 10000
+
+type CommentRepository interface {
+	CreateComment(context.Context, int, string, *models.CreateCommentRequest) (*models.Comment, error)
+	GetComments(context.Context, int) ([]models.Comment, error)
+	GetComment(context.Context, int, int) (*models.Comment, error)
+	UpdateComment(context.Context, int, int, string, *models.UpdateCommentRequest) (*models.Comment, error)
+	DeleteComment(context.Context, int, int, string) error
+}
+
+type CommentHandler struct {
+	repo CommentRepository
+}
+
+func NewCommentHandler(repo CommentRepository) *CommentHandler {
+	return &CommentHandler{repo: repo}
+}
+
+// commentAuthor attributes comments to the authenticated user.
+func commentAuthor(r *http.Request) string {
+	if user := auth.UserID(r.Context()); user != "" {
+		return user
+	}
+	return models.AnonymousUser
+}
+
+// commentOwner returns the caller who may change their own comments. Anonymous
+// callers share one author name, so they own nothing.
+func commentOwner(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user := auth.UserID(r.Context())
+	if user == "" || user == models.AnonymousUser {
+		respondWithError(w, http.StatusUnauthorized, "Authentication required")
+		return "", false
+	}
+	return user, true
+}
+
+func validCommentBody(body string) (string, error) {
+	if strings.TrimSpace(body) == "" {
+		return "", fmt.Errorf("Body is required")
+	}
+	if len(body) > maxCommentLength {
+		return "", fmt.Errorf("Body must be at most %d characters", maxCommentLength)
+	}
+	return body, nil
+}
+
+// wantsHTML reports whether the caller asked for rendered bodies with
+// ?render=html.
+func wantsHTML(r *http.Request) bool {
+	return r.URL.Query().Get("render") == "html"
+}
+
+func parseCommentVars(r *http.Request) (int, int, error) {
+	vars := mux.Vars(r)
+	todoID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid todo ID")
+	}
+	id, err := strconv.Atoi(vars["commentID"])
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid comment ID")
+	}
+	return todoID, id, nil
+}
+
+func respondWithCommentError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case "todo not found":
+		respondWithError(w, http.StatusNotFound, "Todo not found")
+	case "comment not found":
+		respondWithError(w, http.StatusNotFound, "Comment not found")
+	case "comment belongs to another author":
+		respondWithError(w, http.StatusForbidden, "Only the author can change this comment")
+	default:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid todo ID")
+		return
+	}
+
+	var req models.CreateCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Body, err = validCommentBody(req.Body); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	comment, err := h.repo.CreateComment(r.Context(), todoID, commentAuthor(r), &req)
+	if err != nil {
+		respondWithCommentError(w, err)
+		return
+	}
+
+	if wantsHTML(r) {
+		comment.BodyHTML = markdown.Render(comment.Body)
+	}
+	respondWithJSON(w, http.StatusCreated, comment)
+}
+
+func (h *CommentHandler) GetComments(w http.ResponseWriter, r *http.Request) {
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid todo ID")
+		return
+	}
+
+	comments, err := h.repo.GetComments(r.Context(), todoID)
+	if err != nil {
+		respondWithCommentError(w, err)
+		return
+	}
+
+	if wantsHTML(r) {
+		for i := range comments {
+			comments[i].BodyHTML = markdown.Render(comments[i].Body)
+		}
+	}
+	respondWithJSON(w, http.StatusOK, comments)
+}
+
+func (h *CommentHandler) GetComment(w http.ResponseWriter, r *http.Request) {
+	todoID, id, err := parseCommentVars(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	comment, err := h.repo.GetComment(r.Context(), todoID, id)
+	if err != nil {
+		respondWithCommentError(w, err)
+		return
+	}
+
+	if wantsHTML(r) {
+		comment.BodyHTML = markdown.Render(comment.Body)
+	}
+	respondWithJSON(w, http.StatusOK, comment)
+}
+
+func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	todoID, id, err := parseCommentVars(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	author, ok := commentOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Body, err = validCommentBody(req.Body); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	comment, err := h.repo.UpdateComment(r.Context(), todoID, id, author, &req)
+	if err != nil {
+		respondWithCommentError(w, err)
+		return
+	}
+
+	if wantsHTML(r) {
+		comment.BodyHTML = markdown.Render(comment.Body)
+	}
+	respondWithJSON(w, http.StatusOK, comment)
+}
+
+func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	todoID, id, err := parseCommentVars(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	author, ok := commentOwner(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteComment(r.Context(), todoID, id, author); err != nil {
+		respondWithCommentError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Comment deleted successfully"})
+}
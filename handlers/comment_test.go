@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"test-server/auth"
+	"test-server/models"
+
+	"github.com/gorilla/mux"
+)
+
+type MockCommentRepository struct {
+	CreateCommentFunc func(context.Context, int, string, *models.CreateCommentRequest) (*models.Comment, error)
+	GetCommentsFunc   func(context.Context, int) ([]models.Comment, error)
+	GetCommentFunc    func(context.Context, int, int) (*models.Comment, error)
+	UpdateCommentFunc func(context.Context, int, int, string, *models.UpdateCommentRequest) (*models.Comment, error)
+	DeleteCommentFunc func(context.Context, int, int, string) error
+}
+
+func (m *MockCommentRepository) CreateComment(ctx context.Context, todoID int, author string, req *models.CreateCommentRequest) (*models.Comment, error) {
+	if m.CreateCommentFunc != nil {
+		return m.CreateCommentFunc(ctx, todoID, author, req)
+	}
+	return nil, nil
+}
+
+func (m *MockCommentRepository) GetComments(ctx context.Context, todoID int) ([]models.Comment, error) {
+	if m.GetCommentsFunc != nil {
+		return m.GetCommentsFunc(ctx, todoID)
+	}
+	return nil, nil
+}
+
+func (m *MockCommentRepository) GetComment(ctx context.Context, todoID, id int) (*models.Comment, error) {
+	if m.GetCommentFunc != nil {
+		return m.GetCommentFunc(ctx, todoID, id)
+	}
+	return nil, nil
+}
+
+func (m *MockCommentRepository) UpdateComment(ctx context.Context, todoID, id int, author string, req *models.UpdateCommentRequest) (*models.Comment, error) {
+	if m.UpdateCommentFunc != nil {
+		return m.UpdateCommentFunc(ctx, todoID, id, author, req)
+	}
+	return nil, nil
+}
+
+func (m *MockCommentRepository) DeleteComment(ctx context.Context, todoID, id int, author string) error {
+	if m.DeleteCommentFunc != nil {
+		return m.DeleteCommentFunc(ctx, todoID, id, author)
+	}
+	return nil
+}
+
+func TestCreateCommentAttributesAuthor(t *testing.T) {
+	mockRepo := &MockCommentRepository{
+		CreateCommentFunc: func(ctx context.Context, todoID int, author string, req *models.CreateCommentRequest) (*models.Comment, error) {
+			if todoID != 1 || author != "alice" {
+				t.Errorf("Expected comment by alice on todo 1, got %q on %d", author, todoID)
+			}
+			return &models.Comment{ID: 1, TodoID: todoID, Author: author, Body: req.Body}, nil
+		},
+	}
+
+	handler := NewCommentHandler(mockRepo)
+
+	body, _ := json.Marshal(models.CreateCommentRequest{Body: "Hello"})
+	req := httptest.NewRequest("POST", "/api/todos/1/comments", bytes.NewBuffer(body))
+	req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{"sub": "alice"}))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	w := httptest.NewRecorder()
+
+	handler.CreateComment(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+	}
+}
+
+func TestCreateCommentRequiresBody(t *testing.T) {
+	handler := NewCommentHandler(&MockCommentRepository{})
+
+	req := httptest.NewRequest("POST", "/api/todos/1/comments", bytes.NewBufferString(`{"body":"  "}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	w := httptest.NewRecorder()
+
+	handler.CreateComment(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGetCommentsRendersHTML(t *testing.T) {
+	mockRepo := &MockCommentRepository{
+		GetCommentsFunc: func(ctx context.Context, todoID int) ([]models.Comment, error) {
+			return []models.Comment{{ID: 1, TodoID: todoID, Body: "**hi** <script>alert(1)</script>"}}, nil
+		},
+	}
+
+	handler := NewCommentHandler(mockRepo)
+
+	req := httptest.NewRequest("GET", "/api/todos/1/comments?render=html", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	w := httptest.NewRecorder()
+
+	handler.GetComments(w, req)
+
+	var comments []models.Comment
+	if err := json.NewDecoder(w.Body).Decode(&comments); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(comments) != 1 || !strings.HasPrefix(comments[0].BodyHTML, "<p><strong>hi</strong>") {
+		t.Fatalf("Expected rendered body, got %+v", comments)
+	}
+	if strings.Contains(comments[0].BodyHTML, "<script>") {
+		t.Errorf("Expected script to be escaped, got %q", comments[0].BodyHTML)
+	}
+}
+
+func TestUpdateCommentByOtherAuthor(t *testing.T) {
+	mockRepo := &MockCommentRepository{
+		UpdateCommentFunc: func(ctx context.Context, todoID, id int, author string, req *models.UpdateCommentRequest) (*models.Comment, error) {
+			return nil, errors.New("comment belongs to another author")
+		},
+	}
+
+	handler := NewCommentHandler(mockRepo)
+
+	req := httptest.NewRequest("PUT", "/api/todos/1/comments/2", bytes.NewBufferString(`{"body":"Edited"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1", "commentID": "2"})
+	req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{"sub": "bob"}))
+	w := httptest.NewRecorder()
+
+	handler.UpdateComment(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestDeleteCommentNotFound(t *testing.T) {
+	mockRepo := &MockCommentRepository{
+		DeleteCommentFunc: func(ctx context.Context, todoID, id int, author string) error {
+			if author != "alice" {
+				t.Errorf("Expected alice, got %q", author)
+			}
+			return errors.New("comment not found")
+		},
+	}
+
+	handler := NewCommentHandler(mockRepo)
+
+	req := httptest.NewRequest("DELETE", "/api/todos/1/comments/2", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1", "commentID": "2"})
+	req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{"sub": "alice"}))
+	w := httptest.NewRecorder()
+
+	handler.DeleteComment(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestChangeCommentRequiresAuthentication(t *testing.T) {
+	mockRepo := &MockCommentRepository{
+		UpdateCommentFunc: func(ctx context.Context, todoID, id int, author string, req *models.UpdateCommentRequest) (*models.Comment, error) {
+			t.Error("Expected anonymous edits to be refused before the repository")
+			return nil, nil
+		},
+		DeleteCommentFunc: func(ctx context.Context, todoID, id int, author string) error {
+			t.Error("Expected anonymous deletes to be refused before the repository")
+			return nil
+		},
+	}
+	handler := NewCommentHandler(mockRepo)
+	vars := map[string]string{"id": "1", "commentID": "2"}
+
+	req := mux.SetURLVars(httptest.NewRequest("PUT", "/api/todos/1/comments/2", bytes.NewBufferString(`{"body":"Edited"}`)), vars)
+	w := httptest.NewRecorder()
+	handler.UpdateComment(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d for update, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest("DELETE", "/api/todos/1/comments/2", nil), vars)
+	req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{"sub": models.AnonymousUser}))
+	w = httptest.NewRecorder()
+	handler.DeleteComment(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d for delete, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
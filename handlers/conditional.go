@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"test-server/models"
+)
+
+// listETag is weak: it identifies the list's content, not its exact bytes.
+func listETag(v *models.TodoListVersion) string {
+	return fmt.Sprintf(`W/"%d-%d-%d-%d-%d"`, v.Count, v.LastModified.UnixMicro(), v.ChangeID, v.Comments, v.LastCommentID)
+}
+
+// etagMatches compares an If-None-Match header against etag with the weak
+// comparison RFC 9110 requires for it.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the validators and reports whether the request's
+// preconditions show the client's copy is current. If-Modified-Since is
+// only consulted without If-None-Match, and only to the second.
+func notModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
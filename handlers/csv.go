@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"test-server/models"
+)
+
+// csvColumns is the header of CSV exports. Imports read the same names, so
+// an export can be edited and imported again.
+var csvColumns = []string{
+	"id", "title", "description", "status", "priority", "completed", "due_at", "remind_at",
+	"tags", "list_id", "parent_id", "recurrence", "timezone", "created_at", "updated_at", "completed_at",
+}
+
+// importFields are the fields an imported row can set. id is only read for
+// deduplication.
+var importFields = []string{
+	"id", "title", "description", "status", "priority", "completed", "due_at", "remind_at",
+	"tags", "list_id", "recurrence", "timezone",
+}
+
+// csvFormulaPrefixes start cells that spreadsheets evaluate as formulas.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvText escapes free text for spreadsheets: a cell that would be read as
+// a formula gets a leading apostrophe, which spreadsheets hide.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune(csvFormulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// csvUntext undoes csvText.
+func csvUntext(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func csvInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+func csvRecord(todo *models.Todo) []string {
+	tags := make([]string, len(todo.Tags))
+	for i, tag := range todo.Tags {
+		tags[i] = csvText(tag)
+	}
+	var rule, timezone string
+	if todo.Recurrence != nil {
+		rule, timezone = todo.Recurrence.Rule, todo.Recurrence.Timezone
+	}
+	return []string{
+		strconv.Itoa(todo.ID),
+		csvText(todo.Title),
+		csvText(todo.Description),
+		todo.Status,
+		todo.Priority,
+		strconv.FormatBool(todo.Completed),
+		csvTime(todo.DueAt),
+		csvTime(todo.RemindAt),
+		strings.Join(tags, ","),
+		csvInt(todo.ListID),
+		csvInt(todo.ParentID),
+		rule,
+		timezone,
+		csvTime(&todo.CreatedAt),
+		csvTime(&todo.UpdatedAt),
+		csvTime(todo.CompletedAt),
+	}
+}
+
+// csvFieldName turns a header cell into the field name it maps to by
+// default: "Due At" and "due-at" both become "due_at".
+func csvFieldName(header string) string {
+	name := strings.ToLower(strings.TrimSpace(header))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+// parseColumnMap reads ?map=Header:field overrides.
+func parseColumnMap(values []string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, value := range values {
+		i := strings.LastIndex(value, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("map must be header:field")
+		}
+		header, field := strings.TrimSpace(value[:i]), value[i+1:]
+		if !slices.Contains(importFields, field) {
+			return nil, fmt.Errorf("Unknown field %q", field)
+		}
+		mapping[strings.ToLower(header)] = field
+	}
+	return mapping, nil
+}
+
+// csvMapping assigns a field to each column of header, using overrides
+// first and the header's own name otherwise. It returns the fields, with ""
+// for ignored columns, and the ignored header cells.
+func csvMapping(header []string, overrides map[string]string) ([]string, []string, error) {
+	fields := make([]string, len(header))
+	ignored := []string{}
+	used := map[string]string{}
+	found := map[string]bool{}
+
+	for i, cell := range header {
+		key := strings.ToLower(strings.TrimSpace(cell))
+		field, ok := overrides[key]
+		if ok {
+			found[key] = true
+		} else if name := csvFieldName(cell); slices.Contains(importFields, name) {
+			field = name
+		}
+		if field == "" {
+			ignored = append(ignored, cell)
+			continue
+		}
+		if previous, ok := used[field]; ok {
+			return nil, nil, fmt.Errorf("Columns %q and %q both map to %s", previous, cell, field)
+		}
+		used[field] = cell
+		fields[i] = field
+	}
+
+	for key := range overrides {
+		if !found[key] {
+			return nil, nil, fmt.Errorf("Column %q not found", key)
+		}
+	}
+	if _, ok := used["title"]; !ok {
+		return nil, nil, fmt.Errorf("No column maps to title")
+	}
+	return fields, ignored, nil
+}
+
+// csvRow reads one record into a create request, validated as for
+// POST /api/todos, and returns the row's value of the dedupe field. A
+// completed column only applies when status is empty.
+func csvRow(fields, record []string, dedupe string) (*models.CreateTodoRequest, string, error) {
+	values := map[string]string{}
+	for i, field := range fields {
+		if field != "" && i < len(record) {
+			values[field] = strings.TrimSpace(record[i])
+		}
+	}
+
+	req := &models.CreateTodoRequest{
+		Title:       csvUntext(values["title"]),
+		Description: csvUntext(values["description"]),
+		Status:      strings.ToLower(values["status"]),
+		Priority:    strings.ToLower(values["priority"]),
+		Timezone:    values["timezone"],
+	}
+
+	if raw := values["completed"]; raw != "" {
+		completed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, "", fmt.Errorf("completed must be true or false")
+		}
+		if completed && req.Status == "" {
+			req.Status = models.StatusDone
+		}
+	}
+	if raw := values["due_at"]; raw != "" {
+		req.DueAt = &raw
+	}
+	if raw := values["remind_at"]; raw != "" {
+		req.RemindAt = &raw
+	}
+	if raw := values["recurrence"]; raw != "" {
+		req.Recurrence = &raw
+	}
+	if raw := values["list_id"]; raw != "" {
+		listID, err := parseListID(raw)
+		if err != nil {
+			return nil, "", err
+		}
+		req.ListID = &listID
+	}
+	for _, tag := range strings.Split(values["tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			req.Tags = append(req.Tags, csvUntext(tag))
+		}
+	}
+
+	if err := validateCreateTodo(req); err != nil {
+		return nil, "", err
+	}
+
+	switch dedupe {
+	case "title":
+		return req, req.Title, nil
+	case "description":
+		return req, req.Description, nil
+	}
+	return req, values[dedupe], nil
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"test-server/auth"
+	"test-server/models"
+	"test-server/outbox"
+	"test-server/tenant"
+)
+
+// eventBacklogPage is how many logged events are read at a time when a
+// client resumes.
+const eventBacklogPage = 500
+
+type EventLog interface {
+	EventsSince(context.Context, int64, int) ([]models.TodoEvent, error)
+}
+
+type EventSource interface {
+	Subscribe(buffer int) *outbox.Subscription
+}
+
+type EventStreamHandler struct {
+	log    EventLog
+	source EventSource
+	// Heartbeat is how often an idle stream gets a comment line, keeping
+	// proxies from closing it.
+	Heartbeat time.Duration
+	// Buffer is how many events a client may fall behind before it is
+	// disconnected; it then resumes from the event log.
+	Buffer int
+}
+
+func NewEventStreamHandler(log EventLog, source EventSource) *EventStreamHandler {
+	return &EventStreamHandler{log: log, source: source, Heartbeat: 15 * time.Second, Buffer: 64}
+}
+
+// eventFilter selects the events a stream sends. Streams only ever see
+// their own tenant's events.
+type eventFilter struct {
+	tenantID string
+	types    map[string]bool
+	todoID   *int
+	// skipActor drops the caller's own changes.
+	skipActor string
+}
+
+func (f *eventFilter) matches(event models.TodoEvent) bool {
+	if event.TenantID != f.tenantID {
+		return false
+	}
+	if f.types != nil && !f.types[event.Type] {
+		return false
+	}
+	if f.todoID != nil && event.TodoID != *f.todoID {
+		return false
+	}
+	return f.skipActor == "" || event.Actor != f.skipActor
+}
+
+func parseEventFilter(r *http.Request) (*eventFilter, error) {
+	query := r.URL.Query()
+	filter := &eventFilter{tenantID: tenant.IDFromContext(r.Context())}
+
+	if raw := query.Get("types"); raw != "" {
+		filter.types = map[string]bool{}
+		for _, t := range strings.Split(raw, ",") {
+			if !slices.Contains(models.EventTypes, t) {
+				return nil, fmt.Errorf("Unknown event type %q", t)
+			}
+			filter.types[t] = true
+		}
+	}
+
+	if raw := query.Get("todo_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid todo_id")
+		}
+		filter.todoID = &id
+	}
+
+	if query.Get("exclude_self") == "true" {
+		filter.skipActor = auth.UserID(r.Context())
+	}
+
+	return filter, nil
+}
+
+// lastEventID reads where a client left off: browsers send Last-Event-ID
+// when they reconnect, and ?last_event_id= covers the first connection.
+func lastEventID(r *http.Request) (int64, bool, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	if raw == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, fmt.Errorf("Invalid Last-Event-ID")
+	}
+	return id, true, nil
+}
+
+func writeEvent(w http.ResponseWriter, event models.TodoEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Sequence, event.Type, data)
+	return err
+}
+
+// StreamEvents serves GET /api/todos/events as Server-Sent Events. Each
+// event's id is its outbox sequence; a client that reconnects with
+// Last-Event-ID first gets the logged events it missed, then live ones.
+// Delivery is at-least-once, so clients should drop events whose payload
+// id they have already seen.
+func (h *EventStreamHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	since, resume, err := lastEventID(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Subscribe before reading the backlog so that nothing published in
+	// between is missed.
+	sub := h.source.Subscribe(h.Buffer)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	// Streams outlive any server write timeout.
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Event stream cannot flush: %v", err)
+		return
+	}
+
+	sent := map[int64]bool{}
+	if resume {
+		for {
+			events, err := h.log.EventsSince(r.Context(), since, eventBacklogPage)
+			if err != nil {
+				log.Printf("Failed to read event log: %v", err)
+				return
+			}
+			for _, event := range events {
+				since = event.Sequence
+				sent[event.Sequence] = true
+				if !filter.matches(event) {
+					continue
+				}
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+			}
+			if len(events) < eventBacklogPage {
+				break
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and
+				// resumes from the log.
+				return
+			}
+			if sent[event.Sequence] || !filter.matches(event) {
+				continue
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
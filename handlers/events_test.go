@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"test-server/models"
+	"test-server/outbox"
+)
+
+type MockEventLog struct {
+	EventsSinceFunc func(context.Context, int64, int) ([]models.TodoEvent, error)
+}
+
+func (m *MockEventLog) EventsSince(ctx context.Context, sequence int64, limit int) ([]models.TodoEvent, error) {
+	if m.EventsSinceFunc != nil {
+		return m.EventsSinceFunc(ctx, sequence, limit)
+	}
+	return []models.TodoEvent{}, nil
+}
+
+// readEvent reads one event or comment block from an SSE stream.
+func readEvent(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Stream ended: %v", err)
+		}
+		if line == "\n" {
+			return strings.Join(lines, "\n")
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+}
+
+func openStream(t *testing.T, handler *EventStreamHandler, path string, header http.Header) (*bufio.Reader, func()) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamEvents))
+	req, _ := http.NewRequest("GET", server.URL+path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Unexpected response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return bufio.NewReader(resp.Body), func() {
+		resp.Body.Close()
+		server.Close()
+	}
+}
+
+func TestStreamEventsResumesThenStreamsLive(t *testing.T) {
+	bus := outbox.NewBus()
+	mockLog := &MockEventLog{
+		EventsSinceFunc: func(ctx context.Context, sequence int64, limit int) ([]models.TodoEvent, error) {
+			if sequence != 4 {
+				t.Errorf("Expected to resume after 4, got %d", sequence)
+			}
+			return []models.TodoEvent{
+				{ID: "e5", Sequence: 5, Type: models.EventTodoCreated, TenantID: "default", TodoID: 1},
+				{ID: "e6", Sequence: 6, Type: models.EventTodoDeleted, TenantID: "default", TodoID: 1},
+			}, nil
+		},
+	}
+	handler := NewEventStreamHandler(mockLog, bus)
+
+	stream, done := openStream(t, handler, "/api/todos/events?types=todo.created,todo.updated",
+		http.Header{"Last-Event-ID": {"4"}})
+	defer done()
+
+	if got := readEvent(t, stream); !strings.HasPrefix(got, "id: 5\nevent: todo.created\ndata: {\"id\":\"e5\"") {
+		t.Errorf("Unexpected first event %q", got)
+	}
+
+	// The logged event is not repeated, other tenants and filtered types
+	// are skipped.
+	bus.Publish(context.Background(), models.TodoEvent{ID: "e5", Sequence: 5, Type: models.EventTodoCreated, TenantID: "default"})
+	bus.Publish(context.Background(), models.TodoEvent{ID: "e7", Sequence: 7, Type: models.EventTodoUpdated, TenantID: "globex"})
+	bus.Publish(context.Background(), models.TodoEvent{ID: "e8", Sequence: 8, Type: models.EventTodoDeleted, TenantID: "default"})
+	bus.Publish(context.Background(), models.TodoEvent{ID: "e9", Sequence: 9, Type: models.EventTodoUpdated, TenantID: "default"})
+
+	if got := readEvent(t, stream); !strings.HasPrefix(got, "id: 9\nevent: todo.updated\n") {
+		t.Errorf("Expected e9 next, got %q", got)
+	}
+}
+
+func TestStreamEventsSendsHeartbeats(t *testing.T) {
+	handler := NewEventStreamHandler(&MockEventLog{}, outbox.NewBus())
+	handler.Heartbeat = 10 * time.Millisecond
+
+	stream, done := openStream(t, handler, "/api/todos/events", nil)
+	defer done()
+
+	if got := readEvent(t, stream); got != ": heartbeat" {
+		t.Errorf("Expected a heartbeat, got %q", got)
+	}
+}
+
+func TestStreamEventsInvalidRequest(t *testing.T) {
+	for _, path := range []string{"/api/todos/events?types=todo.renamed", "/api/todos/events?last_event_id=x"} {
+		handler := NewEventStreamHandler(&MockEventLog{}, outbox.NewBus())
+
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+
+		handler.StreamEvents(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", path, http.StatusBadRequest, w.Code)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"test-server/models"
+)
+
+const (
+	exportJSON   = "json"
+	exportNDJSON = "ndjson"
+)
+
+type ExportRepository interface {
+	ExportTodos(context.Context, models.TodoFilter, func(*models.Todo) error) error
+}
+
+type ExportHandler struct {
+	repo ExportRepository
+	// FlushEvery is how many todos are written between flushes.
+	FlushEvery int
+	// WriteTimeout bounds each flush, so that a stalled client cannot hold
+	// the export's database connection forever.
+	WriteTimeout time.Duration
+}
+
+func NewExportHandler(repo ExportRepository) *ExportHandler {
+	return &ExportHandler{repo: repo, FlushEvery: 100, WriteTimeout: 30 * time.Second}
+}
+
+// exportFormat reads ?format=, falling back to the Accept header.
+func exportFormat(r *http.Request) (string, bool) {
+	switch format := r.URL.Query().Get("format"); format {
+	case exportJSON, exportNDJSON:
+		return format, true
+	case "":
+		if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+			return exportNDJSON, true
+		}
+		return exportJSON, true
+	default:
+		return "", false
+	}
+}
+
+// todoEncoder writes an export to a buffered response.
+type todoEncoder interface {
+	begin() error
+	encode(*models.Todo) error
+	end() error
+	flush() error
+}
+
+type jsonEncoder struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+	// array wraps the todos in a JSON array rather than writing one per
+	// line.
+	array bool
+	n     int
+}
+
+func newJSONEncoder(w http.ResponseWriter, array bool) *jsonEncoder {
+	bw := bufio.NewWriter(w)
+	return &jsonEncoder{bw: bw, enc: json.NewEncoder(bw), array: array}
+}
+
+func (e *jsonEncoder) begin() error {
+	if e.array {
+		_, err := e.bw.WriteString("[")
+		return err
+	}
+	return nil
+}
+
+func (e *jsonEncoder) encode(todo *models.Todo) error {
+	if e.array && e.n > 0 {
+		if _, err := e.bw.WriteString(","); err != nil {
+			return err
+		}
+	}
+	e.n++
+	return e.enc.Encode(todo)
+}
+
+func (e *jsonEncoder) end() error {
+	if e.array {
+		_, err := e.bw.WriteString("]\n")
+		return err
+	}
+	return nil
+}
+
+func (e *jsonEncoder) flush() error {
+	return e.bw.Flush()
+}
+
+type csvEncoder struct {
+	cw *csv.Writer
+}
+
+func (e *csvEncoder) begin() error {
+	return e.cw.Write(csvColumns)
+}
+
+func (e *csvEncoder) encode(todo *models.Todo) error {
+	return e.cw.Write(csvRecord(todo))
+}
+
+func (e *csvEncoder) end() error {
+	return nil
+}
+
+func (e *csvEncoder) flush() error {
+	e.cw.Flush()
+	return e.cw.Error()
+}
+
+// ExportTodos streams the todos GetAllTodos would return, as a JSON array
+// or as NDJSON, writing each row as it is read. Filters are those of
+// GET /api/todos.
+func (h *ExportHandler) ExportTodos(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormat(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "format must be json or ndjson")
+		return
+	}
+	if format == exportNDJSON {
+		h.export(w, r, "application/x-ndjson", "todos.ndjson", newJSONEncoder(w, false))
+		return
+	}
+	h.export(w, r, "application/json", "todos.json", newJSONEncoder(w, true))
+}
+
+// ExportCSV streams the same todos as CSV, in the columns ImportTodos
+// reads.
+func (h *ExportHandler) ExportCSV(w http.ResponseWriter, r *http.Request) {
+	h.export(w, r, "text/csv; charset=utf-8", "todos.csv", &csvEncoder{cw: csv.NewWriter(w)})
+}
+
+// export writes the filtered todos through enc. Once the response has
+// started, a failure can no longer change its status, so the connection is
+// aborted instead and the client sees a truncated transfer.
+func (h *ExportHandler) export(w http.ResponseWriter, r *http.Request, contentType, filename string, enc todoEncoder) {
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	count := 0
+	started := false
+
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.WriteHeader(http.StatusOK)
+		return enc.begin()
+	}
+	flush := func() error {
+		rc.SetWriteDeadline(time.Now().Add(h.WriteTimeout))
+		if err := enc.flush(); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+
+	err = h.repo.ExportTodos(r.Context(), filter, func(todo *models.Todo) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := enc.encode(todo); err != nil {
+			return err
+		}
+		count++
+		if count%h.FlushEvery == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = enc.end()
+	}
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		if !started {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		log.Printf("Export aborted after %d todos: %v", count, err)
+		panic(http.ErrAbortHandler)
+	}
+}
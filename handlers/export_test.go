@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"test-server/models"
+)
+
+type MockExportRepository struct {
+	ExportTodosFunc func(context.Context, models.TodoFilter, func(*models.Todo) error) error
+}
+
+func (m *MockExportRepository) ExportTodos(ctx context.Context, filter models.TodoFilter, fn func(*models.Todo) error) error {
+	if m.ExportTodosFunc != nil {
+		return m.ExportTodosFunc(ctx, filter, fn)
+	}
+	return nil
+}
+
+// exportRows feeds n todos to the export callback.
+func exportRows(n int) *MockExportRepository {
+	return &MockExportRepository{
+		ExportTodosFunc: func(ctx context.Context, filter models.TodoFilter, fn func(*models.Todo) error) error {
+			for i := 1; i <= n; i++ {
+				if err := fn(&models.Todo{ID: i, Title: fmt.Sprintf("Todo %d", i), Status: filter.Status}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func TestExportTodosJSON(t *testing.T) {
+	handler := NewExportHandler(exportRows(5))
+	handler.FlushEvery = 2
+
+	req := httptest.NewRequest("GET", "/api/todos/export?status=done", nil)
+	w := httptest.NewRecorder()
+	handler.ExportTodos(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Unexpected response %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !w.Flushed {
+		t.Error("Expected the export to be flushed as it goes")
+	}
+	var todos []models.Todo
+	if err := json.Unmarshal(w.Body.Bytes(), &todos); err != nil {
+		t.Fatalf("Expected a JSON array, got %v: %s", err, w.Body)
+	}
+	if len(todos) != 5 || todos[4].ID != 5 || todos[0].Status != models.StatusDone {
+		t.Errorf("Unexpected todos %+v", todos)
+	}
+}
+
+func TestExportTodosNDJSON(t *testing.T) {
+	handler := NewExportHandler(exportRows(3))
+
+	req := httptest.NewRequest("GET", "/api/todos/export", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	handler.ExportTodos(w, req)
+
+	if w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Expected NDJSON, got %s", w.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %q", w.Body.String())
+	}
+	for i, line := range lines {
+		var todo models.Todo
+		if err := json.Unmarshal([]byte(line), &todo); err != nil || todo.ID != i+1 {
+			t.Errorf("Line %d: unexpected %q", i, line)
+		}
+	}
+}
+
+func TestExportTodosEmpty(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewExportHandler(exportRows(0)).ExportTodos(w, httptest.NewRequest("GET", "/api/todos/export", nil))
+
+	if strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("Expected an empty array, got %q", w.Body.String())
+	}
+}
+
+func TestExportTodosBadFormat(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewExportHandler(exportRows(1)).ExportTodos(w, httptest.NewRequest("GET", "/api/todos/export?format=xml", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestExportTodosFailures(t *testing.T) {
+	failing := &MockExportRepository{
+		ExportTodosFunc: func(ctx context.Context, filter models.TodoFilter, fn func(*models.Todo) error) error {
+			return fmt.Errorf("failed to query todos")
+		},
+	}
+	w := httptest.NewRecorder()
+	NewExportHandler(failing).ExportTodos(w, httptest.NewRequest("GET", "/api/todos/export", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a failure before the first row to be a 500, got %d", w.Code)
+	}
+
+	midway := &MockExportRepository{
+		ExportTodosFunc: func(ctx context.Context, filter models.TodoFilter, fn func(*models.Todo) error) error {
+			fn(&models.Todo{ID: 1})
+			return fmt.Errorf("connection lost")
+		},
+	}
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Errorf("Expected the handler to abort the response, got %v", r)
+		}
+	}()
+	NewExportHandler(midway).ExportTodos(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/todos/export", nil))
+}
+
+func TestExportCSV(t *testing.T) {
+	listID := 3
+	mockRepo := &MockExportRepository{
+		ExportTodosFunc: func(ctx context.Context, filter models.TodoFilter, fn func(*models.Todo) error) error {
+			return fn(&models.Todo{ID: 1, Title: "=HYPERLINK(\"x\")", Status: models.StatusTodo, Priority: models.PriorityHigh,
+				ListID: &listID, Tags: []string{"home", "work"}, Recurrence: &models.Recurrence{Rule: "FREQ=DAILY", Timezone: "UTC"}})
+		},
+	}
+
+	w := httptest.NewRecorder()
+	NewExportHandler(mockRepo).ExportCSV(w, httptest.NewRequest("GET", "/api/todos/export.csv", nil))
+
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("Expected CSV, got %s", w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if len(records) != 2 || strings.Join(records[0], ",") != strings.Join(csvColumns, ",") {
+		t.Fatalf("Unexpected records %q", records)
+	}
+	row := records[1]
+	if row[1] != `'=HYPERLINK("x")` {
+		t.Errorf("Expected the formula to be escaped, got %q", row[1])
+	}
+	if row[8] != "home,work" || row[9] != "3" || row[11] != "FREQ=DAILY" {
+		t.Errorf("Unexpected row %q", row)
+	}
+}
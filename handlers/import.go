@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"test-server/models"
+)
+
+type ImportRepository interface {
+	GetList(context.Context, int) (*models.List, error)
+	ExistingValues(ctx context.Context, field string, values []string) (map[string]bool, error)
+	ImportTodos(ctx context.Context, todos []*models.CreateTodoRequest, batchSize int, progress func(int)) error
+}
+
+// DefaultImportMaxBytes is the largest upload an ImportHandler accepts
+// unless configured otherwise.
+const DefaultImportMaxBytes = 10 << 20
+
+type ImportHandler struct {
+	repo ImportRepository
+	// MaxBytes and MaxRows bound an upload, which is held in memory until
+	// it has been validated.
+	MaxBytes int64
+	MaxRows  int
+	// BatchSize is how many todos are inserted per statement, and so how
+	// often progress is reported.
+	BatchSize    int
+	WriteTimeout time.Duration
+}
+
+func NewImportHandler(repo ImportRepository) *ImportHandler {
+	return &ImportHandler{repo: repo, MaxBytes: DefaultImportMaxBytes, MaxRows: 10000, BatchSize: 500, WriteTimeout: 30 * time.Second}
+}
+
+// importMessage is one line of a streamed import response.
+type importMessage struct {
+	Type     string                 `json:"type"`
+	Progress *models.ImportProgress `json:"progress,omitempty"`
+	Result   *models.ImportResult   `json:"result,omitempty"`
+	Status   int                    `json:"status,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// csvBody returns the uploaded file: the "file" part of a multipart form,
+// or the request body itself.
+func csvBody(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid multipart body")
+	}
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, fmt.Errorf("No file part in upload")
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
+
+// importError maps failures reading the upload to a status.
+func importError(err error) (int, string) {
+	var maxBytes *http.MaxBytesError
+	var parse *csv.ParseError
+	switch {
+	case errors.As(err, &maxBytes):
+		return http.StatusRequestEntityTooLarge, fmt.Sprintf("Import is limited to %d bytes", maxBytes.Limit)
+	case errors.As(err, &parse):
+		return http.StatusBadRequest, "Invalid CSV: " + parse.Error()
+	}
+	return http.StatusBadRequest, err.Error()
+}
+
+// ImportTodos creates todos from an uploaded CSV file. The first row is the
+// header; columns map to fields by name or by ?map=Header:field, and other
+// columns are ignored. Every row is validated before anything is written,
+// and rows whose ?dedupe= field matches an existing todo or an earlier row
+// are skipped. ?dry_run=true stops after validation. Otherwise the todos
+// are created in one transaction, all or nothing; clients that accept
+// application/x-ndjson get a progress line after each batch and the result
+// as the last line.
+func (h *ImportHandler) ImportTodos(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	dryRun := query.Get("dry_run") == "true"
+	dedupe := query.Get("dedupe")
+	if dedupe != "" && dedupe != "id" && dedupe != "title" && dedupe != "description" {
+		respondWithError(w, http.StatusBadRequest, "dedupe must be id, title or description")
+		return
+	}
+	overrides, err := parseColumnMap(query["map"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxBytes)
+	body, err := csvBody(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rows, result, status, message := h.readRows(body, overrides, dedupe)
+	if status != 0 {
+		respondWithError(w, status, message)
+		return
+	}
+	result.DryRun = dryRun
+
+	rows, err = h.checkLists(r.Context(), rows, result)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if dedupe != "" {
+		if rows, err = h.dedupe(r.Context(), dedupe, rows, result); err != nil {
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	slices.SortFunc(result.Errors, func(a, b models.ImportRowError) int { return a.Row - b.Row })
+	slices.Sort(result.Duplicates)
+
+	todos := make([]*models.CreateTodoRequest, len(rows))
+	for i, row := range rows {
+		todos[i] = row.todo
+	}
+
+	if dryRun {
+		result.Imported = len(todos)
+		respondWithJSON(w, http.StatusOK, result)
+		return
+	}
+	if len(result.Errors) > 0 {
+		respondWithJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		if err := h.repo.ImportTodos(r.Context(), todos, h.BatchSize, nil); err != nil {
+			status, message := importFailure(err)
+			respondWithError(w, status, message)
+			return
+		}
+		result.Imported = len(todos)
+		respondWithJSON(w, http.StatusOK, result)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	send := func(message importMessage) {
+		rc.SetWriteDeadline(time.Now().Add(h.WriteTimeout))
+		enc.Encode(message)
+		rc.Flush()
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	err = h.repo.ImportTodos(r.Context(), todos, h.BatchSize, func(imported int) {
+		send(importMessage{Type: "progress", Progress: &models.ImportProgress{Imported: imported, Total: len(todos)}})
+	})
+	if err != nil {
+		status, message := importFailure(err)
+		send(importMessage{Type: "error", Status: status, Error: message})
+		return
+	}
+	result.Imported = len(todos)
+	send(importMessage{Type: "result", Result: result})
+}
+
+// importRow is a row that passed validation, with its dedupe key.
+type importRow struct {
+	row  int
+	todo *models.CreateTodoRequest
+	key  string
+}
+
+// readRows parses and validates the upload. Rows that fail validation are
+// recorded in the result; a non-zero status rejects the whole file.
+func (h *ImportHandler) readRows(body io.Reader, overrides map[string]string, dedupe string) ([]importRow, *models.ImportResult, int, string) {
+	reader := csv.NewReader(body)
+	// Spreadsheets often drop empty trailing cells.
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, http.StatusBadRequest, "CSV file is empty"
+	}
+	if err != nil {
+		status, message := importError(err)
+		return nil, nil, status, message
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	fields, ignored, err := csvMapping(header, overrides)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err.Error()
+	}
+	if dedupe != "" && !slices.Contains(fields, dedupe) {
+		return nil, nil, http.StatusBadRequest, fmt.Sprintf("No column maps to %s", dedupe)
+	}
+
+	result := &models.ImportResult{
+		Duplicates:     []int{},
+		Errors:         []models.ImportRowError{},
+		IgnoredColumns: ignored,
+	}
+	var rows []importRow
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			status, message := importError(err)
+			return nil, nil, status, message
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		result.Rows++
+		if result.Rows > h.MaxRows {
+			return nil, nil, http.StatusRequestEntityTooLarge, fmt.Sprintf("Import is limited to %d rows", h.MaxRows)
+		}
+
+		todo, key, err := csvRow(fields, record, dedupe)
+		if err != nil {
+			result.Errors = append(result.Errors, models.ImportRowError{Row: row, Error: err.Error()})
+			continue
+		}
+		rows = append(rows, importRow{row: row, todo: todo, key: strings.ToLower(key)})
+	}
+	return rows, result, 0, ""
+}
+
+// checkLists drops, and reports, rows naming a list the tenant does not
+// have.
+func (h *ImportHandler) checkLists(ctx context.Context, rows []importRow, result *models.ImportResult) ([]importRow, error) {
+	missing := map[int]bool{}
+	kept := rows[:0]
+	for _, row := range rows {
+		listID := row.todo.ListID
+		if listID != nil && *listID != models.InboxListID {
+			if _, checked := missing[*listID]; !checked {
+				_, err := h.repo.GetList(ctx, *listID)
+				if err != nil && err.Error() != "list not found" {
+					return nil, err
+				}
+				missing[*listID] = err != nil
+			}
+			if missing[*listID] {
+				result.Errors = append(result.Errors, models.ImportRowError{Row: row.row, Error: "List not found"})
+				continue
+			}
+		}
+		kept = append(kept, row)
+	}
+	return kept, nil
+}
+
+// dedupe drops rows whose key repeats an earlier row's or matches an
+// existing todo. Rows with an empty key are always kept.
+func (h *ImportHandler) dedupe(ctx context.Context, field string, rows []importRow, result *models.ImportResult) ([]importRow, error) {
+	seen := map[string]bool{}
+	var values []string
+	unique := rows[:0]
+	for _, row := range rows {
+		if row.key != "" {
+			if seen[row.key] {
+				result.Duplicates = append(result.Duplicates, row.row)
+				continue
+			}
+			seen[row.key] = true
+			values = append(values, row.key)
+		}
+		unique = append(unique, row)
+	}
+	if len(values) == 0 {
+		return unique, nil
+	}
+
+	existing, err := h.repo.ExistingValues(ctx, field, values)
+	if err != nil {
+		return nil, err
+	}
+	kept := unique[:0]
+	for _, row := range unique {
+		if row.key != "" && existing[row.key] {
+			result.Duplicates = append(result.Duplicates, row.row)
+			continue
+		}
+		kept = append(kept, row)
+	}
+	return kept, nil
+}
+
+func importFailure(err error) (int, string) {
+	switch err.Error() {
+	case "todo quota exceeded":
+		return http.StatusForbidden, "Todo quota exceeded"
+	case "list not found":
+		return http.StatusBadRequest, "List not found"
+	}
+	return http.StatusInternalServerError, err.Error()
+}
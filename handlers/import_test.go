@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"test-server/models"
+)
+
+type MockImportRepository struct {
+	GetListFunc        func(context.Context, int) (*models.List, error)
+	ExistingValuesFunc func(context.Context, string, []string) (map[string]bool, error)
+	ImportTodosFunc    func(context.Context, []*models.CreateTodoRequest, int, func(int)) error
+}
+
+func (m *MockImportRepository) GetList(ctx context.Context, id int) (*models.List, error) {
+	if m.GetListFunc != nil {
+		return m.GetListFunc(ctx, id)
+	}
+	return &models.List{ID: id}, nil
+}
+
+func (m *MockImportRepository) ExistingValues(ctx context.Context, field string, values []string) (map[string]bool, error) {
+	if m.ExistingValuesFunc != nil {
+		return m.ExistingValuesFunc(ctx, field, values)
+	}
+	return map[string]bool{}, nil
+}
+
+func (m *MockImportRepository) ImportTodos(ctx context.Context, todos []*models.CreateTodoRequest, batchSize int, progress func(int)) error {
+	if m.ImportTodosFunc != nil {
+		return m.ImportTodosFunc(ctx, todos, batchSize, progress)
+	}
+	return nil
+}
+
+func importRequest(target, body string) *http.Request {
+	req := httptest.NewRequest("POST", target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	return req
+}
+
+func decodeImportResult(t *testing.T, w *httptest.ResponseRecorder) models.ImportResult {
+	t.Helper()
+	var result models.ImportResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode result: %v: %s", err, w.Body)
+	}
+	return result
+}
+
+func TestImportTodosDryRun(t *testing.T) {
+	mockRepo := &MockImportRepository{
+		GetListFunc: func(ctx context.Context, id int) (*models.List, error) {
+			if id == 9 {
+				return nil, fmt.Errorf("list not found")
+			}
+			return &models.List{ID: id}, nil
+		},
+		ExistingValuesFunc: func(ctx context.Context, field string, values []string) (map[string]bool, error) {
+			if field != "title" {
+				t.Errorf("Expected dedupe on title, got %s", field)
+			}
+			return map[string]bool{"water plants": true}, nil
+		},
+		ImportTodosFunc: func(context.Context, []*models.CreateTodoRequest, int, func(int)) error {
+			t.Error("Expected a dry run not to import")
+			return nil
+		},
+	}
+	handler := NewImportHandler(mockRepo)
+
+	body := "\ufeffTask,Priority,List ID,Owner\n" +
+		"Buy milk,high,,ann\n" +
+		"Call mum,extreme,,bob\n" +
+		"buy milk,low,,cat\n" +
+		",,,\n" +
+		"Water plants,,,dan\n" +
+		"File taxes,,9,eve\n" +
+		"Book flights,,3,fay\n"
+	w := httptest.NewRecorder()
+	handler.ImportTodos(w, importRequest("/api/todos/import?dry_run=true&dedupe=title&map=Task:title", body))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	result := decodeImportResult(t, w)
+	if !result.DryRun || result.Rows != 6 || result.Imported != 2 {
+		t.Errorf("Unexpected counts %+v", result)
+	}
+	if len(result.Duplicates) != 2 || result.Duplicates[0] != 4 || result.Duplicates[1] != 6 {
+		t.Errorf("Expected rows 4 and 6 to be duplicates, got %v", result.Duplicates)
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Row != 3 || result.Errors[0].Error != "Invalid priority" ||
+		result.Errors[1].Row != 7 || result.Errors[1].Error != "List not found" {
+		t.Errorf("Unexpected errors %+v", result.Errors)
+	}
+	if len(result.IgnoredColumns) != 1 || result.IgnoredColumns[0] != "Owner" {
+		t.Errorf("Expected Owner to be ignored, got %v", result.IgnoredColumns)
+	}
+}
+
+func TestImportTodosRejectsInvalidRows(t *testing.T) {
+	mockRepo := &MockImportRepository{
+		ImportTodosFunc: func(context.Context, []*models.CreateTodoRequest, int, func(int)) error {
+			t.Error("Expected nothing to be imported")
+			return nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	NewImportHandler(mockRepo).ImportTodos(w, importRequest("/api/todos/import", "title,due_at\nOne,tomorrow\nTwo,\n"))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	result := decodeImportResult(t, w)
+	if result.Imported != 0 || len(result.Errors) != 1 || result.Errors[0].Row != 2 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestImportTodosReadsFields(t *testing.T) {
+	var imported []*models.CreateTodoRequest
+	mockRepo := &MockImportRepository{
+		ImportTodosFunc: func(ctx context.Context, todos []*models.CreateTodoRequest, batchSize int, progress func(int)) error {
+			imported = todos
+			return nil
+		},
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "todos.csv")
+	fmt.Fprint(part, "id,title,completed,tags,due_at,timezone,recurrence,created_at\n"+
+		"4,'=SUM(A1),true,\"home, Errands\",2026-03-01T09:00,Europe/Berlin,weekly,2026-01-01T00:00:00Z\n")
+	form.Close()
+	req := httptest.NewRequest("POST", "/api/todos/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	w := httptest.NewRecorder()
+	NewImportHandler(mockRepo).ImportTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	if len(imported) != 1 {
+		t.Fatalf("Expected one todo, got %d", len(imported))
+	}
+	todo := imported[0]
+	if todo.Title != "=SUM(A1)" || todo.Status != models.StatusDone {
+		t.Errorf("Unexpected title or status %+v", todo)
+	}
+	if len(todo.Tags) != 2 || todo.Tags[1] != "errands" {
+		t.Errorf("Expected normalised tags, got %v", todo.Tags)
+	}
+	if todo.ParsedDueAt == nil || todo.ParsedDueAt.Hour() != 8 {
+		t.Errorf("Expected due_at in Europe/Berlin, got %v", todo.ParsedDueAt)
+	}
+	if todo.Recurrence == nil || *todo.Recurrence != "FREQ=WEEKLY" {
+		t.Errorf("Expected a normalised recurrence, got %v", todo.Recurrence)
+	}
+	if result := decodeImportResult(t, w); result.Imported != 1 || len(result.IgnoredColumns) != 1 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestImportTodosStreamsProgress(t *testing.T) {
+	mockRepo := &MockImportRepository{
+		ImportTodosFunc: func(ctx context.Context, todos []*models.CreateTodoRequest, batchSize int, progress func(int)) error {
+			for done := batchSize; done < len(todos)+batchSize; done += batchSize {
+				progress(min(done, len(todos)))
+			}
+			return nil
+		},
+	}
+	handler := NewImportHandler(mockRepo)
+	handler.BatchSize = 2
+
+	req := importRequest("/api/todos/import", "title\nA\nB\nC\nD\nE\n")
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	handler.ImportTodos(w, req)
+
+	var messages []importMessage
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var message importMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			t.Fatalf("Invalid line %q: %v", scanner.Text(), err)
+		}
+		messages = append(messages, message)
+	}
+
+	if len(messages) != 4 {
+		t.Fatalf("Expected 3 progress lines and a result, got %d", len(messages))
+	}
+	if messages[2].Type != "progress" || messages[2].Progress.Imported != 5 || messages[2].Progress.Total != 5 {
+		t.Errorf("Unexpected progress %+v", messages[2].Progress)
+	}
+	if messages[3].Type != "result" || messages[3].Result.Imported != 5 {
+		t.Errorf("Unexpected result %+v", messages[3])
+	}
+}
+
+func TestImportTodosRejectsFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		status int
+	}{
+		{"empty", "/api/todos/import", "", http.StatusBadRequest},
+		{"no title column", "/api/todos/import", "name\nBuy milk\n", http.StatusBadRequest},
+		{"unknown mapped field", "/api/todos/import?map=Name:owner", "Name\nBuy milk\n", http.StatusBadRequest},
+		{"mapped column missing", "/api/todos/import?map=Task:title", "title\nBuy milk\n", http.StatusBadRequest},
+		{"dedupe column missing", "/api/todos/import?dedupe=id", "title\nBuy milk\n", http.StatusBadRequest},
+		{"malformed", "/api/todos/import", "title\n\"Buy milk\n", http.StatusBadRequest},
+		{"too many rows", "/api/todos/import", "title\nA\nB\nC\n", http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewImportHandler(&MockImportRepository{})
+			handler.MaxRows = 2
+			w := httptest.NewRecorder()
+			handler.ImportTodos(w, importRequest(tt.target, tt.body))
+			if w.Code != tt.status {
+				t.Errorf("Expected status code %d, got %d: %s", tt.status, w.Code, w.Body)
+			}
+		})
+	}
+}
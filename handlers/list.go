@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"test-server/models"
+
+	"github.com/gorilla/mux"
+)
+
+const maxListNameLength = 255
+
+type ListRepository interface {
+	CreateList(context.Context, *models.CreateListRequest) (*models.List, error)
+	GetLists(context.Context) ([]models.List, error)
+	GetList(context.Context, int) (*models.List, error)
+	UpdateList(context.Context, int, *models.UpdateListRequest) (*models.List, error)
+	DeleteList(context.Context, int, string) error
+}
+
+type ListHandler struct {
+	repo  ListRepository
+	todos *TodoHandler
+}
+
+func NewListHandler(repo ListRepository, todos TodoRepository) *ListHandler {
+	return &ListHandler{repo: repo, todos: NewTodoHandler(todos)}
+}
+
+// parseListID accepts a numeric list ID or "inbox".
+func parseListID(raw string) (int, error) {
+	if raw == "inbox" {
+		return models.InboxListID, nil
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid list ID")
+	}
+	return id, nil
+}
+
+func validListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("Name is required")
+	}
+	if len(name) > maxListNameLength {
+		return "", fmt.Errorf("Name must be at most %d characters", maxListNameLength)
+	}
+	return name, nil
+}
+
+func (h *ListHandler) CreateList(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	name, err := validListName(req.Name)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Name = name
+
+	list, err := h.repo.CreateList(r.Context(), &req)
+	if err != nil {
+		if err.Error() == "list name already exists" {
+			respondWithError(w, http.StatusConflict, "List name already exists")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, list)
+}
+
+func (h *ListHandler) GetAllLists(w http.ResponseWriter, r *http.Request) {
+	lists, err := h.repo.GetLists(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, lists)
+}
+
+func (h *ListHandler) GetList(w http.ResponseWriter, r *http.Request) {
+	id, err := parseListID(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+
+	list, ok := h.getList(w, r, id)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+func (h *ListHandler) UpdateList(w http.ResponseWriter, r *http.Request) {
+	id, err := parseListID(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+	if id == models.InboxListID {
+		respondWithError(w, http.StatusBadRequest, "The inbox cannot be changed")
+		return
+	}
+
+	var req models.UpdateListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Name != nil {
+		name, err := validListName(*req.Name)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.Name = &name
+	}
+
+	list, err := h.repo.UpdateList(r.Context(), id, &req)
+	if err != nil {
+		switch err.Error() {
+		case "list not found":
+			respondWithError(w, http.StatusNotFound, "List not found")
+		case "list name already exists":
+			respondWithError(w, http.StatusConflict, "List name already exists")
+		default:
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// DeleteList moves the list's todos to the inbox unless ?todos=delete asks
+// for them to be deleted too.
+func (h *ListHandler) DeleteList(w http.ResponseWriter, r *http.Request) {
+	id, err := parseListID(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+	if id == models.InboxListID {
+		respondWithError(w, http.StatusBadRequest, "The inbox cannot be deleted")
+		return
+	}
+
+	mode := r.URL.Query().Get("todos")
+	switch mode {
+	case "":
+		mode = models.ListDeleteMoveToInbox
+	case models.ListDeleteMoveToInbox, models.ListDeleteCascade:
+	default:
+		respondWithError(w, http.StatusBadRequest, "todos must be inbox or delete")
+		return
+	}
+
+	if err := h.repo.DeleteList(r.Context(), id, mode); err != nil {
+		if err.Error() == "list not found" {
+			respondWithError(w, http.StatusNotFound, "List not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "List deleted successfully"})
+}
+
+func (h *ListHandler) GetListTodos(w http.ResponseWriter, r *http.Request) {
+	id, err := parseListID(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+	if _, ok := h.getList(w, r, id); !ok {
+		return
+	}
+
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.ListID = &id
+
+	todos, err := h.todos.repo.GetAll(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, todos)
+}
+
+func (h *ListHandler) CreateListTodo(w http.ResponseWriter, r *http.Request) {
+	id, err := parseListID(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+	if _, ok := h.getList(w, r, id); !ok {
+		return
+	}
+
+	var req models.CreateTodoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.ListID = &id
+
+	h.todos.createTodo(w, r, &req)
+}
+
+// getList writes the error response and reports false when the list cannot
+// be loaded.
+func (h *ListHandler) getList(w http.ResponseWriter, r *http.Request, id int) (*models.List, bool) {
+	list, err := h.repo.GetList(r.Context(), id)
+	if err != nil {
+		if err.Error() == "list not found" {
+			respondWithError(w, http.StatusNotFound, "List not found")
+			return nil, false
+		}
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return list, true
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"test-server/models"
+
+	"github.com/gorilla/mux"
+)
+
+type MockListRepository struct {
+	CreateListFunc func(context.Context, *models.CreateListRequest) (*models.List, error)
+	GetListsFunc   func(context.Context) ([]models.List, error)
+	GetListFunc    func(context.Context, int) (*models.List, error)
+	UpdateListFunc func(context.Context, int, *models.UpdateListRequest) (*models.List, error)
+	DeleteListFunc func(context.Context, int, string) error
+}
+
+func (m *MockListRepository) CreateList(ctx context.Context, req *models.CreateListRequest) (*models.List, error) {
+	if m.CreateListFunc != nil {
+		return m.CreateListFunc(ctx, req)
+	}
+	return nil, nil
+}
+
+func (m *MockListRepository) GetLists(ctx context.Context) ([]models.List, error) {
+	if m.GetListsFunc != nil {
+		return m.GetListsFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *MockListRepository) GetList(ctx context.Context, id int) (*models.List, error) {
+	if m.GetListFunc != nil {
+		return m.GetListFunc(ctx, id)
+	}
+	return &models.List{ID: id}, nil
+}
+
+func (m *MockListRepository) UpdateList(ctx context.Context, id int, req *models.UpdateListRequest) (*models.List, error) {
+	if m.UpdateListFunc != nil {
+		return m.UpdateListFunc(ctx, id, req)
+	}
+	return nil, nil
+}
+
+func (m *MockListRepository) DeleteList(ctx context.Context, id int, mode string) error {
+	if m.DeleteListFunc != nil {
+		return m.DeleteListFunc(ctx, id, mode)
+	}
+	return nil
+}
+
+func TestCreateList(t *testing.T) {
+	mockRepo := &MockListRepository{
+		CreateListFunc: func(ctx context.Context, req *models.CreateListRequest) (*models.List, error) {
+			if req.Name != "Groceries" {
+				t.Errorf("Expected trimmed name, got %q", req.Name)
+			}
+			return &models.List{ID: 1, Name: req.Name}, nil
+		},
+	}
+
+	handler := NewListHandler(mockRepo, &MockTodoRepository{})
+
+	body, _ := json.Marshal(models.CreateListRequest{Name: "  Groceries "})
+	req := httptest.NewRequest("POST", "/api/lists", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.CreateList(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+	}
+}
+
+func TestCreateListDuplicateName(t *testing.T) {
+	mockRepo := &MockListRepository{
+		CreateListFunc: func(ctx context.Context, req *models.CreateListRequest) (*models.List, error) {
+			return nil, errors.New("list name already exists")
+		},
+	}
+
+	handler := NewListHandler(mockRepo, &MockTodoRepository{})
+
+	req := httptest.NewRequest("POST", "/api/lists", bytes.NewBufferString(`{"name":"Work"}`))
+	w := httptest.NewRecorder()
+
+	handler.CreateList(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestDeleteListModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		id       string
+		wantCode int
+		wantMode string
+	}{
+		{"default moves to inbox", "/api/lists/2", "2", http.StatusOK, models.ListDeleteMoveToInbox},
+		{"cascade", "/api/lists/2?todos=delete", "2", http.StatusOK, models.ListDeleteCascade},
+		{"unknown mode", "/api/lists/2?todos=archive", "2", http.StatusBadRequest, ""},
+		{"inbox", "/api/lists/inbox", "inbox", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotMode string
+			mockRepo := &MockListRepository{
+				DeleteListFunc: func(ctx context.Context, id int, mode string) error {
+					gotMode = mode
+					return nil
+				},
+			}
+
+			handler := NewListHandler(mockRepo, &MockTodoRepository{})
+
+			req := httptest.NewRequest("DELETE", tt.path, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			w := httptest.NewRecorder()
+
+			handler.DeleteList(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status code %d, got %d", tt.wantCode, w.Code)
+			}
+			if gotMode != tt.wantMode {
+				t.Errorf("Expected mode %q, got %q", tt.wantMode, gotMode)
+			}
+		})
+	}
+}
+
+func TestGetListTodosInbox(t *testing.T) {
+	mockTodos := &MockTodoRepository{
+		GetAllFunc: func(ctx context.Context, filter models.TodoFilter) ([]models.Todo, error) {
+			if filter.ListID == nil || *filter.ListID != models.InboxListID {
+				t.Errorf("Expected inbox filter, got %v", filter.ListID)
+			}
+			return []models.Todo{{ID: 1, Title: "Loose end"}}, nil
+		},
+	}
+
+	handler := NewListHandler(&MockListRepository{}, mockTodos)
+
+	req := httptest.NewRequest("GET", "/api/lists/inbox/todos", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "inbox"})
+	w := httptest.NewRecorder()
+
+	handler.GetListTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestCreateListTodoUnknownList(t *testing.T) {
+	mockRepo := &MockListRepository{
+		GetListFunc: func(ctx context.Context, id int) (*models.List, error) {
+			return nil, errors.New("list not found")
+		},
+	}
+
+	handler := NewListHandler(mockRepo, &MockTodoRepository{})
+
+	req := httptest.NewRequest("POST", "/api/lists/9/todos", bytes.NewBufferString(`{"title":"Milk"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "9"})
+	w := httptest.NewRecorder()
+
+	handler.CreateListTodo(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestCreateListTodo(t *testing.T) {
+	mockTodos := &MockTodoRepository{
+		CreateFunc: func(ctx context.Context, req *models.CreateTodoRequest) (*models.Todo, error) {
+			if req.ListID == nil || *req.ListID != 3 {
+				t.Errorf("Expected list 3, got %v", req.ListID)
+			}
+			return &models.Todo{ID: 1, Title: req.Title, ListID: req.ListID}, nil
+		},
+	}
+
+	handler := NewListHandler(&MockListRepository{}, mockTodos)
+
+	req := httptest.NewRequest("POST", "/api/lists/3/todos", bytes.NewBufferString(`{"title":"Milk","list_id":7}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	w := httptest.NewRecorder()
+
+	handler.CreateListTodo(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"test-server/models"
+	"test-server/realtime"
+	"test-server/tenant"
+	"test-server/websocket"
+)
+
+// Commands clients send over the WebSocket.
+const (
+	commandSubscribe   = "subscribe"
+	commandUnsubscribe = "unsubscribe"
+	commandUpdate      = "update"
+)
+
+type realtimeCommand struct {
+	Type  string `json:"type"`
+	Ref   string `json:"ref"`
+	Lists []int  `json:"lists"`
+	Todos []int  `json:"todos"`
+	ID    int    `json:"id"`
+	// Changes takes the same fields as PUT /api/todos/{id}.
+	Changes *models.UpdateTodoRequest `json:"changes"`
+}
+
+type RealtimeHandler struct {
+	todos    *TodoHandler
+	hub      *realtime.Hub
+	Upgrader websocket.Upgrader
+	// PingInterval is how often the server pings; a client that has not
+	// answered within two intervals is disconnected.
+	PingInterval time.Duration
+	WriteTimeout time.Duration
+	// Buffer is how many messages a client may fall behind before it is
+	// disconnected with CloseTryAgainLater.
+	Buffer int
+}
+
+func NewRealtimeHandler(repo TodoRepository, hub *realtime.Hub) *RealtimeHandler {
+	return &RealtimeHandler{
+		todos:        NewTodoHandler(repo),
+		hub:          hub,
+		PingInterval: 30 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		Buffer:       64,
+	}
+}
+
+// Connect upgrades to a WebSocket that carries change events for the
+// subscribed lists and todos and accepts updates to todos. The connection
+// keeps the tenant and identity of the upgrade request.
+func (h *RealtimeHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.Upgrader.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	client := h.hub.Join(tenant.IDFromContext(ctx), h.Buffer)
+	defer h.hub.Leave(client)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.writeLoop(ctx, conn, client)
+	}()
+
+	h.readLoop(ctx, conn, client)
+	cancel()
+	<-done
+}
+
+func (h *RealtimeHandler) readLoop(ctx context.Context, conn *websocket.Conn, client *realtime.Client) {
+	pongWait := 2 * h.PingInterval
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func([]byte) { conn.SetReadDeadline(time.Now().Add(pongWait)) })
+
+	for {
+		op, data, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				conn.Close(websocket.CloseGoingAway, "")
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		if op != websocket.TextMessage {
+			conn.Close(websocket.CloseUnsupportedData, "Text messages only")
+			return
+		}
+		reply := h.handleCommand(ctx, client, data)
+		if encoded, err := json.Marshal(reply); err == nil {
+			client.Deliver(encoded)
+		}
+	}
+}
+
+func (h *RealtimeHandler) writeLoop(ctx context.Context, conn *websocket.Conn, client *realtime.Client) {
+	ticker := time.NewTicker(h.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-client.Dropped():
+			conn.Close(websocket.CloseTryAgainLater, "Client too slow")
+			return
+		case data := <-client.Send():
+			conn.SetWriteDeadline(time.Now().Add(h.WriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(h.WriteTimeout))
+			if err := conn.Ping(nil); err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+func (h *RealtimeHandler) handleCommand(ctx context.Context, client *realtime.Client, data []byte) realtime.Message {
+	var cmd realtimeCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return realtime.Message{Type: realtime.MessageError, Status: http.StatusBadRequest, Error: "Invalid message"}
+	}
+
+	switch cmd.Type {
+	case commandSubscribe, commandUnsubscribe:
+		if cmd.Type == commandSubscribe {
+			client.Subscribe(cmd.Lists, cmd.Todos)
+		} else {
+			client.Unsubscribe(cmd.Lists, cmd.Todos)
+		}
+		lists, todos := client.Subscriptions()
+		return realtime.Message{Type: realtime.MessageSubscribed, Ref: cmd.Ref, Lists: lists, Todos: todos}
+	case commandUpdate:
+		if cmd.Changes == nil {
+			return realtime.Message{Type: realtime.MessageError, Ref: cmd.Ref, Status: http.StatusBadRequest, Error: "changes is required"}
+		}
+		todo, failure := h.todos.updateTodo(ctx, cmd.ID, cmd.Changes)
+		if failure != nil {
+			if failure.code == http.StatusInternalServerError {
+				log.Printf("realtime: update of todo %d failed: %s", cmd.ID, failure.message)
+			}
+			return realtime.Message{Type: realtime.MessageError, Ref: cmd.Ref, Status: failure.code, Error: failure.message}
+		}
+		return realtime.Message{Type: realtime.MessageUpdated, Ref: cmd.Ref, Todo: todo}
+	default:
+		return realtime.Message{Type: realtime.MessageError, Ref: cmd.Ref, Status: http.StatusBadRequest, Error: "Unknown message type"}
+	}
+}
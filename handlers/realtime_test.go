@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"test-server/models"
+	"test-server/outbox"
+	"test-server/realtime"
+	"test-server/websocket"
+)
+
+func openRealtime(t *testing.T, repo TodoRepository, bus *outbox.Bus) *websocket.Conn {
+	t.Helper()
+	hub := realtime.NewHub(bus)
+	t.Cleanup(hub.Close)
+	server := httptest.NewServer(http.HandlerFunc(NewRealtimeHandler(repo, hub).Connect))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { conn.Close(websocket.CloseNormal, "") })
+	return conn
+}
+
+func sendCommand(t *testing.T, conn *websocket.Conn, command string) realtime.Message {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(command)); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	return readMessage(t, conn)
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) realtime.Message {
+	t.Helper()
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	var msg realtime.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("Invalid message %s", data)
+	}
+	return msg
+}
+
+func TestRealtimeSubscriptionReceivesListEvents(t *testing.T) {
+	bus := outbox.NewBus()
+	conn := openRealtime(t, &MockTodoRepository{}, bus)
+
+	msg := sendCommand(t, conn, `{"type":"subscribe","ref":"s1","lists":[3]}`)
+	if msg.Type != realtime.MessageSubscribed || msg.Ref != "s1" || len(msg.Lists) != 1 || msg.Lists[0] != 3 {
+		t.Fatalf("Unexpected reply %+v", msg)
+	}
+
+	list := func(id int) *models.Todo { return &models.Todo{ListID: &id} }
+	ctx := context.Background()
+	bus.Publish(ctx, models.TodoEvent{ID: "other-list", TenantID: "default", TodoID: 1, Todo: list(4)})
+	bus.Publish(ctx, models.TodoEvent{ID: "other-tenant", TenantID: "acme", TodoID: 2, Todo: list(3)})
+	bus.Publish(ctx, models.TodoEvent{ID: "wanted", TenantID: "default", TodoID: 3, Todo: list(3)})
+
+	msg = readMessage(t, conn)
+	if msg.Type != realtime.MessageEvent || msg.Event.ID != "wanted" {
+		t.Errorf("Expected only the subscribed list's event, got %+v", msg)
+	}
+}
+
+func TestRealtimeUpdateUsesTodoValidation(t *testing.T) {
+	var updated *models.UpdateTodoRequest
+	repo := &MockTodoRepository{
+		UpdateFunc: func(ctx context.Context, id int, req *models.UpdateTodoRequest) (*models.Todo, error) {
+			if id == 9 {
+				return nil, fmt.Errorf("todo not found")
+			}
+			if req.Status != nil && *req.Status == models.StatusInProgress {
+				return nil, &models.TransitionError{From: models.StatusDone, To: models.StatusInProgress}
+			}
+			updated = req
+			return &models.Todo{ID: id, Title: *req.Title}, nil
+		},
+	}
+	conn := openRealtime(t, repo, outbox.NewBus())
+
+	tests := []struct {
+		command string
+		status  int
+	}{
+		{`{"type":"update","ref":"u1","id":1,"changes":{"priority":"extreme"}}`, http.StatusBadRequest},
+		{`{"type":"update","ref":"u1","id":1,"changes":{"status":"done","completed":false}}`, http.StatusBadRequest},
+		{`{"type":"update","ref":"u1","id":1,"changes":{"status":"in_progress"}}`, http.StatusConflict},
+		{`{"type":"update","ref":"u1","id":9,"changes":{"title":"Gone"}}`, http.StatusNotFound},
+		{`{"type":"update","ref":"u1","id":1}`, http.StatusBadRequest},
+		{`{"type":"shout"}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		msg := sendCommand(t, conn, tt.command)
+		if msg.Type != realtime.MessageError || msg.Status != tt.status {
+			t.Errorf("%s: expected error %d, got %+v", tt.command, tt.status, msg)
+		}
+	}
+
+	msg := sendCommand(t, conn, `{"type":"update","ref":"u2","id":1,"changes":{"title":"Renamed","tags":[" Home "]}}`)
+	if msg.Type != realtime.MessageUpdated || msg.Ref != "u2" || msg.Todo.Title != "Renamed" {
+		t.Fatalf("Unexpected reply %+v", msg)
+	}
+	if updated == nil || (*updated.Tags)[0] != "home" {
+		t.Errorf("Expected normalized tags to reach the repository, got %+v", updated)
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"test-server/models"
+
+	"github.com/gorilla/mux"
+)
+
+type RevisionRepository interface {
+	GetRevisions(context.Context, int) ([]models.TodoRevision, error)
+	GetRevision(context.Context, int, int) (*models.TodoRevision, error)
+	DiffRevisions(context.Context, int, int, int) (*models.RevisionDiff, error)
+	Revert(context.Context, int, int) (*models.Todo, error)
+}
+
+type RevisionHandler struct {
+	repo RevisionRepository
+}
+
+func NewRevisionHandler(repo RevisionRepository) *RevisionHandler {
+	return &RevisionHandler{repo: repo}
+}
+
+// revisionNumber parses a revision number, which starts at 1.
+func revisionNumber(raw string) (int, bool) {
+	n, err := strconv.Atoi(raw)
+	return n, err == nil && n > 0
+}
+
+func (h *RevisionHandler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid todo ID")
+		return
+	}
+
+	revisions, err := h.repo.GetRevisions(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, revisions)
+}
+
+func (h *RevisionHandler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid todo ID")
+		return
+	}
+	n, ok := revisionNumber(vars["n"])
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid revision")
+		return
+	}
+
+	revision, err := h.repo.GetRevision(r.Context(), id, n)
+	if err != nil {
+		if err.Error() == "revision not found" {
+			respondWithError(w, http.StatusNotFound, "Revision not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, revision)
+}
+
+// DiffRevisions serves GET /api/todos/{id}/revisions/diff?from=n&to=m.
+func (h *RevisionHandler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid todo ID")
+		return
+	}
+	from, ok := revisionNumber(r.URL.Query().Get("from"))
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "from must be a revision number")
+		return
+	}
+	to, ok := revisionNumber(r.URL.Query().Get("to"))
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "to must be a revision number")
+		return
+	}
+
+	diff, err := h.repo.DiffRevisions(r.Context(), id, from, to)
+	if err != nil {
+		if err.Error() == "revision not found" {
+			respondWithError(w, http.StatusNotFound, "Revision not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, diff)
+}
+
+// Revert serves POST /api/todos/{id}/revert?to=n.
+func (h *RevisionHandler) Revert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid todo ID")
+		return
+	}
+	n, ok := revisionNumber(r.URL.Query().Get("to"))
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "to must be a revision number")
+		return
+	}
+
+	todo, err := h.repo.Revert(r.Context(), id, n)
+	if err != nil {
+		var transition *models.TransitionError
+		if errors.As(err, &transition) {
+			respondWithError(w, http.StatusConflict, fmt.Sprintf("Cannot move todo from %s to %s", transition.From, transition.To))
+			return
+		}
+		switch err.Error() {
+		case "todo not found":
+			respondWithError(w, http.StatusNotFound, "Todo not found")
+		case "revision not found":
+			respondWithError(w, http.StatusNotFound, "Revision not found")
+		case "list not found":
+			respondWithError(w, http.StatusConflict, "The revision's list no longer exists")
+		case "parent todo not found":
+			respondWithError(w, http.StatusConflict, "The revision's parent todo no longer exists")
+		case "todo cannot be its own ancestor":
+			respondWithError(w, http.StatusConflict, "A todo cannot be moved under itself or its subtasks")
+		case "todo has open subtasks":
+			respondWithError(w, http.StatusConflict, "Todo has open subtasks")
+		case "todo quota exceeded":
+			respondWithError(w, http.StatusForbidden, "Todo quota exceeded")
+		default:
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, todo)
+}
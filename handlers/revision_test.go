@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"test-server/models"
+
+	"github.com/gorilla/mux"
+)
+
+type MockRevisionRepository struct {
+	GetRevisionsFunc  func(context.Context, int) ([]models.TodoRevision, error)
+	GetRevisionFunc   func(context.Context, int, int) (*models.TodoRevision, error)
+	DiffRevisionsFunc func(context.Context, int, int, int) (*models.RevisionDiff, error)
+	RevertFunc        func(context.Context, int, int) (*models.Todo, error)
+}
+
+func (m *MockRevisionRepository) GetRevisions(ctx context.Context, id int) ([]models.TodoRevision, error) {
+	if m.GetRevisionsFunc != nil {
+		return m.GetRevisionsFunc(ctx, id)
+	}
+	return []models.TodoRevision{}, nil
+}
+
+func (m *MockRevisionRepository) GetRevision(ctx context.Context, id, n int) (*models.TodoRevision, error) {
+	if m.GetRevisionFunc != nil {
+		return m.GetRevisionFunc(ctx, id, n)
+	}
+	return &models.TodoRevision{TodoID: id, Revision: n}, nil
+}
+
+func (m *MockRevisionRepository) DiffRevisions(ctx context.Context, id, from, to int) (*models.RevisionDiff, error) {
+	if m.DiffRevisionsFunc != nil {
+		return m.DiffRevisionsFunc(ctx, id, from, to)
+	}
+	return &models.RevisionDiff{TodoID: id, From: from, To: to}, nil
+}
+
+func (m *MockRevisionRepository) Revert(ctx context.Context, id, n int) (*models.Todo, error) {
+	if m.RevertFunc != nil {
+		return m.RevertFunc(ctx, id, n)
+	}
+	return &models.Todo{ID: id}, nil
+}
+
+func TestGetRevisionNotFound(t *testing.T) {
+	mockRepo := &MockRevisionRepository{
+		GetRevisionFunc: func(ctx context.Context, id, n int) (*models.TodoRevision, error) {
+			return nil, fmt.Errorf("revision not found")
+		},
+	}
+
+	handler := NewRevisionHandler(mockRepo)
+
+	req := httptest.NewRequest("GET", "/api/todos/1/revisions/7", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1", "n": "7"})
+	w := httptest.NewRecorder()
+
+	handler.GetRevision(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"from=1&to=3", http.StatusOK},
+		{"from=0&to=3", http.StatusBadRequest},
+		{"to=3", http.StatusBadRequest},
+		{"from=1&to=x", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		handler := NewRevisionHandler(&MockRevisionRepository{})
+
+		req := httptest.NewRequest("GET", "/api/todos/1/revisions/diff?"+tt.query, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		w := httptest.NewRecorder()
+
+		handler.DiffRevisions(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status code %d, got %d", tt.query, tt.want, w.Code)
+		}
+	}
+}
+
+func TestRevert(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"reverted", nil, http.StatusOK},
+		{"unknown revision", fmt.Errorf("revision not found"), http.StatusNotFound},
+		{"list deleted", fmt.Errorf("list not found"), http.StatusConflict},
+		{"forbidden transition", &models.TransitionError{From: models.StatusArchived, To: models.StatusDone}, http.StatusConflict},
+		{"open subtasks", fmt.Errorf("todo has open subtasks"), http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRevisionRepository{
+				RevertFunc: func(ctx context.Context, id, n int) (*models.Todo, error) {
+					if n != 2 {
+						t.Errorf("Expected revision 2, got %d", n)
+					}
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return &models.Todo{ID: id}, nil
+				},
+			}
+
+			handler := NewRevisionHandler(mockRepo)
+
+			req := httptest.NewRequest("POST", "/api/todos/1/revert?to=2", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			w := httptest.NewRecorder()
+
+			handler.Revert(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status code %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
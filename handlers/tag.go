@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"test-server/models"
+)
+
+type TagRepository interface {
+	ListTags(context.Context) ([]models.TagCount, error)
+}
+
+type TagHandler struct {
+	repo TagRepository
+}
+
+func NewTagHandler(repo TagRepository) *TagHandler {
+	return &TagHandler{repo: repo}
+}
+
+func (h *TagHandler) GetAllTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.repo.ListTags(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tags)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"test-server/models"
+)
+
+type MockTagRepository struct {
+	ListTagsFunc func(context.Context) ([]models.TagCount, error)
+}
+
+func (m *MockTagRepository) ListTags(ctx context.Context) ([]models.TagCount, error) {
+	if m.ListTagsFunc != nil {
+		return m.ListTagsFunc(ctx)
+	}
+	return nil, nil
+}
+
+func TestGetAllTags(t *testing.T) {
+	mockRepo := &MockTagRepository{
+		ListTagsFunc: func(ctx context.Context) ([]models.TagCount, error) {
+			return []models.TagCount{{Name: "home", Count: 2}}, nil
+		},
+	}
+
+	handler := NewTagHandler(mockRepo)
+
+	req := httptest.NewRequest("GET", "/api/tags", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAllTags(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var tags []models.TagCount
+	json.NewDecoder(w.Body).Decode(&tags)
+
+	if len(tags) != 1 || tags[0].Count != 2 {
+		t.Errorf("Unexpected tags %v", tags)
+	}
+}
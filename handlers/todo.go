@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -11,11 +14,16 @@ import (
 )
 
 type TodoRepository interface {
-	Create(*models.CreateTodoRequest) (*models.Todo, error)
-	GetAll() ([]models.Todo, error)
-	GetByID(int) (*models.Todo, error)
-	Update(int, *models.UpdateTodoRequest) (*models.Todo, error)
-	Delete(int) error
+	Create(context.Context, *models.CreateTodoRequest) (*models.Todo, error)
+	GetAll(context.Context, models.TodoFilter) ([]models.Todo, error)
+	GetAllVersion(context.Context, models.TodoFilter) (*models.TodoListVersion, error)
+	GetByID(context.Context, int) (*models.Todo, error)
+	Update(context.Context, int, *models.UpdateTodoRequest) (*models.Todo, error)
+	Delete(context.Context, int) error
+	Move(context.Context, int, *models.MoveTodoRequest) (*models.Todo, error)
+	Search(context.Context, string, int) ([]models.SearchResult, error)
+	GetChildren(context.Context, int) ([]models.Todo, error)
+	GetTree(context.Context, int) (*models.TodoNode, error)
 }
 
 type TodoHandler struct {
@@ -33,13 +41,29 @@ func (h *TodoHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Title == "" {
-		respondWithError(w, http.StatusBadRequest, "Title is required")
+	h.createTodo(w, r, &req)
+}
+
+func (h *TodoHandler) createTodo(w http.ResponseWriter, r *http.Request, req *models.CreateTodoRequest) {
+	if err := validateCreateTodo(req); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	todo, err := h.repo.Create(&req)
+	todo, err := h.repo.Create(r.Context(), req)
 	if err != nil {
+		if err.Error() == "todo quota exceeded" {
+			respondWithError(w, http.StatusForbidden, "Todo quota exceeded")
+			return
+		}
+		if err.Error() == "list not found" {
+			respondWithError(w, http.StatusBadRequest, "List not found")
+			return
+		}
+		if err.Error() == "parent todo not found" {
+			respondWithError(w, http.StatusBadRequest, "Parent todo not found")
+			return
+		}
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -47,8 +71,65 @@ func (h *TodoHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusCreated, todo)
 }
 
+// validateCreateTodo checks a create request and normalises it in place:
+// times are parsed and tags and recurrence brought to canonical form.
+func validateCreateTodo(req *models.CreateTodoRequest) error {
+	if req.Title == "" {
+		return fmt.Errorf("Title is required")
+	}
+
+	err := req.ParseTimes()
+	if err != nil {
+		return err
+	}
+
+	if req.Status != "" && !models.ValidStatus(req.Status) {
+		return fmt.Errorf("Invalid status")
+	}
+	if req.Priority != "" && !models.ValidPriority(req.Priority) {
+		return fmt.Errorf("Invalid priority")
+	}
+
+	if req.Tags, err = models.NormalizeTags(req.Tags); err != nil {
+		return err
+	}
+
+	if req.Recurrence != nil {
+		rule, err := models.NormalizeRecurrence(*req.Recurrence)
+		if err != nil {
+			return err
+		}
+		req.Recurrence = &rule
+	}
+	return nil
+}
+
 func (h *TodoHandler) GetAllTodos(w http.ResponseWriter, r *http.Request) {
-	todos, err := h.repo.GetAll()
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Lists are per caller and tenant, and must be revalidated before reuse.
+	// Shared caches must not store them at all; the Tenant middleware adds
+	// its header to Vary for any that do.
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Add("Vary", "Authorization")
+
+	// The version costs a summary query on top of the list query, so that a
+	// revalidation can be answered without loading the list.
+	version, err := h.repo.GetAllVersion(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if version != nil && notModified(w, r, listETag(version), version.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	todos, err := h.repo.GetAll(r.Context(), filter)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -57,6 +138,32 @@ func (h *TodoHandler) GetAllTodos(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, todos)
 }
 
+func (h *TodoHandler) SearchTodos(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		respondWithError(w, http.StatusBadRequest, "Query parameter q is required")
+		return
+	}
+
+	limit := 20
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 100 {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+
+	results, err := h.repo.Search(r.Context(), q, limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, results)
+}
+
 func (h *TodoHandler) GetTodo(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -65,7 +172,7 @@ func (h *TodoHandler) GetTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	todo, err := h.repo.GetByID(id)
+	todo, err := h.repo.GetByID(r.Context(), id)
 	if err != nil {
 		if err.Error() == "todo not found" {
 			respondWithError(w, http.StatusNotFound, "Todo not found")
@@ -78,6 +185,48 @@ func (h *TodoHandler) GetTodo(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, todo)
 }
 
+func (h *TodoHandler) GetTodoChildren(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid todo ID")
+		return
+	}
+
+	todos, err := h.repo.GetChildren(r.Context(), id)
+	if err != nil {
+		if err.Error() == "todo not found" {
+			respondWithError(w, http.StatusNotFound, "Todo not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, todos)
+}
+
+func (h *TodoHandler) GetTodoTree(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid todo ID")
+		return
+	}
+
+	tree, err := h.repo.GetTree(r.Context(), id)
+	if err != nil {
+		if err.Error() == "todo not found" {
+			respondWithError(w, http.StatusNotFound, "Todo not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tree)
+}
+
 func (h *TodoHandler) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -92,13 +241,117 @@ func (h *TodoHandler) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	todo, err := h.repo.Update(id, &req)
+	todo, failure := h.updateTodo(r.Context(), id, &req)
+	if failure != nil {
+		respondWithError(w, failure.code, failure.message)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, todo)
+}
+
+// statusError is a request failure along with the HTTP status it maps to,
+// so the same validation can answer over HTTP and the WebSocket channel.
+type statusError struct {
+	code    int
+	message string
+}
+
+// updateTodo validates and applies an update.
+func (h *TodoHandler) updateTodo(ctx context.Context, id int, req *models.UpdateTodoRequest) (*models.Todo, *statusError) {
+	if err := req.ParseTimes(); err != nil {
+		return nil, &statusError{http.StatusBadRequest, err.Error()}
+	}
+
+	if req.Status != nil && !models.ValidStatus(*req.Status) {
+		return nil, &statusError{http.StatusBadRequest, "Invalid status"}
+	}
+	if req.Priority != nil && !models.ValidPriority(*req.Priority) {
+		return nil, &statusError{http.StatusBadRequest, "Invalid priority"}
+	}
+	if req.Tags != nil {
+		tags, err := models.NormalizeTags(*req.Tags)
+		if err != nil {
+			return nil, &statusError{http.StatusBadRequest, err.Error()}
+		}
+		req.Tags = &tags
+	}
+	if req.Recurrence != nil {
+		rule, err := models.NormalizeRecurrence(*req.Recurrence)
+		if err != nil {
+			return nil, &statusError{http.StatusBadRequest, err.Error()}
+		}
+		req.Recurrence = &rule
+	}
+	if req.ParentID != nil && *req.ParentID == id {
+		return nil, &statusError{http.StatusBadRequest, "A todo cannot be its own parent"}
+	}
+	if req.Status != nil && req.Completed != nil && *req.Status != models.StatusArchived &&
+		*req.Completed != (*req.Status == models.StatusDone) {
+		return nil, &statusError{http.StatusBadRequest, "completed contradicts status"}
+	}
+
+	todo, err := h.repo.Update(ctx, id, req)
 	if err != nil {
-		if err.Error() == "todo not found" {
+		var transition *models.TransitionError
+		if errors.As(err, &transition) {
+			return nil, &statusError{http.StatusConflict, fmt.Sprintf("Cannot move todo from %s to %s", transition.From, transition.To)}
+		}
+		switch err.Error() {
+		case "todo not found":
+			return nil, &statusError{http.StatusNotFound, "Todo not found"}
+		case "list not found":
+			return nil, &statusError{http.StatusBadRequest, "List not found"}
+		case "parent todo not found":
+			return nil, &statusError{http.StatusBadRequest, "Parent todo not found"}
+		case "todo cannot be its own ancestor":
+			return nil, &statusError{http.StatusConflict, "A todo cannot be moved under itself or its subtasks"}
+		case "todo has open subtasks":
+			return nil, &statusError{http.StatusConflict, "Todo has open subtasks"}
+		case "todo quota exceeded":
+			return nil, &statusError{http.StatusForbidden, "Todo quota exceeded"}
+		default:
+			return nil, &statusError{http.StatusInternalServerError, err.Error()}
+		}
+	}
+	return todo, nil
+}
+
+// MoveTodo places a todo before or after another todo in the tenant's list.
+func (h *TodoHandler) MoveTodo(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid todo ID")
+		return
+	}
+
+	var req models.MoveTodoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Before == nil && req.After == nil {
+		respondWithError(w, http.StatusBadRequest, "before or after is required")
+		return
+	}
+	if req.Before != nil && *req.Before == id || req.After != nil && *req.After == id {
+		respondWithError(w, http.StatusBadRequest, "A todo cannot be moved relative to itself")
+		return
+	}
+
+	todo, err := h.repo.Move(r.Context(), id, &req)
+	if err != nil {
+		switch err.Error() {
+		case "todo not found":
 			respondWithError(w, http.StatusNotFound, "Todo not found")
-			return
+		case "anchor todo not found":
+			respondWithError(w, http.StatusBadRequest, "before or after todo not found")
+		case "after todo is not above before todo":
+			respondWithError(w, http.StatusBadRequest, "after must be listed above before")
+		default:
+			respondWithError(w, http.StatusInternalServerError, err.Error())
 		}
-		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -113,7 +366,7 @@ func (h *TodoHandler) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.repo.Delete(id)
+	err = h.repo.Delete(r.Context(), id)
 	if err != nil {
 		if err.Error() == "todo not found" {
 			respondWithError(w, http.StatusNotFound, "Todo not found")
@@ -126,6 +379,72 @@ func (h *TodoHandler) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Todo deleted successfully"})
 }
 
+// parseTodoFilter reads the list filters. due_before is interpreted in the
+// tz query parameter when it carries no offset.
+func parseTodoFilter(r *http.Request) (models.TodoFilter, error) {
+	var filter models.TodoFilter
+	query := r.URL.Query()
+
+	if raw := query.Get("due_before"); raw != "" {
+		loc, err := models.LoadTimezone(query.Get("tz"))
+		if err != nil {
+			return filter, err
+		}
+		dueBefore, err := models.ParseDateTime(raw, loc)
+		if err != nil {
+			return filter, fmt.Errorf("due_before: %w", err)
+		}
+		filter.DueBefore = &dueBefore
+	}
+
+	if raw := query.Get("list_id"); raw != "" {
+		listID, err := parseListID(raw)
+		if err != nil {
+			return filter, err
+		}
+		filter.ListID = &listID
+	}
+
+	if status := query.Get("status"); status != "" {
+		if !models.ValidStatus(status) {
+			return filter, fmt.Errorf("invalid status")
+		}
+		filter.Status = status
+	}
+
+	if priority := query.Get("priority"); priority != "" {
+		if !models.ValidPriority(priority) {
+			return filter, fmt.Errorf("invalid priority")
+		}
+		filter.Priority = priority
+	}
+
+	if tags := query["tag"]; len(tags) > 0 {
+		normalized, err := models.NormalizeTags(tags)
+		if err != nil {
+			return filter, err
+		}
+		filter.Tags = normalized
+	}
+
+	switch match := query.Get("tag_match"); match {
+	case "", "any", "all":
+		filter.TagMatch = match
+	default:
+		return filter, fmt.Errorf("tag_match must be any or all")
+	}
+
+	if raw := query.Get("overdue"); raw != "" {
+		overdue, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("overdue must be true or false")
+		}
+		filter.Overdue = overdue
+	}
+
+	return filter, nil
+}
+
 func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJSON(w, code, map[string]string{"error": message})
 }
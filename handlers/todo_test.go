@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,51 +18,92 @@ import (
 
 // MockTodoRepository mocks the TodoRepository for testing
 type MockTodoRepository struct {
-	CreateFunc  func(*models.CreateTodoRequest) (*models.Todo, error)
-	GetAllFunc  func() ([]models.Todo, error)
-	GetByIDFunc func(int) (*models.Todo, error)
-	UpdateFunc  func(int, *models.UpdateTodoRequest) (*models.Todo, error)
-	DeleteFunc  func(int) error
+	CreateFunc        func(context.Context, *models.CreateTodoRequest) (*models.Todo, error)
+	GetAllFunc        func(context.Context, models.TodoFilter) ([]models.Todo, error)
+	GetAllVersionFunc func(context.Context, models.TodoFilter) (*models.TodoListVersion, error)
+	GetByIDFunc       func(context.Context, int) (*models.Todo, error)
+	UpdateFunc        func(context.Context, int, *models.UpdateTodoRequest) (*models.Todo, error)
+	DeleteFunc        func(context.Context, int) error
+	MoveFunc          func(context.Context, int, *models.MoveTodoRequest) (*models.Todo, error)
+	SearchFunc        func(context.Context, string, int) ([]models.SearchResult, error)
+
+	GetChildrenFunc func(context.Context, int) ([]models.Todo, error)
+	GetTreeFunc     func(context.Context, int) (*models.TodoNode, error)
 }
 
-func (m *MockTodoRepository) Create(req *models.CreateTodoRequest) (*models.Todo, error) {
+func (m *MockTodoRepository) Create(ctx context.Context, req *models.CreateTodoRequest) (*models.Todo, error) {
 	if m.CreateFunc != nil {
-		return m.CreateFunc(req)
+		return m.CreateFunc(ctx, req)
 	}
 	return nil, nil
 }
 
-func (m *MockTodoRepository) GetAll() ([]models.Todo, error) {
+func (m *MockTodoRepository) GetAll(ctx context.Context, filter models.TodoFilter) ([]models.Todo, error) {
 	if m.GetAllFunc != nil {
-		return m.GetAllFunc()
+		return m.GetAllFunc(ctx, filter)
 	}
 	return nil, nil
 }
 
-func (m *MockTodoRepository) GetByID(id int) (*models.Todo, error) {
+func (m *MockTodoRepository) GetAllVersion(ctx context.Context, filter models.TodoFilter) (*models.TodoListVersion, error) {
+	if m.GetAllVersionFunc != nil {
+		return m.GetAllVersionFunc(ctx, filter)
+	}
+	return nil, nil
+}
+
+func (m *MockTodoRepository) GetByID(ctx context.Context, id int) (*models.Todo, error) {
 	if m.GetByIDFunc != nil {
-		return m.GetByIDFunc(id)
+		return m.GetByIDFunc(ctx, id)
 	}
 	return nil, nil
 }
 
-func (m *MockTodoRepository) Update(id int, req *models.UpdateTodoRequest) (*models.Todo, error) {
+func (m *MockTodoRepository) Update(ctx context.Context, id int, req *models.UpdateTodoRequest) (*models.Todo, error) {
 	if m.UpdateFunc != nil {
-		return m.UpdateFunc(id, req)
+		return m.UpdateFunc(ctx, id, req)
 	}
 	return nil, nil
 }
 
-func (m *MockTodoRepository) Delete(id int) error {
+func (m *MockTodoRepository) Delete(ctx context.Context, id int) error {
 	if m.DeleteFunc != nil {
-		return m.DeleteFunc(id)
+		return m.DeleteFunc(ctx, id)
 	}
 	return nil
 }
 
+func (m *MockTodoRepository) Search(ctx context.Context, q string, limit int) ([]models.SearchResult, error) {
+	if m.SearchFunc != nil {
+		return m.SearchFunc(ctx, q, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockTodoRepository) Move(ctx context.Context, id int, req *models.MoveTodoRequest) (*models.Todo, error) {
+	if m.MoveFunc != nil {
+		return m.MoveFunc(ctx, id, req)
+	}
+	return nil, nil
+}
+
+func (m *MockTodoRepository) GetChildren(ctx context.Context, id int) ([]models.Todo, error) {
+	if m.GetChildrenFunc != nil {
+		return m.GetChildrenFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockTodoRepository) GetTree(ctx context.Context, id int) (*models.TodoNode, error) {
+	if m.GetTreeFunc != nil {
+		return m.GetTreeFunc(ctx, id)
+	}
+	return nil, nil
+}
+
 func TestCreateTodo(t *testing.T) {
 	mockRepo := &MockTodoRepository{
-		CreateFunc: func(req *models.CreateTodoRequest) (*models.Todo, error) {
+		CreateFunc: func(ctx context.Context, req *models.CreateTodoRequest) (*models.Todo, error) {
 			return &models.Todo{
 				ID:          1,
 				Title:       req.Title,
@@ -99,7 +142,7 @@ func TestCreateTodo(t *testing.T) {
 
 func TestGetAllTodos(t *testing.T) {
 	mockRepo := &MockTodoRepository{
-		GetAllFunc: func() ([]models.Todo, error) {
+		GetAllFunc: func(ctx context.Context, filter models.TodoFilter) ([]models.Todo, error) {
 			return []models.Todo{
 				{ID: 1, Title: "Todo 1", Description: "Desc 1", Completed: false},
 				{ID: 2, Title: "Todo 2", Description: "Desc 2", Completed: true},
@@ -126,9 +169,63 @@ func TestGetAllTodos(t *testing.T) {
 	}
 }
 
+func TestGetAllTodosConditional(t *testing.T) {
+	modified := time.Date(2026, 3, 1, 12, 0, 0, 500000, time.UTC)
+	loads := 0
+	mockRepo := &MockTodoRepository{
+		GetAllVersionFunc: func(ctx context.Context, filter models.TodoFilter) (*models.TodoListVersion, error) {
+			return &models.TodoListVersion{Count: 2, LastModified: modified, ChangeID: 9}, nil
+		},
+		GetAllFunc: func(ctx context.Context, filter models.TodoFilter) ([]models.Todo, error) {
+			loads++
+			return []models.Todo{{ID: 1}, {ID: 2}}, nil
+		},
+	}
+	handler := &TodoHandler{repo: mockRepo}
+
+	w := httptest.NewRecorder()
+	handler.GetAllTodos(w, httptest.NewRequest("GET", "/api/todos", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"2-`) {
+		t.Fatalf("Expected 200 with a weak ETag, got %d %q", w.Code, etag)
+	}
+	if w.Header().Get("Last-Modified") != "Sun, 01 Mar 2026 12:00:00 GMT" {
+		t.Errorf("Unexpected Last-Modified %q", w.Header().Get("Last-Modified"))
+	}
+	if w.Header().Get("Cache-Control") != "private, no-cache" {
+		t.Errorf("Unexpected Cache-Control %q", w.Header().Get("Cache-Control"))
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		code   int
+	}{
+		{"matching etag", "If-None-Match", `"x", ` + strings.TrimPrefix(etag, "W/"), http.StatusNotModified},
+		{"stale etag", "If-None-Match", `W/"1-0-0-0-0"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", "Sun, 01 Mar 2026 12:00:00 GMT", http.StatusNotModified},
+		{"modified since", "If-Modified-Since", "Sun, 01 Mar 2026 11:59:59 GMT", http.StatusOK},
+	}
+	for _, tt := range tests {
+		loads = 0
+		req := httptest.NewRequest("GET", "/api/todos", nil)
+		req.Header.Set(tt.header, tt.value)
+		w := httptest.NewRecorder()
+		handler.GetAllTodos(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("%s: expected status code %d, got %d", tt.name, tt.code, w.Code)
+		}
+		if tt.code == http.StatusNotModified && (loads != 0 || w.Body.Len() != 0) {
+			t.Errorf("%s: expected no list to be loaded or sent", tt.name)
+		}
+	}
+}
+
 func TestGetTodo(t *testing.T) {
 	mockRepo := &MockTodoRepository{
-		GetByIDFunc: func(id int) (*models.Todo, error) {
+		GetByIDFunc: func(ctx context.Context, id int) (*models.Todo, error) {
 			return &models.Todo{
 				ID:          id,
 				Title:       "Test Todo",
@@ -161,7 +258,7 @@ func TestGetTodo(t *testing.T) {
 func TestUpdateTodo(t *testing.T) {
 	title := "Updated Title"
 	mockRepo := &MockTodoRepository{
-		UpdateFunc: func(id int, req *models.UpdateTodoRequest) (*models.Todo, error) {
+		UpdateFunc: func(ctx context.Context, id int, req *models.UpdateTodoRequest) (*models.Todo, error) {
 			return &models.Todo{
 				ID:          id,
 				Title:       *req.Title,
@@ -198,7 +295,7 @@ func TestUpdateTodo(t *testing.T) {
 
 func TestDeleteTodo(t *testing.T) {
 	mockRepo := &MockTodoRepository{
-		DeleteFunc: func(id int) error {
+		DeleteFunc: func(ctx context.Context, id int) error {
 			return nil
 		},
 	}
@@ -218,7 +315,7 @@ func TestDeleteTodo(t *testing.T) {
 
 func TestGetTodoNotFound(t *testing.T) {
 	mockRepo := &MockTodoRepository{
-		GetByIDFunc: func(id int) (*models.Todo, error) {
+		GetByIDFunc: func(ctx context.Context, id int) (*models.Todo, error) {
 			return nil, errors.New("todo not found")
 		},
 	}
@@ -235,3 +332,350 @@ func TestGetTodoNotFound(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
 	}
 }
+
+func TestCreateTodoQuotaExceeded(t *testing.T) {
+	mockRepo := &MockTodoRepository{
+		CreateFunc: func(ctx context.Context, req *models.CreateTodoRequest) (*models.Todo, error) {
+			return nil, errors.New("todo quota exceeded")
+		},
+	}
+
+	handler := &TodoHandler{repo: mockRepo}
+
+	body, _ := json.Marshal(models.CreateTodoRequest{Title: "Test Todo"})
+	req := httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.CreateTodo(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestSearchTodos(t *testing.T) {
+	mockRepo := &MockTodoRepository{
+		SearchFunc: func(ctx context.Context, q string, limit int) ([]models.SearchResult, error) {
+			if q != `+milk "oat bread"` || limit != 5 {
+				t.Errorf("Unexpected search arguments %q, %d", q, limit)
+			}
+			return []models.SearchResult{
+				{Todo: models.Todo{ID: 1, Title: "Buy milk"}, Score: 1.5},
+			}, nil
+		},
+	}
+
+	handler := &TodoHandler{repo: mockRepo}
+
+	req := httptest.NewRequest("GET", `/api/todos/search?q=%2Bmilk+%22oat+bread%22&limit=5`, nil)
+	w := httptest.NewRecorder()
+
+	handler.SearchTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var results []models.SearchResult
+	json.NewDecoder(w.Body).Decode(&results)
+
+	if len(results) != 1 || results[0].Score != 1.5 {
+		t.Errorf("Unexpected results %+v", results)
+	}
+}
+
+func TestSearchTodosRequiresQuery(t *testing.T) {
+	handler := &TodoHandler{repo: &MockTodoRepository{}}
+
+	req := httptest.NewRequest("GET", "/api/todos/search", nil)
+	w := httptest.NewRecorder()
+
+	handler.SearchTodos(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGetAllTodosDueFilters(t *testing.T) {
+	var got models.TodoFilter
+	mockRepo := &MockTodoRepository{
+		GetAllFunc: func(ctx context.Context, filter models.TodoFilter) ([]models.Todo, error) {
+			got = filter
+			return []models.Todo{}, nil
+		},
+	}
+
+	handler := &TodoHandler{repo: mockRepo}
+
+	req := httptest.NewRequest("GET", "/api/todos?due_before=2026-03-01T09:00&tz=Europe/Berlin&overdue=true", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	want := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	if got.DueBefore == nil || !got.DueBefore.Equal(want) {
+		t.Errorf("Expected due_before %v, got %v", want, got.DueBefore)
+	}
+	if !got.Overdue {
+		t.Error("Expected overdue filter")
+	}
+}
+
+func TestCreateTodoInvalidDueAt(t *testing.T) {
+	handler := &TodoHandler{repo: &MockTodoRepository{}}
+
+	body := []byte(`{"title":"Test Todo","due_at":"next tuesday"}`)
+	req := httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.CreateTodo(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestUpdateTodoStatusTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		repoErr error
+		code    int
+		message string
+	}{
+		{"start work", `{"status":"in_progress"}`, nil, http.StatusOK, ""},
+		{"finish archived", `{"status":"done"}`, &models.TransitionError{From: models.StatusArchived, To: models.StatusDone},
+			http.StatusConflict, "Cannot move todo from archived to done"},
+		{"legacy complete", `{"completed":true}`, nil, http.StatusOK, ""},
+		{"contradiction", `{"status":"done","completed":false}`, nil, http.StatusBadRequest, ""},
+		{"unknown status", `{"status":"someday"}`, nil, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockTodoRepository{
+				UpdateFunc: func(ctx context.Context, id int, req *models.UpdateTodoRequest) (*models.Todo, error) {
+					if tt.repoErr != nil {
+						return nil, tt.repoErr
+					}
+					return &models.Todo{ID: id}, nil
+				},
+			}
+
+			handler := &TodoHandler{repo: mockRepo}
+
+			req := httptest.NewRequest("PUT", "/api/todos/1", bytes.NewBufferString(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			w := httptest.NewRecorder()
+
+			handler.UpdateTodo(w, req)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status code %d, got %d", tt.code, w.Code)
+			}
+			if tt.message != "" && !strings.Contains(w.Body.String(), tt.message) {
+				t.Errorf("Expected %q, got %s", tt.message, w.Body)
+			}
+		})
+	}
+}
+
+func TestGetAllTodosTagFilter(t *testing.T) {
+	var got models.TodoFilter
+	mockRepo := &MockTodoRepository{
+		GetAllFunc: func(ctx context.Context, filter models.TodoFilter) ([]models.Todo, error) {
+			got = filter
+			return []models.Todo{}, nil
+		},
+	}
+
+	handler := &TodoHandler{repo: mockRepo}
+
+	req := httptest.NewRequest("GET", "/api/todos?tag=Home&tag=work&tag_match=all", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if len(got.Tags) != 2 || got.Tags[0] != "home" || got.TagMatch != "all" {
+		t.Errorf("Unexpected filter %+v", got)
+	}
+}
+
+func TestCreateTodoNormalizesTags(t *testing.T) {
+	var got []string
+	mockRepo := &MockTodoRepository{
+		CreateFunc: func(ctx context.Context, req *models.CreateTodoRequest) (*models.Todo, error) {
+			got = req.Tags
+			return &models.Todo{ID: 1, Title: req.Title, Tags: req.Tags}, nil
+		},
+	}
+
+	handler := &TodoHandler{repo: mockRepo}
+
+	body := []byte(`{"title":"Test Todo","tags":[" Home ","home","Errands"]}`)
+	req := httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.CreateTodo(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+	}
+	if len(got) != 2 || got[0] != "home" || got[1] != "errands" {
+		t.Errorf("Expected normalized tags [home errands], got %v", got)
+	}
+}
+
+func TestUpdateTodoParentErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		repoErr  string
+		wantCode int
+	}{
+		{"own parent", `{"parent_id":1}`, "", http.StatusBadRequest},
+		{"cycle", `{"parent_id":3}`, "todo cannot be its own ancestor", http.StatusConflict},
+		{"missing parent", `{"parent_id":9}`, "parent todo not found", http.StatusBadRequest},
+		{"open subtasks", `{"status":"done"}`, "todo has open subtasks", http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockTodoRepository{
+				GetByIDFunc: func(ctx context.Context, id int) (*models.Todo, error) {
+					return &models.Todo{ID: id, Status: models.StatusTodo}, nil
+				},
+				UpdateFunc: func(ctx context.Context, id int, req *models.UpdateTodoRequest) (*models.Todo, error) {
+					return nil, errors.New(tt.repoErr)
+				},
+			}
+
+			handler := NewTodoHandler(mockRepo)
+
+			req := httptest.NewRequest("PUT", "/api/todos/1", bytes.NewBufferString(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			w := httptest.NewRecorder()
+
+			handler.UpdateTodo(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status code %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestGetTodoTree(t *testing.T) {
+	mockRepo := &MockTodoRepository{
+		GetTreeFunc: func(ctx context.Context, id int) (*models.TodoNode, error) {
+			child := &models.TodoNode{Todo: models.Todo{ID: 2, ParentID: &id}, Children: []*models.TodoNode{}}
+			return &models.TodoNode{Todo: models.Todo{ID: id}, Children: []*models.TodoNode{child}}, nil
+		},
+	}
+
+	handler := NewTodoHandler(mockRepo)
+
+	req := httptest.NewRequest("GET", "/api/todos/1/tree", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	w := httptest.NewRecorder()
+
+	handler.GetTodoTree(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var tree models.TodoNode
+	json.NewDecoder(w.Body).Decode(&tree)
+
+	if len(tree.Children) != 1 || tree.Children[0].ID != 2 {
+		t.Errorf("Unexpected tree %+v", tree)
+	}
+}
+
+func TestMoveTodo(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		repoErr  error
+		wantCode int
+	}{
+		{"after", `{"after":2}`, nil, http.StatusOK},
+		{"between", `{"after":2,"before":3}`, nil, http.StatusOK},
+		{"no anchor", `{}`, nil, http.StatusBadRequest},
+		{"relative to itself", `{"before":1}`, nil, http.StatusBadRequest},
+		{"unknown anchor", `{"before":9}`, errors.New("anchor todo not found"), http.StatusBadRequest},
+		{"unknown todo", `{"before":9}`, errors.New("todo not found"), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockTodoRepository{
+				MoveFunc: func(ctx context.Context, id int, req *models.MoveTodoRequest) (*models.Todo, error) {
+					if tt.repoErr != nil {
+						return nil, tt.repoErr
+					}
+					return &models.Todo{ID: id, Position: "V"}, nil
+				},
+			}
+
+			handler := NewTodoHandler(mockRepo)
+
+			req := httptest.NewRequest("POST", "/api/todos/1/move", bytes.NewBufferString(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			w := httptest.NewRecorder()
+
+			handler.MoveTodo(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status code %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestCreateTodoRecurrence(t *testing.T) {
+	tests := []struct {
+		name       string
+		recurrence string
+		wantCode   int
+		wantRule   string
+	}{
+		{"shorthand", "weekly", http.StatusCreated, "FREQ=WEEKLY"},
+		{"rrule", "RRULE:FREQ=WEEKLY;BYDAY=MO,TH", http.StatusCreated, "FREQ=WEEKLY;BYDAY=MO,TH"},
+		{"unsupported", "FREQ=HOURLY", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockTodoRepository{
+				CreateFunc: func(ctx context.Context, req *models.CreateTodoRequest) (*models.Todo, error) {
+					if *req.Recurrence != tt.wantRule {
+						t.Errorf("Expected rule %q, got %q", tt.wantRule, *req.Recurrence)
+					}
+					return &models.Todo{ID: 1, Title: req.Title}, nil
+				},
+			}
+
+			handler := NewTodoHandler(mockRepo)
+
+			body, _ := json.Marshal(map[string]string{"title": "Stand-up", "recurrence": tt.recurrence})
+			req := httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.CreateTodo(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status code %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
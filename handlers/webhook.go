@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"test-server/models"
+	"test-server/webhooks"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	minWebhookSecretLength = 16
+	defaultDeliveryLimit   = 100
+	maxDeliveryLimit       = 1000
+)
+
+type WebhookRepository interface {
+	CreateWebhook(context.Context, *models.CreateWebhookRequest) (*models.Webhook, error)
+	GetWebhooks(context.Context) ([]models.Webhook, error)
+	GetWebhook(context.Context, int) (*models.Webhook, error)
+	UpdateWebhook(context.Context, int, *models.UpdateWebhookRequest) (*models.Webhook, error)
+	DeleteWebhook(context.Context, int) error
+	GetDeliveries(context.Context, models.DeliveryFilter) ([]models.WebhookDelivery, error)
+	Redeliver(context.Context, int64) (*models.WebhookDelivery, error)
+}
+
+type WebhookHandler struct {
+	repo WebhookRepository
+}
+
+func NewWebhookHandler(repo WebhookRepository) *WebhookHandler {
+	return &WebhookHandler{repo: repo}
+}
+
+func validWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("URL must be an absolute http or https URL")
+	}
+	// The dispatcher checks the addresses names resolve to as it connects.
+	if err := webhooks.CheckHost(u.Hostname()); err != nil {
+		return fmt.Errorf("URL must not point at a loopback, private or link-local address")
+	}
+	return nil
+}
+
+func validWebhookEvents(events []string) error {
+	if len(events) == 0 {
+		return fmt.Errorf("At least one event is required")
+	}
+	for _, event := range events {
+		if event != models.WebhookAllEvents && !slices.Contains(models.EventTypes, event) {
+			return fmt.Errorf("Unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+func validWebhookSecret(secret string) error {
+	if len(secret) < minWebhookSecretLength {
+		return fmt.Errorf("Secret must be at least %d characters", minWebhookSecretLength)
+	}
+	return nil
+}
+
+// newWebhookSecret generates a secret for webhooks created without one.
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func respondWithWebhookError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case "webhook not found":
+		respondWithError(w, http.StatusNotFound, "Webhook not found")
+	case "delivery not found":
+		respondWithError(w, http.StatusNotFound, "Delivery not found")
+	default:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// CreateWebhook subscribes a URL to todo events. The response is the only
+// time the secret is shown; one is generated if the request has none.
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := validWebhookURL(req.URL); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validWebhookEvents(req.Events); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to generate secret")
+			return
+		}
+		req.Secret = secret
+	} else if err := validWebhookSecret(req.Secret); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook, err := h.repo.CreateWebhook(r.Context(), &req)
+	if err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, webhook)
+}
+
+func (h *WebhookHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.repo.GetWebhooks(r.Context())
+	if err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhooks)
+}
+
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	webhook, err := h.repo.GetWebhook(r.Context(), id)
+	if err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhook)
+}
+
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.URL != nil {
+		if err := validWebhookURL(*req.URL); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Events != nil {
+		if err := validWebhookEvents(*req.Events); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Secret != nil {
+		if err := validWebhookSecret(*req.Secret); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	webhook, err := h.repo.UpdateWebhook(r.Context(), id, &req)
+	if err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhook)
+}
+
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	if err := h.repo.DeleteWebhook(r.Context(), id); err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted successfully"})
+}
+
+func parseDeliveryLimit(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return defaultDeliveryLimit, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > maxDeliveryLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxDeliveryLimit)
+	}
+	return n, nil
+}
+
+// GetDeliveries serves GET /api/webhooks/{id}/deliveries, optionally
+// filtered by status.
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	filter := models.DeliveryFilter{WebhookID: &id}
+	switch status := r.URL.Query().Get("status"); status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+		filter.Status = status
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be pending, delivered or dead")
+		return
+	}
+	if filter.Limit, err = parseDeliveryLimit(r); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveries, err := h.repo.GetDeliveries(r.Context(), filter)
+	if err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// GetDeadLetters lists the tenant's dead deliveries across all webhooks.
+func (h *WebhookHandler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseDeliveryLimit(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveries, err := h.repo.GetDeliveries(r.Context(), models.DeliveryFilter{Status: models.DeliveryDead, Limit: limit})
+	if err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["deliveryID"], 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	delivery, err := h.repo.Redeliver(r.Context(), id)
+	if err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, delivery)
+}
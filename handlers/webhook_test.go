@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"test-server/models"
+
+	"github.com/gorilla/mux"
+)
+
+type MockWebhookRepository struct {
+	CreateWebhookFunc func(context.Context, *models.CreateWebhookRequest) (*models.Webhook, error)
+	GetWebhooksFunc   func(context.Context) ([]models.Webhook, error)
+	GetWebhookFunc    func(context.Context, int) (*models.Webhook, error)
+	UpdateWebhookFunc func(context.Context, int, *models.UpdateWebhookRequest) (*models.Webhook, error)
+	DeleteWebhookFunc func(context.Context, int) error
+	GetDeliveriesFunc func(context.Context, models.DeliveryFilter) ([]models.WebhookDelivery, error)
+	RedeliverFunc     func(context.Context, int64) (*models.WebhookDelivery, error)
+}
+
+func (m *MockWebhookRepository) CreateWebhook(ctx context.Context, req *models.CreateWebhookRequest) (*models.Webhook, error) {
+	if m.CreateWebhookFunc != nil {
+		return m.CreateWebhookFunc(ctx, req)
+	}
+	return &models.Webhook{ID: 1, URL: req.URL, Events: req.Events, Secret: req.Secret, Active: true}, nil
+}
+
+func (m *MockWebhookRepository) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	if m.GetWebhooksFunc != nil {
+		return m.GetWebhooksFunc(ctx)
+	}
+	return []models.Webhook{}, nil
+}
+
+func (m *MockWebhookRepository) GetWebhook(ctx context.Context, id int) (*models.Webhook, error) {
+	if m.GetWebhookFunc != nil {
+		return m.GetWebhookFunc(ctx, id)
+	}
+	return nil, fmt.Errorf("webhook not found")
+}
+
+func (m *MockWebhookRepository) UpdateWebhook(ctx context.Context, id int, req *models.UpdateWebhookRequest) (*models.Webhook, error) {
+	if m.UpdateWebhookFunc != nil {
+		return m.UpdateWebhookFunc(ctx, id, req)
+	}
+	return &models.Webhook{ID: id}, nil
+}
+
+func (m *MockWebhookRepository) DeleteWebhook(ctx context.Context, id int) error {
+	if m.DeleteWebhookFunc != nil {
+		return m.DeleteWebhookFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *MockWebhookRepository) GetDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]models.WebhookDelivery, error) {
+	if m.GetDeliveriesFunc != nil {
+		return m.GetDeliveriesFunc(ctx, filter)
+	}
+	return []models.WebhookDelivery{}, nil
+}
+
+func (m *MockWebhookRepository) Redeliver(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	if m.RedeliverFunc != nil {
+		return m.RedeliverFunc(ctx, id)
+	}
+	return &models.WebhookDelivery{ID: id, Status: models.DeliveryPending}, nil
+}
+
+func TestCreateWebhookGeneratesSecret(t *testing.T) {
+	handler := NewWebhookHandler(&MockWebhookRepository{})
+
+	body, _ := json.Marshal(models.CreateWebhookRequest{URL: "https://ci.example.com/hook", Events: []string{models.EventTodoCreated}})
+	req := httptest.NewRequest("POST", "/api/webhooks", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.CreateWebhook(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+	}
+	var webhook models.Webhook
+	json.NewDecoder(w.Body).Decode(&webhook)
+	if len(webhook.Secret) != 64 {
+		t.Errorf("Expected a generated secret, got %q", webhook.Secret)
+	}
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		req  models.CreateWebhookRequest
+	}{
+		{"relative URL", models.CreateWebhookRequest{URL: "/hook", Events: []string{"*"}}},
+		{"ftp URL", models.CreateWebhookRequest{URL: "ftp://example.com", Events: []string{"*"}}},
+		{"loopback URL", models.CreateWebhookRequest{URL: "http://127.0.0.1:8080/hook", Events: []string{"*"}}},
+		{"localhost URL", models.CreateWebhookRequest{URL: "http://localhost/hook", Events: []string{"*"}}},
+		{"metadata URL", models.CreateWebhookRequest{URL: "http://169.254.169.254/latest/meta-data", Events: []string{"*"}}},
+		{"private URL", models.CreateWebhookRequest{URL: "https://10.0.0.5/hook", Events: []string{"*"}}},
+		{"no events", models.CreateWebhookRequest{URL: "https://example.com"}},
+		{"unknown event", models.CreateWebhookRequest{URL: "https://example.com", Events: []string{"todo.renamed"}}},
+		{"short secret", models.CreateWebhookRequest{URL: "https://example.com", Events: []string{"*"}, Secret: "abc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewWebhookHandler(&MockWebhookRepository{})
+
+			body, _ := json.Marshal(tt.req)
+			req := httptest.NewRequest("POST", "/api/webhooks", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.CreateWebhook(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestGetDeadLetters(t *testing.T) {
+	var got models.DeliveryFilter
+	mockRepo := &MockWebhookRepository{
+		GetDeliveriesFunc: func(ctx context.Context, filter models.DeliveryFilter) ([]models.WebhookDelivery, error) {
+			got = filter
+			return []models.WebhookDelivery{}, nil
+		},
+	}
+
+	handler := NewWebhookHandler(mockRepo)
+
+	req := httptest.NewRequest("GET", "/api/webhooks/dead-letters?limit=10", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetDeadLetters(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if got.Status != models.DeliveryDead || got.WebhookID != nil || got.Limit != 10 {
+		t.Errorf("Unexpected filter %+v", got)
+	}
+}
+
+func TestRedeliverNotFound(t *testing.T) {
+	mockRepo := &MockWebhookRepository{
+		RedeliverFunc: func(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+			return nil, fmt.Errorf("delivery not found")
+		},
+	}
+
+	handler := NewWebhookHandler(mockRepo)
+
+	req := httptest.NewRequest("POST", "/api/webhooks/deliveries/9/redeliver", nil)
+	req = mux.SetURLVars(req, map[string]string{"deliveryID": "9"})
+	w := httptest.NewRecorder()
+
+	handler.Redeliver(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
-	"net/http"
-	"os"
+	"time"
 
+	"test-server/cache"
 	"test-server/database"
+	"test-server/outbox"
+	"test-server/reminders"
 	"test-server/repository"
 	"test-server/routes"
+	"test-server/server"
+	"test-server/tenant"
+	"test-server/webhooks"
 )
 
 func main() {
@@ -27,27 +33,80 @@ func main() {
 	defer database.CloseDB()
 
 	// Create tables
-	if err := database.CreateTodoTable(); err != nil {
+	if err := database.CreateTables(); err != nil {
 		log.Fatalf("Failed to create tables: %v", err)
 	}
 
+	// Tenant quotas
+	quotas, err := tenant.ParseQuotas(getEnvInt("TENANT_MAX_TODOS", 0), getEnv("TENANT_QUOTAS", ""))
+	if err != nil {
+		log.Fatalf("Failed to parse tenant quotas: %v", err)
+	}
+
 	// Initialize repository
-	todoRepo := repository.NewTodoRepository(database.DB)
+	todoRepo := repository.NewTodoRepository(database.DB).WithQuotas(quotas).
+		WithCompletionRules(repository.CompletionRules{
+			AutoCompleteParents: getEnvBool("SUBTASK_AUTO_COMPLETE", true),
+			BlockOpenChildren:   getEnvBool("SUBTASK_BLOCK_OPEN", false),
+		})
+	if getEnv("SEARCH_BACKEND", "fulltext") == "like" {
+		todoRepo.WithSearchMode(repository.SearchLike)
+	}
+
+	var todoCache *cache.TodoRepository
+	if size := getEnvInt("CACHE_SIZE", 10000); size > 0 {
+		todoCache = cache.NewTodoRepository(todoRepo, cache.NewLRU(size), getEnvDuration("CACHE_TTL", 30*time.Second))
+	}
+
+	// Start reminder scheduler
+	var reminderStore reminders.Store = todoRepo
+	if todoCache != nil {
+		reminderStore = todoCache.Reminders(todoRepo)
+	}
+	scheduler := reminders.NewScheduler(reminderStore, reminders.LogNotifier{})
+	todoRepo.WithReminderHook(scheduler.Wake)
+	go scheduler.Run(context.Background())
+
+	// Start webhook dispatcher
+	dispatcher := webhooks.NewDispatcher(todoRepo, nil)
+	dispatcher.MaxAttempts = getEnvInt("WEBHOOK_MAX_ATTEMPTS", dispatcher.MaxAttempts)
+	dispatcher.BaseBackoff = time.Duration(getEnvInt("WEBHOOK_BACKOFF_SECONDS", 30)) * time.Second
+	todoRepo.WithDeliveryHook(dispatcher.Wake)
+	go dispatcher.Run(context.Background())
+
+	// Start outbox relay
+	bus := outbox.NewBus()
+	publishers := outbox.Publishers{dispatcher, bus}
+	if getEnvBool("LOG_EVENTS", false) {
+		publishers = append(publishers, outbox.LogPublisher{})
+	}
+	relay := outbox.NewRelay(todoRepo, publishers)
+	todoRepo.WithOutboxHook(relay.Wake)
+	go relay.Run(context.Background())
 
 	// Setup routes
-	router := routes.SetupRouter(todoRepo)
+	routerConfig, err := loadRouterConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	routerConfig.Events = bus
+	if todoCache != nil {
+		go todoCache.Watch(context.Background(), bus)
+		routerConfig.Cache = todoCache
+	}
+	router := routes.SetupRouter(todoRepo, routerConfig)
 
 	// Start server
-	port := getEnv("PORT", "8080")
-	log.Printf("Server starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv, err := server.New(loadServerConfig(), router)
+	if err != nil {
+		log.Fatalf("Failed to configure server: %v", err)
 	}
-}
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	scheme := "http"
+	if srv.TLSEnabled() {
+		scheme = "https"
+	}
+	log.Printf("Server starting on port %s (%s)", getEnv("PORT", "8080"), scheme)
+	if err := srv.ListenAndServe(); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
 	}
-	return defaultValue
 }
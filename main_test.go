@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -34,7 +35,7 @@ func setupTestDB(t *testing.T) {
 		t.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	if err := database.CreateTodoTable(); err != nil {
+	if err := database.CreateTables(); err != nil {
 		t.Fatalf("Failed to create tables: %v", err)
 	}
 
@@ -81,7 +82,7 @@ func TestIntegrationCreateTodo(t *testing.T) {
 	defer teardownTestDB(t)
 
 	repo := repository.NewTodoRepository(database.DB)
-	router := routes.SetupRouter(repo)
+	router := routes.SetupRouter(repo, routes.DefaultConfig())
 
 	reqBody := models.CreateTodoRequest{
 		Title:       "Integration Test Todo",
@@ -112,11 +113,11 @@ func TestIntegrationGetAllTodos(t *testing.T) {
 	defer teardownTestDB(t)
 
 	repo := repository.NewTodoRepository(database.DB)
-	router := routes.SetupRouter(repo)
+	router := routes.SetupRouter(repo, routes.DefaultConfig())
 
 	// Create test todos
-	repo.Create(&models.CreateTodoRequest{Title: "Todo 1", Description: "Desc 1"})
-	repo.Create(&models.CreateTodoRequest{Title: "Todo 2", Description: "Desc 2"})
+	repo.Create(context.Background(), &models.CreateTodoRequest{Title: "Todo 1", Description: "Desc 1"})
+	repo.Create(context.Background(), &models.CreateTodoRequest{Title: "Todo 2", Description: "Desc 2"})
 
 	req := httptest.NewRequest("GET", "/api/todos", nil)
 	w := httptest.NewRecorder()
@@ -137,10 +138,10 @@ func TestIntegrationGetTodoByID(t *testing.T) {
 	defer teardownTestDB(t)
 
 	repo := repository.NewTodoRepository(database.DB)
-	router := routes.SetupRouter(repo)
+	router := routes.SetupRouter(repo, routes.DefaultConfig())
 
 	// Create a todo
-	created, _ := repo.Create(&models.CreateTodoRequest{
+	created, _ := repo.Create(context.Background(), &models.CreateTodoRequest{
 		Title:       "Test Todo",
 		Description: "Test Description",
 	})
@@ -159,6 +160,37 @@ func TestIntegrationGetTodoByID(t *testing.T) {
 	assert.Equal(t, created.Title, todo.Title)
 }
 
+func TestIntegrationOpposingReparentsCannotFormCycle(t *testing.T) {
+	startKeploySession(t, "TestIntegrationOpposingReparentsCannotFormCycle")
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	repo := repository.NewTodoRepository(database.DB)
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		a, _ := repo.Create(ctx, &models.CreateTodoRequest{Title: "A"})
+		b, _ := repo.Create(ctx, &models.CreateTodoRequest{Title: "B"})
+
+		// Move A under B and B under A at the same time; at most one may win.
+		var wg sync.WaitGroup
+		move := func(id, parentID int) {
+			defer wg.Done()
+			repo.Update(ctx, id, &models.UpdateTodoRequest{ParentID: &parentID})
+		}
+		wg.Add(2)
+		go move(a.ID, b.ID)
+		go move(b.ID, a.ID)
+		wg.Wait()
+
+		a, _ = repo.GetByID(ctx, a.ID)
+		b, _ = repo.GetByID(ctx, b.ID)
+		if a.ParentID != nil && b.ParentID != nil {
+			t.Fatalf("Todos %d and %d are each other's parent", a.ID, b.ID)
+		}
+	}
+}
+
 func TestExternalHTTPSCall(t *testing.T) {
 	startKeploySession(t, "TestExternalHTTPSCall")
 
@@ -240,10 +272,10 @@ func TestMySQLHealth(t *testing.T) {
 // 	defer teardownTestDB(t)
 
 // 	repo := repository.NewTodoRepository(database.DB)
-// 	router := routes.SetupRouter(repo)
+// 	router := routes.SetupRouter(repo, routes.DefaultConfig())
 
 // 	// Create a todo
-// 	created, _ := repo.Create(&models.CreateTodoRequest{
+// 	created, _ := repo.Create(context.Background(), &models.CreateTodoRequest{
 // 		Title:       "Original Title",
 // 		Description: "Original Description",
 // 	})
@@ -277,10 +309,10 @@ func TestMySQLHealth(t *testing.T) {
 // 	defer teardownTestDB(t)
 
 // 	repo := repository.NewTodoRepository(database.DB)
-// 	router := routes.SetupRouter(repo)
+// 	router := routes.SetupRouter(repo, routes.DefaultConfig())
 
 // 	// Create a todo
-// 	created, _ := repo.Create(&models.CreateTodoRequest{
+// 	created, _ := repo.Create(context.Background(), &models.CreateTodoRequest{
 // 		Title:       "To Be Deleted",
 // 		Description: "This will be deleted",
 // 	})
@@ -294,7 +326,7 @@ func TestMySQLHealth(t *testing.T) {
 // 	assert.Equal(t, http.StatusOK, w.Code)
 
 // 	// Verify it's deleted
-// 	_, err := repo.GetByID(created.ID)
+// 	_, err := repo.GetByID(context.Background(), created.ID)
 // 	assert.Error(t, err)
 // }
 
@@ -303,7 +335,7 @@ func TestMySQLHealth(t *testing.T) {
 // 	defer teardownTestDB(t)
 
 // 	repo := repository.NewTodoRepository(database.DB)
-// 	router := routes.SetupRouter(repo)
+// 	router := routes.SetupRouter(repo, routes.DefaultConfig())
 
 // 	// 1. Create a todo
 // 	createReq := models.CreateTodoRequest{
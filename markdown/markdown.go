@@ -0,0 +1,209 @@
+// Package markdown renders the Markdown subset used in comments to HTML
+// that is safe to embed in a page.
+//
+// Raw HTML in the source is always escaped rather than filtered, and links
+// are only emitted for http, https, mailto and relative URLs, so the output
+// needs no further sanitising. Supported are paragraphs, ATX headings,
+// fenced code blocks, block quotes, flat ordered and unordered lists,
+// horizontal rules, emphasis, strong emphasis, inline code and links.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingLine   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	unorderedItem = regexp.MustCompile(`^\s{0,3}[-*+]\s+(.*)$`)
+	orderedItem   = regexp.MustCompile(`^\s{0,3}\d{1,9}[.)]\s+(.*)$`)
+	ruleLine      = regexp.MustCompile(`^\s{0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	fenceLine     = regexp.MustCompile("^\\s{0,3}```\\s*([A-Za-z0-9_+-]*)\\s*$")
+	quoteLine     = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+)
+
+// Render converts src to HTML.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	var out strings.Builder
+	renderBlocks(&out, strings.Split(src, "\n"))
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+func renderBlocks(out *strings.Builder, lines []string) {
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + renderInline(strings.Join(paragraph, "\n")) + "</p>\n")
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		if m := fenceLine.FindStringSubmatch(line); m != nil {
+			flush()
+			var code []string
+			for i++; i < len(lines) && !fenceLine.MatchString(lines[i]); i++ {
+				code = append(code, lines[i])
+			}
+			class := ""
+			if m[1] != "" {
+				class = ` class="language-` + m[1] + `"`
+			}
+			out.WriteString("<pre><code" + class + ">" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+			continue
+		}
+
+		if m := headingLine.FindStringSubmatch(line); m != nil {
+			flush()
+			level := string(rune('0' + len(m[1])))
+			out.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			continue
+		}
+
+		if ruleLine.MatchString(line) {
+			flush()
+			out.WriteString("<hr>\n")
+			continue
+		}
+
+		if quoteLine.MatchString(line) {
+			flush()
+			var quoted []string
+			for ; i < len(lines); i++ {
+				m := quoteLine.FindStringSubmatch(lines[i])
+				if m == nil {
+					break
+				}
+				quoted = append(quoted, m[1])
+			}
+			i--
+			out.WriteString("<blockquote>\n")
+			renderBlocks(out, quoted)
+			out.WriteString("</blockquote>\n")
+			continue
+		}
+
+		if list, _ := listKind(line); list != "" {
+			flush()
+			out.WriteString("<" + list + ">\n")
+			for ; i < len(lines); i++ {
+				kind, text := listKind(lines[i])
+				if kind != list {
+					break
+				}
+				out.WriteString("<li>" + renderInline(text) + "</li>\n")
+			}
+			i--
+			out.WriteString("</" + list + ">\n")
+			continue
+		}
+
+		paragraph = append(paragraph, strings.TrimSpace(line))
+	}
+	flush()
+}
+
+func listKind(line string) (string, string) {
+	if ruleLine.MatchString(line) {
+		return "", ""
+	}
+	if m := unorderedItem.FindStringSubmatch(line); m != nil {
+		return "ul", m[1]
+	}
+	if m := orderedItem.FindStringSubmatch(line); m != nil {
+		return "ol", m[1]
+	}
+	return "", ""
+}
+
+func renderInline(text string) string {
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte("\\`*_[]()#+-.!>", text[i+1]) >= 0:
+			out.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if end := strings.IndexByte(text[i+1:], '`'); end >= 0 {
+				out.WriteString("<code>" + html.EscapeString(text[i+1:i+1+end]) + "</code>")
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(text[i:], "**") || strings.HasPrefix(text[i:], "__"):
+			delim := text[i : i+2]
+			if end := strings.Index(text[i+2:], delim); end > 0 {
+				out.WriteString("<strong>" + renderInline(text[i+2:i+2+end]) + "</strong>")
+				i += end + 4
+				continue
+			}
+
+		case c == '*' || c == '_':
+			if end := strings.IndexByte(text[i+1:], c); end > 0 {
+				out.WriteString("<em>" + renderInline(text[i+1:i+1+end]) + "</em>")
+				i += end + 2
+				continue
+			}
+
+		case c == '[':
+			if label, href, n, ok := parseLink(text[i:]); ok {
+				if safeURL(href) {
+					out.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener">` + renderInline(label) + "</a>")
+				} else {
+					out.WriteString(renderInline(label))
+				}
+				i += n
+				continue
+			}
+		}
+
+		out.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+	return out.String()
+}
+
+// parseLink matches "[label](href)" at the start of text and returns the
+// number of bytes it spans.
+func parseLink(text string) (string, string, int, bool) {
+	closeLabel := strings.Index(text, "](")
+	if closeLabel < 0 {
+		return "", "", 0, false
+	}
+	closeHref := strings.IndexByte(text[closeLabel+2:], ')')
+	if closeHref < 0 {
+		return "", "", 0, false
+	}
+	href := strings.TrimSpace(text[closeLabel+2 : closeLabel+2+closeHref])
+	return text[1:closeLabel], href, closeLabel + 3 + closeHref, true
+}
+
+func safeURL(href string) bool {
+	if href == "" || strings.ContainsAny(href, " \t\n\"'<>") {
+		return false
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return true
+	case "":
+		// Relative URLs only; "//host" would leave the site.
+		return !strings.HasPrefix(href, "//")
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package markdown
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one\ntwo</p>\n<p>three</p>"},
+		{"heading", "## Plan ##", "<h2>Plan</h2>"},
+		{"emphasis", "*a* **b** `c*d`", "<p><em>a</em> <strong>b</strong> <code>c*d</code></p>"},
+		{"escape", `\*not em\*`, "<p>*not em*</p>"},
+		{"lists", "- a\n- b\n1. c", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol>\n<li>c</li>\n</ol>"},
+		{"quote", "> **note**\n> more", "<blockquote>\n<p><strong>note</strong>\nmore</p>\n</blockquote>"},
+		{"rule", "a\n\n---", "<p>a</p>\n<hr>"},
+		{"code block", "```go\nif a < b {}\n```", "<pre><code class=\"language-go\">if a &lt; b {}</code></pre>"},
+		{"link", "[docs](https://example.com/?a=1&b=2)",
+			`<p><a href="https://example.com/?a=1&amp;b=2" rel="nofollow noopener">docs</a></p>`},
+	}
+
+	for _, tt := range tests {
+		if got := Render(tt.in); got != tt.want {
+			t.Errorf("%s: Render(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderSanitises(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{`<img src=x onerror="alert(1)">`, "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>"},
+		{"[click](javascript:alert(1))", "<p>click)</p>"},
+		{"[click](//evil.example)", "<p>click</p>"},
+		{`[x](https://a.example/" onclick="y)`, "<p>x</p>"},
+	}
+
+	for _, tt := range tests {
+		if got := Render(tt.in); got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"test-server/auth"
+)
+
+// Authenticate verifies an optional "Authorization: Bearer" token and stores
+// its claims on the request context. Requests without a token pass through
+// unauthenticated; requests with an invalid token are rejected. An empty
+// secret disables token handling entirely.
+func Authenticate(secret []byte) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if len(secret) == 0 || header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				respondWithError(w, http.StatusUnauthorized, "Invalid authorization header")
+				return
+			}
+
+			claims, err := auth.ParseToken(strings.TrimSpace(token), secret)
+			if err != nil {
+				respondWithError(w, http.StatusUnauthorized, "Invalid token")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// RequireRole only lets through requests whose token grants role.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := auth.FromContext(r.Context())
+			if !ok {
+				respondWithError(w, http.StatusUnauthorized, "Authentication required")
+				return
+			}
+			if !claims.HasRole(role) {
+				respondWithError(w, http.StatusForbidden, "Insufficient permissions")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"test-server/auth"
+)
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   auth.Claims
+		wantCode int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"without role", auth.Claims{"sub": "alice"}, http.StatusForbidden},
+		{"with role", auth.Claims{"sub": "alice", "role": "admin"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RequireRole("admin")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/api/audit", nil)
+			if tt.claims != nil {
+				req = req.WithContext(auth.WithClaims(req.Context(), tt.claims))
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status code %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionConfig controls response compression and the decoding of
+// compressed request bodies.
+type CompressionConfig struct {
+	Disabled bool
+	// Level is a compress/flate level; 0 selects the default.
+	Level int
+	// MinSize is the smallest body worth compressing.
+	MinSize int
+	// MaxRequestBody bounds decoded request bodies, against compression
+	// bombs.
+	MaxRequestBody int64
+}
+
+// compressor is what the gzip, zlib, brotli and zstd writers have in
+// common.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// encoding is a content coding the server can produce and accept.
+type encoding struct {
+	name      string
+	writers   sync.Pool
+	newReader func(io.Reader) (io.ReadCloser, error)
+}
+
+// maxZstdWindow bounds the window a zstd request body may ask the decoder
+// to allocate.
+const maxZstdWindow = 8 << 20
+
+// newEncodings lists the supported codings in order of preference: zstd
+// and brotli compress JSON better than gzip, zstd the faster of the two.
+// HTTP's "deflate" is the zlib format. The level applies to gzip and
+// deflate; brotli and zstd use their own defaults.
+func newEncodings(level int) []*encoding {
+	return []*encoding{
+		{
+			name: "zstd",
+			writers: sync.Pool{New: func() interface{} {
+				// Each response is compressed by the goroutine serving it.
+				w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+				return w
+			}},
+			newReader: func(r io.Reader) (io.ReadCloser, error) {
+				d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxZstdWindow))
+				if err != nil {
+					return nil, err
+				}
+				return d.IOReadCloser(), nil
+			},
+		},
+		{
+			name: "br",
+			writers: sync.Pool{New: func() interface{} {
+				return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+			}},
+			newReader: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(brotli.NewReader(r)), nil },
+		},
+		{
+			name: "gzip",
+			writers: sync.Pool{New: func() interface{} {
+				w, _ := gzip.NewWriterLevel(nil, level)
+				return w
+			}},
+			newReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		},
+		{
+			name: "deflate",
+			writers: sync.Pool{New: func() interface{} {
+				w, _ := zlib.NewWriterLevel(nil, level)
+				return w
+			}},
+			newReader: zlib.NewReader,
+		},
+	}
+}
+
+// Compress compresses responses with the best coding the client accepts,
+// leaving alone small bodies, types that are already compressed, event
+// streams and protocol upgrades. It also decodes request bodies sent with a
+// Content-Encoding it supports and rejects the others with 415.
+func Compress(cfg CompressionConfig) func(http.Handler) http.Handler {
+	if cfg.Level == 0 {
+		cfg.Level = gzip.DefaultCompression
+	}
+	if cfg.MinSize == 0 {
+		cfg.MinSize = 1024
+	}
+	if cfg.MaxRequestBody == 0 {
+		cfg.MaxRequestBody = 10 << 20
+	}
+	encodings := newEncodings(cfg.Level)
+
+	return func(next http.Handler) http.Handler {
+		if cfg.Disabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if coding := r.Header.Get("Content-Encoding"); coding != "" && !strings.EqualFold(coding, "identity") {
+				enc := findEncoding(encodings, coding)
+				if enc == nil {
+					w.Header().Set("Accept-Encoding", encodingNames(encodings))
+					respondWithError(w, http.StatusUnsupportedMediaType, "Unsupported Content-Encoding")
+					return
+				}
+				body, err := enc.newReader(r.Body)
+				if err != nil {
+					respondWithError(w, http.StatusBadRequest, "Invalid "+enc.name+" request body")
+					return
+				}
+				defer body.Close()
+				r.Body = http.MaxBytesReader(w, body, cfg.MaxRequestBody)
+				r.Header.Del("Content-Encoding")
+				r.Header.Del("Content-Length")
+				r.ContentLength = -1
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+			enc := negotiateEncoding(encodings, r.Header.Get("Accept-Encoding"))
+			if enc == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, enc: enc, minSize: cfg.MinSize}
+			defer cw.finish()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+func findEncoding(encodings []*encoding, name string) *encoding {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, enc := range encodings {
+		if enc.name == name {
+			return enc
+		}
+	}
+	return nil
+}
+
+func encodingNames(encodings []*encoding) string {
+	names := make([]string, len(encodings))
+	for i, enc := range encodings {
+		names[i] = enc.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// negotiateEncoding picks the supported coding with the highest q-value in
+// an Accept-Encoding header, preferring earlier codings on ties. It returns
+// nil when the client accepts none of them.
+func negotiateEncoding(encodings []*encoding, header string) *encoding {
+	if header == "" {
+		return nil
+	}
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if raw, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				parsed = 0
+			}
+			q = parsed
+		}
+		weights[name] = q
+	}
+
+	var best *encoding
+	bestQ := 0.0
+	for _, enc := range encodings {
+		q, ok := weights[enc.name]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether a content type is worth compressing. Images,
+// archives and other binary types are usually compressed already, and event
+// streams must reach the client as soon as they are written.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/x-ndjson", "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// compressWriter holds back the start of a response until it knows whether
+// the body is large enough, and of a suitable type, to compress.
+type compressWriter struct {
+	http.ResponseWriter
+	enc     *encoding
+	minSize int
+
+	status  int
+	buf     []byte
+	decided bool
+	cw      compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	if code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		w.start(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) >= w.minSize {
+			if err := w.start(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+	if w.cw != nil {
+		return w.cw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// start sends the header, compressing if the body is big enough and of a
+// compressible type, then writes what was held back.
+func (w *compressWriter) start(bigEnough bool) error {
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if bigEnough && header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", w.enc.name)
+		header.Del("Content-Length")
+		w.cw = w.enc.writers.Get().(compressor)
+		w.cw.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush sends what has been written so far. A response that is flushed is
+// being streamed, so it is compressed regardless of its size so far.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.start(true)
+	}
+	if w.cw != nil {
+		w.cw.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if w.status == 0 {
+			// Nothing was written; let the server send its default.
+			return
+		}
+		w.start(false)
+	}
+	if w.cw != nil {
+		w.cw.Close()
+		w.cw.Reset(io.Discard)
+		w.enc.writers.Put(w.cw)
+		w.cw = nil
+	}
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
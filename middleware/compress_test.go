@@ -0,0 +1,256 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func bodyHandler(contentType, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, body)
+	})
+}
+
+func compressedGet(handler http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/api/todos", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	Compress(CompressionConfig{})(handler).ServeHTTP(w, req)
+	return w
+}
+
+func TestCompressGzipsLargeJSON(t *testing.T) {
+	body := `[` + strings.Repeat(`{"title":"Buy milk"},`, 200) + `{}]`
+	w := compressedGet(bodyHandler("application/json", body), "br;q=0.5, gzip")
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", w.Header().Get("Vary"))
+	}
+	r, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Invalid gzip body: %v", err)
+	}
+	decoded, _ := io.ReadAll(r)
+	if string(decoded) != body {
+		t.Error("Expected the decoded body to match")
+	}
+	if w.Body.Len() >= len(body) {
+		t.Errorf("Expected a smaller body, got %d bytes for %d", w.Body.Len(), len(body))
+	}
+}
+
+func TestCompressSkipsSmallAndCompressedBodies(t *testing.T) {
+	large := strings.Repeat("x", 4096)
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"small body", "application/json", `{"ok":true}`},
+		{"image", "image/png", large},
+		{"event stream", "text/event-stream", large},
+	}
+	for _, tt := range tests {
+		w := compressedGet(bodyHandler(tt.contentType, tt.body), "gzip")
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s: expected no compression", tt.name)
+		}
+		if w.Body.String() != tt.body {
+			t.Errorf("%s: expected the body unchanged", tt.name)
+		}
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	encodings := newEncodings(gzip.DefaultCompression)
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip, deflate", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0, *", "zstd"},
+		{"*;q=0", ""},
+		{"br;q=1.0, GZIP;q=0.8", "br"},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"gzip, deflate, br", "br"},
+		{"zstd;q=0.1, br;q=0.1, gzip;q=0.2", "gzip"},
+	}
+	for _, tt := range tests {
+		got := ""
+		if enc := negotiateEncoding(encodings, tt.header); enc != nil {
+			got = enc.name
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.header, tt.want, got)
+		}
+	}
+}
+
+func TestCompressDeflateUsesZlibFormat(t *testing.T) {
+	body := strings.Repeat("todo ", 500)
+	w := compressedGet(bodyHandler("text/plain", body), "deflate")
+
+	if w.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("Expected deflate, got %q", w.Header().Get("Content-Encoding"))
+	}
+	r, err := zlib.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Invalid zlib body: %v", err)
+	}
+	decoded, _ := io.ReadAll(r)
+	if string(decoded) != body {
+		t.Error("Expected the decoded body to match")
+	}
+}
+
+// decodeBody decodes body with the reference reader for coding.
+func decodeBody(t *testing.T, coding string, body io.Reader) string {
+	t.Helper()
+	var r io.Reader
+	var err error
+	switch coding {
+	case "gzip":
+		r, err = gzip.NewReader(body)
+	case "deflate":
+		r, err = zlib.NewReader(body)
+	case "br":
+		r = brotli.NewReader(body)
+	case "zstd":
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(body)
+		if err == nil {
+			defer d.Close()
+			r = d
+		}
+	}
+	if err != nil {
+		t.Fatalf("Invalid %s body: %v", coding, err)
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Invalid %s body: %v", coding, err)
+	}
+	return string(decoded)
+}
+
+func TestCompressRoundTripsEveryCoding(t *testing.T) {
+	body := `[` + strings.Repeat(`{"title":"Buy milk"},`, 200) + `{}]`
+	chunks := []string{"{\"id\":1}\n", "{\"id\":2}\n", strings.Repeat("{\"id\":3}\n", 300)}
+	streamed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, chunk := range chunks {
+			io.WriteString(w, chunk)
+			http.NewResponseController(w).Flush()
+		}
+	})
+
+	for _, coding := range []string{"zstd", "br", "gzip", "deflate"} {
+		w := compressedGet(bodyHandler("application/json", body), coding)
+		if w.Header().Get("Content-Encoding") != coding {
+			t.Errorf("Expected %s, got %q", coding, w.Header().Get("Content-Encoding"))
+			continue
+		}
+		if w.Body.Len() >= len(body) {
+			t.Errorf("%s: expected a smaller body, got %d bytes for %d", coding, w.Body.Len(), len(body))
+		}
+		if decoded := decodeBody(t, coding, w.Body); decoded != body {
+			t.Errorf("%s: expected the decoded body to match", coding)
+		}
+
+		// Every flush must leave the stream decodable up to that point.
+		w = compressedGet(streamed, coding)
+		if w.Header().Get("Content-Encoding") != coding || !w.Flushed {
+			t.Errorf("Expected a flushed %s stream, got %q", coding, w.Header().Get("Content-Encoding"))
+			continue
+		}
+		if decoded := decodeBody(t, coding, w.Body); decoded != strings.Join(chunks, "") {
+			t.Errorf("%s: unexpected stream of %d bytes", coding, len(decoded))
+		}
+	}
+}
+
+func TestCompressFlushStreamsSmallChunks(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, "{\"id\":1}\n")
+		http.NewResponseController(w).Flush()
+		io.WriteString(w, "{\"id\":2}\n")
+	})
+	w := compressedGet(handler, "gzip")
+
+	if w.Header().Get("Content-Encoding") != "gzip" || !w.Flushed {
+		t.Fatalf("Expected a flushed gzip stream, got %q", w.Header().Get("Content-Encoding"))
+	}
+	r, _ := gzip.NewReader(w.Body)
+	decoded, _ := io.ReadAll(r)
+	if string(decoded) != "{\"id\":1}\n{\"id\":2}\n" {
+		t.Errorf("Unexpected stream %q", decoded)
+	}
+}
+
+func TestCompressDecodesRequestBodies(t *testing.T) {
+	var got string
+	handler := Compress(CompressionConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	}))
+
+	encoders := map[string]func(io.Writer) io.WriteCloser{
+		"gzip": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"br":   func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) },
+		"zstd": func(w io.Writer) io.WriteCloser { e, _ := zstd.NewWriter(w); return e },
+	}
+	for coding, newWriter := range encoders {
+		var buf bytes.Buffer
+		enc := newWriter(&buf)
+		io.WriteString(enc, `{"title":"Milk"}`)
+		enc.Close()
+
+		got = ""
+		req := httptest.NewRequest("POST", "/api/todos", &buf)
+		req.Header.Set("Content-Encoding", coding)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if got != `{"title":"Milk"}` {
+			t.Errorf("%s: expected the decoded body, got %q", coding, got)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/todos", strings.NewReader("..."))
+	req.Header.Set("Content-Encoding", "compress")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnsupportedMediaType || w.Header().Get("Accept-Encoding") != "zstd, br, gzip, deflate" {
+		t.Errorf("Expected 415 listing the supported codings, got %d %q", w.Code, w.Header().Get("Accept-Encoding"))
+	}
+}
+
+func TestCompressLeavesNotModifiedAlone(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `W/"1"`)
+		w.WriteHeader(http.StatusNotModified)
+	})
+	w := compressedGet(handler, "gzip")
+
+	if w.Code != http.StatusNotModified || w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 0 {
+		t.Errorf("Expected a bare 304, got %d %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func corsTestConfig() CORSConfig {
+	cfg := DefaultCORSConfig()
+	cfg.AllowedOrigins = []string{"https://app.example.com", "https://*.preview.example.com"}
+	return cfg
+}
+
+func TestCORSPreflight(t *testing.T) {
+	handler := CORS(corsTestConfig())(http.HandlerFunc(okHandler))
+
+	req := httptest.NewRequest("OPTIONS", "/api/todos", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status code %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Expected allowed origin, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST, PUT, DELETE" {
+		t.Errorf("Unexpected allowed methods %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Expected max age 600, got %q", got)
+	}
+}
+
+func TestCORSWildcardSubdomain(t *testing.T) {
+	handler := CORS(corsTestConfig())(http.HandlerFunc(okHandler))
+
+	req := httptest.NewRequest("GET", "/api/todos", nil)
+	req.Header.Set("Origin", "https://pr-12.preview.example.com")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://pr-12.preview.example.com" {
+		t.Errorf("Expected allowed origin, got %q", got)
+	}
+	if w.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Error("Expected exposed headers on actual request")
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	handler := CORS(corsTestConfig())(http.HandlerFunc(okHandler))
+
+	req := httptest.NewRequest("GET", "/api/todos", nil)
+	req.Header.Set("Origin", "https://evil.example.org")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no allowed origin, got %q", got)
+	}
+}
+
+func TestCORSCredentialsEchoOrigin(t *testing.T) {
+	cfg := DefaultCORSConfig()
+	cfg.AllowedOrigins = []string{"https://*.example.com"}
+	cfg.AllowCredentials = true
+	handler := CORS(cfg)(http.HandlerFunc(okHandler))
+
+	req := httptest.NewRequest("GET", "/api/todos", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Expected echoed origin, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Expected credentials allowed, got %q", got)
+	}
+}
+
+func TestCORSValidateRejectsAnyOriginWithCredentials(t *testing.T) {
+	tests := []struct {
+		origins     []string
+		credentials bool
+		valid       bool
+	}{
+		{[]string{"*"}, false, true},
+		{[]string{"*"}, true, false},
+		{[]string{"https://app.example.com", "https://*"}, true, false},
+		{[]string{"https://*.example.com"}, true, true},
+		{[]string{"https://app.example.com"}, true, true},
+	}
+
+	for _, tt := range tests {
+		cfg := DefaultCORSConfig()
+		cfg.AllowedOrigins = tt.origins
+		cfg.AllowCredentials = tt.credentials
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%v with credentials %v: expected valid %v, got %v", tt.origins, tt.credentials, tt.valid, err)
+		}
+	}
+}
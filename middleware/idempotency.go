@@ -0,0 +1,381 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"test-server/tenant"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// IdempotentResponse is a stored response: the status, the headers the
+// handler set and the body.
+type IdempotentResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// IdempotencyRecord is what a store holds for a key. Response is nil while
+// the first request is still in flight.
+type IdempotencyRecord struct {
+	Fingerprint string
+	Response    *IdempotentResponse
+}
+
+// IdempotencyStore remembers responses by key. The in-process
+// MemoryIdempotencyStore can be replaced with a shared implementation so
+// that retries reaching another instance are recognised too.
+type IdempotencyStore interface {
+	// Reserve claims key for a request with fingerprint for up to lock. If
+	// the key is taken, it returns the existing record instead.
+	Reserve(ctx context.Context, key, fingerprint string, lock time.Duration) (*IdempotencyRecord, error)
+	// Save stores the response for key, releasing the reservation.
+	Save(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error
+	// Release drops a reservation so that the request can be retried.
+	Release(ctx context.Context, key string) error
+	// Extend holds a reservation for another lock while its request is
+	// still running.
+	Extend(ctx context.Context, key string, lock time.Duration) error
+}
+
+type IdempotencyConfig struct {
+	Header string
+	// TTL is how long responses are replayed. Lock is how long a request
+	// holds its key without renewing it; running requests renew it every
+	// half Lock, so only one whose instance has gone away lets it lapse.
+	TTL     time.Duration
+	Lock    time.Duration
+	Methods []string
+	// MaxBody bounds the request bodies read for fingerprinting, 1 MiB by
+	// default. MaxBodies overrides it per route, keyed like
+	// RateLimitConfig.Routes, for routes that take larger uploads.
+	MaxBody   int64
+	MaxBodies map[string]int64
+	// APIKeyHeader, APIKeys and TrustProxy identify callers without a
+	// token, as for rate limiting.
+	APIKeyHeader string
+	APIKeys      []string
+	TrustProxy   bool
+	Store        IdempotencyStore
+}
+
+func (c IdempotencyConfig) maxBodyFor(route string) int64 {
+	if limit, ok := c.MaxBodies[route]; ok {
+		return limit
+	}
+	return c.MaxBody
+}
+
+// Idempotency replays the stored response when a request is retried with
+// the same Idempotency-Key. Keys are scoped to the tenant and the caller; a
+// key reused for a different request is rejected with 422, and a retry
+// while the first request is still running with 409. Server errors are not
+// stored, so that they can be retried.
+func Idempotency(cfg IdempotencyConfig) func(http.Handler) http.Handler {
+	if cfg.Header == "" {
+		cfg.Header = "Idempotency-Key"
+	}
+	if cfg.TTL == 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.Lock == 0 {
+		cfg.Lock = time.Minute
+	}
+	if cfg.Methods == nil {
+		cfg.Methods = []string{http.MethodPost}
+	}
+	if cfg.MaxBody == 0 {
+		cfg.MaxBody = 1 << 20
+	}
+	if cfg.APIKeyHeader == "" {
+		cfg.APIKeyHeader = "X-API-Key"
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryIdempotencyStore(defaultIdempotencyStoreBytes)
+	}
+	identity := RateLimitConfig{KeyBy: "user", APIKeyHeader: cfg.APIKeyHeader, APIKeys: cfg.APIKeys, TrustProxy: cfg.TrustProxy}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idempotencyKey := r.Header.Get(cfg.Header)
+			if idempotencyKey == "" || !slices.Contains(cfg.Methods, r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(idempotencyKey) > maxIdempotencyKeyLength {
+				respondWithError(w, http.StatusBadRequest, "Idempotency-Key is too long")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.maxBodyFor(routeKey(r))))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+					return
+				}
+				respondWithError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			key := tenant.IDFromContext(r.Context()) + "|" + clientKey(r, identity) + "|" + idempotencyKey
+			fingerprint := requestFingerprint(r, body)
+			record, err := cfg.Store.Reserve(r.Context(), key, fingerprint, cfg.Lock)
+			if err != nil {
+				// Fail open, like the rate limiter.
+				next.ServeHTTP(w, r)
+				return
+			}
+			if record != nil {
+				switch {
+				case record.Fingerprint != fingerprint:
+					respondWithError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+				case record.Response == nil:
+					w.Header().Set("Retry-After", "1")
+					respondWithError(w, http.StatusConflict, "A request with this Idempotency-Key is in progress")
+				default:
+					replay(w, record.Response)
+				}
+				return
+			}
+
+			rec := &idempotencyRecorder{ResponseWriter: w, before: w.Header().Clone()}
+			saved := false
+			defer func() {
+				if !saved {
+					cfg.Store.Release(context.WithoutCancel(r.Context()), key)
+				}
+			}()
+
+			stop := holdReservation(r.Context(), cfg.Store, key, cfg.Lock)
+			next.ServeHTTP(rec, r)
+			stop()
+
+			if rec.status != 0 && rec.status < http.StatusInternalServerError {
+				err := cfg.Store.Save(context.WithoutCancel(r.Context()), key, rec.response(), cfg.TTL)
+				saved = err == nil
+			}
+		})
+	}
+}
+
+// holdReservation renews key every half lock until the returned function
+// is called, so that a long request, such as a large import, keeps its key
+// and a retry is not run alongside it.
+func holdReservation(ctx context.Context, store IdempotencyStore, key string, lock time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lock / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				store.Extend(context.WithoutCancel(ctx), key, lock)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// requestFingerprint identifies what a request asks for, so that a key
+// reused for something else can be told apart from a retry.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, resp *IdempotentResponse) {
+	for name, values := range resp.Header {
+		w.Header()[name] = slices.Clone(values)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// idempotencyRecorder passes the response through while keeping a copy.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	// before holds the headers set by earlier middleware, which are not
+	// part of the stored response.
+	before http.Header
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *idempotencyRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *idempotencyRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *idempotencyRecorder) response() *IdempotentResponse {
+	header := http.Header{}
+	for name, values := range w.header {
+		if !slices.Equal(values, w.before[name]) {
+			header[name] = values
+		}
+	}
+	return &IdempotentResponse{Status: w.status, Header: header, Body: w.body.Bytes()}
+}
+
+// defaultIdempotencyStoreBytes bounds the responses the default
+// MemoryIdempotencyStore keeps.
+const defaultIdempotencyStoreBytes = 64 << 20
+
+type idempotencyEntry struct {
+	record  IdempotencyRecord
+	expires time.Time
+	// stored places a saved response in the store's eviction order, and
+	// size is what it counts against the store's bound.
+	stored *list.Element
+	size   int
+}
+
+// MemoryIdempotencyStore keeps responses in process memory, up to a bound
+// on their total size. When it is reached the oldest responses are dropped
+// early, and a response larger than the bound is not kept at all; retries
+// of those requests run again.
+type MemoryIdempotencyStore struct {
+	mu       sync.Mutex
+	entries  map[string]*idempotencyEntry
+	order    *list.List
+	size     int
+	maxBytes int
+	now      func() time.Time
+	ops      int
+}
+
+func NewMemoryIdempotencyStore(maxBytes int) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: map[string]*idempotencyEntry{}, order: list.New(), maxBytes: maxBytes, now: time.Now}
+}
+
+// responseSize estimates the memory a stored response takes.
+func responseSize(key string, resp *IdempotentResponse) int {
+	size := len(key) + len(resp.Body)
+	for name, values := range resp.Header {
+		size += len(name)
+		for _, v := range values {
+			size += len(v)
+		}
+	}
+	return size
+}
+
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, lock time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.ops++
+	if s.ops%1024 == 0 {
+		s.sweep(now)
+	}
+
+	if entry, ok := s.entries[key]; ok {
+		if now.Before(entry.expires) {
+			record := entry.record
+			return &record, nil
+		}
+		s.remove(key, entry)
+	}
+	s.entries[key] = &idempotencyEntry{
+		record:  IdempotencyRecord{Fingerprint: fingerprint},
+		expires: now.Add(lock),
+	}
+	return nil, nil
+}
+
+func (s *MemoryIdempotencyStore) Save(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return errors.New("idempotency key not reserved")
+	}
+	size := responseSize(key, resp)
+	if size > s.maxBytes {
+		return errors.New("idempotent response too large to store")
+	}
+
+	entry.record.Response = resp
+	entry.expires = s.now().Add(ttl)
+	entry.size = size
+	entry.stored = s.order.PushBack(key)
+	s.size += size
+	for s.size > s.maxBytes {
+		oldest := s.order.Front().Value.(string)
+		s.remove(oldest, s.entries[oldest])
+	}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok && entry.record.Response == nil {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Extend(ctx context.Context, key string, lock time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok && entry.record.Response == nil {
+		entry.expires = s.now().Add(lock)
+	}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) remove(key string, entry *idempotencyEntry) {
+	if entry.stored != nil {
+		s.order.Remove(entry.stored)
+		s.size -= entry.size
+	}
+	delete(s.entries, key)
+}
+
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			s.remove(key, entry)
+		}
+	}
+}
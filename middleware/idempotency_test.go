@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"test-server/auth"
+)
+
+// countingHandler creates a "todo" per call.
+type countingHandler struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.calls++
+	n := h.calls
+	h.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/todos/%d", n))
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"id":%d}`, n)
+}
+
+func idempotentPost(handler http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/todos", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysFirstResponse(t *testing.T) {
+	next := &countingHandler{}
+	handler := Idempotency(IdempotencyConfig{})(next)
+
+	first := idempotentPost(handler, "k1", `{"title":"Milk"}`)
+	retry := idempotentPost(handler, "k1", `{"title":"Milk"}`)
+
+	if next.calls != 1 {
+		t.Fatalf("Expected the handler to run once, ran %d times", next.calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected replay of %d %s, got %d %s", first.Code, first.Body, retry.Code, retry.Body)
+	}
+	if retry.Header().Get("Location") != "/api/todos/1" || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Unexpected replayed headers %v", retry.Header())
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("Expected the first response not to be marked as replayed")
+	}
+
+	// Requests without a key are never deduplicated.
+	idempotentPost(handler, "", `{"title":"Milk"}`)
+	idempotentPost(handler, "", `{"title":"Milk"}`)
+	if next.calls != 3 {
+		t.Errorf("Expected requests without a key to run, ran %d times", next.calls)
+	}
+}
+
+func TestIdempotencyRejectsKeyReuseWithDifferentBody(t *testing.T) {
+	next := &countingHandler{}
+	handler := Idempotency(IdempotencyConfig{})(next)
+
+	idempotentPost(handler, "k1", `{"title":"Milk"}`)
+	w := idempotentPost(handler, "k1", `{"title":"Eggs"}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	if next.calls != 1 {
+		t.Errorf("Expected the handler to run once, ran %d times", next.calls)
+	}
+}
+
+func TestIdempotencyConflictsWhileInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := Idempotency(IdempotencyConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- idempotentPost(handler, "k1", "{}") }()
+	<-started
+
+	w := idempotentPost(handler, "k1", "{}")
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 409 with Retry-After, got %d", w.Code)
+	}
+
+	close(release)
+	if first := <-done; first.Code != http.StatusCreated {
+		t.Errorf("Expected the first request to complete, got %d", first.Code)
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	calls := 0
+	handler := Idempotency(IdempotencyConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	idempotentPost(handler, "k1", "{}")
+	if w := idempotentPost(handler, "k1", "{}"); w.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("Expected the retry to run after a server error, got %d after %d calls", w.Code, calls)
+	}
+}
+
+func TestIdempotencyKeysAreScopedToCaller(t *testing.T) {
+	next := &countingHandler{}
+	handler := Idempotency(IdempotencyConfig{})(next)
+
+	for _, user := range []string{"alice", "bob"} {
+		req := httptest.NewRequest("POST", "/api/todos", strings.NewReader("{}"))
+		req.Header.Set("Idempotency-Key", "k1")
+		req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{"sub": user}))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if next.calls != 2 {
+		t.Errorf("Expected each caller's key to be separate, ran %d times", next.calls)
+	}
+}
+
+func TestIdempotencyResponsesExpire(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryIdempotencyStore(1 << 20)
+	store.now = func() time.Time { return now }
+	next := &countingHandler{}
+	handler := Idempotency(IdempotencyConfig{TTL: time.Hour, Store: store})(next)
+
+	idempotentPost(handler, "k1", "{}")
+	now = now.Add(2 * time.Hour)
+	idempotentPost(handler, "k1", "{}")
+
+	if next.calls != 2 {
+		t.Errorf("Expected the key to be reusable after the TTL, ran %d times", next.calls)
+	}
+}
+
+func TestIdempotencyBodyLimitPerRoute(t *testing.T) {
+	body := `{"title":"` + strings.Repeat("x", 64) + `"}`
+	limited := Idempotency(IdempotencyConfig{MaxBody: 32})(&countingHandler{})
+	if w := idempotentPost(limited, "k1", body); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 over the default limit, got %d", w.Code)
+	}
+
+	raised := Idempotency(IdempotencyConfig{MaxBody: 32, MaxBodies: map[string]int64{"POST /api/todos": 1024}})(&countingHandler{})
+	if w := idempotentPost(raised, "k1", body); w.Code != http.StatusCreated {
+		t.Errorf("Expected the route's limit to apply, got %d", w.Code)
+	}
+}
+
+func TestIdempotencyHoldsKeyWhileRequestRuns(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler := Idempotency(IdempotencyConfig{Lock: 20 * time.Millisecond})(slow)
+
+	done := make(chan struct{})
+	go func() {
+		idempotentPost(handler, "k1", "{}")
+		close(done)
+	}()
+	<-started
+	// Well past the lock, the first request still holds the key.
+	time.Sleep(100 * time.Millisecond)
+	retry := idempotentPost(handler, "k1", "{}")
+	close(release)
+	<-done
+
+	if retry.Code != http.StatusConflict {
+		t.Errorf("Expected 409 while the first request runs, got %d", retry.Code)
+	}
+}
+
+func TestMemoryIdempotencyStoreIsBounded(t *testing.T) {
+	store := NewMemoryIdempotencyStore(100)
+	ctx := context.Background()
+	resp := &IdempotentResponse{Status: http.StatusCreated, Body: []byte(strings.Repeat("x", 40))}
+
+	for _, key := range []string{"k1", "k2", "k3"} {
+		store.Reserve(ctx, key, "f", time.Minute)
+		if err := store.Save(ctx, key, resp, time.Hour); err != nil {
+			t.Fatalf("Expected %s to be stored, got %v", key, err)
+		}
+	}
+	if record, _ := store.Reserve(ctx, "k1", "f", time.Minute); record != nil {
+		t.Error("Expected the oldest response to be dropped")
+	}
+	if record, _ := store.Reserve(ctx, "k3", "f", time.Minute); record == nil || record.Response == nil {
+		t.Error("Expected the newest response to be kept")
+	}
+
+	store.Reserve(ctx, "big", "f", time.Minute)
+	if err := store.Save(ctx, "big", &IdempotentResponse{Body: make([]byte, 200)}, time.Hour); err == nil {
+		t.Error("Expected a response over the bound not to be stored")
+	}
+}
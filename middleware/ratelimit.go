@@ -0,0 +1,297 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"test-server/auth"
+
+	"github.com/gorilla/mux"
+)
+
+// RateLimit describes a token bucket: Rate tokens are added per second up to
+// Burst tokens.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l RateLimit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Reset is the time until the bucket is full again; RetryAfter is the
+	// time until the next token when the request was rejected.
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimitStore takes tokens from named buckets. The in-process
+// MemoryRateLimitStore can be replaced with a shared implementation so that
+// several instances enforce one budget.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+type RateLimitConfig struct {
+	Default RateLimit
+	// Routes overrides Default per route, keyed by "METHOD /path/template",
+	// e.g. "POST /api/todos".
+	Routes map[string]RateLimit
+	// KeyBy selects the client identity: "user" (token subject), "api_key"
+	// or "ip". Identities that are unavailable fall back in that order.
+	KeyBy        string
+	APIKeyHeader string
+	// APIKeys are the keys accepted as identities. A request carrying any
+	// other key is keyed by its IP, so made-up keys cannot mint budgets.
+	APIKeys []string
+	// TrustProxy takes the client IP from X-Forwarded-For.
+	TrustProxy bool
+	Store      RateLimitStore
+}
+
+func (c RateLimitConfig) limitFor(route string) RateLimit {
+	if limit, ok := c.Routes[route]; ok {
+		return limit
+	}
+	return c.Default
+}
+
+func RateLimiter(cfg RateLimitConfig) func(http.Handler) http.Handler {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateLimitStore()
+	}
+	if cfg.APIKeyHeader == "" {
+		cfg.APIKeyHeader = "X-API-Key"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeKey(r)
+			limit := cfg.limitFor(route)
+			if !limit.Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := route + "|" + clientKey(r, cfg)
+			result, err := cfg.Store.Take(r.Context(), key, limit)
+			if err != nil {
+				// Fail open: an unavailable limiter store should not take
+				// the API down with it.
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				respondWithError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func routeKey(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return r.Method + " " + tpl
+		}
+	}
+	return r.Method + " " + r.URL.Path
+}
+
+func clientKey(r *http.Request, cfg RateLimitConfig) string {
+	switch cfg.KeyBy {
+	case "user":
+		if user := auth.UserID(r.Context()); user != "" {
+			return "user:" + user
+		}
+		fallthrough
+	case "api_key":
+		if key := r.Header.Get(cfg.APIKeyHeader); key != "" && cfg.validAPIKey(key) {
+			return "key:" + key
+		}
+	}
+	return "ip:" + clientIP(r, cfg.TrustProxy)
+}
+
+func (c RateLimitConfig) validAPIKey(key string) bool {
+	valid := false
+	for _, allowed := range c.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(allowed), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ParseRateLimits parses a comma separated list of "route=rate" entries,
+// e.g. "default=100/m,POST /api/todos=10/m:5". Rates are "N/s", "N/m" or
+// "N/h" with an optional ":burst" that defaults to N.
+func ParseRateLimits(spec string) (RateLimit, map[string]RateLimit, error) {
+	var defaultLimit RateLimit
+	routes := map[string]RateLimit{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, rate, ok := strings.Cut(entry, "=")
+		if !ok {
+			return defaultLimit, nil, fmt.Errorf("invalid rate limit %q", entry)
+		}
+		limit, err := parseRate(rate)
+		if err != nil {
+			return defaultLimit, nil, fmt.Errorf("invalid rate limit %q: %w", entry, err)
+		}
+		route = strings.TrimSpace(route)
+		if route == "default" {
+			defaultLimit = limit
+		} else {
+			routes[route] = limit
+		}
+	}
+
+	return defaultLimit, routes, nil
+}
+
+func parseRate(rate string) (RateLimit, error) {
+	rate, burstSpec, hasBurst := strings.Cut(strings.TrimSpace(rate), ":")
+	count, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("missing unit")
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid count %q", count)
+	}
+
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return RateLimit{}, fmt.Errorf("invalid unit %q", unit)
+	}
+
+	burst := n
+	if hasBurst {
+		burst, err = strconv.Atoi(burstSpec)
+		if err != nil || burst <= 0 {
+			return RateLimit{}, fmt.Errorf("invalid burst %q", burstSpec)
+		}
+	}
+
+	return RateLimit{Rate: float64(n) / per.Seconds(), Burst: burst}, nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// refill is how long an empty bucket takes to fill up.
+	refill time.Duration
+}
+
+// MemoryRateLimitStore keeps token buckets in process memory.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+	takes   int
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens: float64(limit.Burst),
+			last:   now,
+			refill: secondsDuration(float64(limit.Burst) / limit.Rate),
+		}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	var result RateLimitResult
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - b.tokens) / limit.Rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = secondsDuration((float64(limit.Burst) - b.tokens) / limit.Rate)
+
+	s.takes++
+	if s.takes%1024 == 0 {
+		s.sweep(now)
+	}
+
+	return result, nil
+}
+
+// sweep drops buckets that have been idle long enough to refill completely,
+// since they are indistinguishable from new ones.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > b.refill {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestRateLimiterRejectsAfterBurst(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(RateLimiter(RateLimitConfig{
+		Routes: map[string]RateLimit{"POST /api/todos": {Rate: 1.0 / 60, Burst: 2}},
+	}))
+	router.HandleFunc("/api/todos", okHandler).Methods("POST")
+	router.HandleFunc("/api/todos", okHandler).Methods("GET")
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/api/todos", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status code %d, got %d", i, http.StatusOK, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/todos", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status code %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", w.Header().Get("Retry-After"))
+	}
+	if w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected RateLimit-Remaining 0, got %q", w.Header().Get("RateLimit-Remaining"))
+	}
+
+	// Routes without a limit are unaffected.
+	req = httptest.NewRequest("GET", "/api/todos", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestRateLimiterKeysByAPIKey(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(RateLimiter(RateLimitConfig{
+		Default: RateLimit{Rate: 1.0 / 60, Burst: 1},
+		KeyBy:   "api_key",
+		APIKeys: []string{"a", "b"},
+	}))
+	router.HandleFunc("/api/todos", okHandler).Methods("GET")
+
+	for _, key := range []string{"a", "b"} {
+		req := httptest.NewRequest("GET", "/api/todos", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Key %s: expected status code %d, got %d", key, http.StatusOK, w.Code)
+		}
+	}
+}
+
+func TestRateLimiterIgnoresUnknownAPIKeys(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(RateLimiter(RateLimitConfig{
+		Default: RateLimit{Rate: 1.0 / 60, Burst: 1},
+		KeyBy:   "user",
+		APIKeys: []string{"a"},
+	}))
+	router.HandleFunc("/api/todos", okHandler).Methods("GET")
+
+	codes := []int{}
+	for _, key := range []string{"made-up", "another"} {
+		req := httptest.NewRequest("GET", "/api/todos", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+
+	if codes[1] != http.StatusTooManyRequests {
+		t.Errorf("Expected unknown keys to share the IP's budget, got %v", codes)
+	}
+}
+
+func TestMemoryRateLimitStoreRefills(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	limit := RateLimit{Rate: 1, Burst: 1}
+
+	if result, _ := store.Take(context.Background(), "k", limit); !result.Allowed {
+		t.Fatal("Expected first take to be allowed")
+	}
+	if result, _ := store.Take(context.Background(), "k", limit); result.Allowed {
+		t.Fatal("Expected second take to be rejected")
+	}
+
+	now = now.Add(time.Second)
+	if result, _ := store.Take(context.Background(), "k", limit); !result.Allowed {
+		t.Error("Expected take after refill to be allowed")
+	}
+}
+
+func TestParseRateLimits(t *testing.T) {
+	def, routes, err := ParseRateLimits("default=120/m, POST /api/todos=10/m:5")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if def.Rate != 2 || def.Burst != 120 {
+		t.Errorf("Unexpected default limit %+v", def)
+	}
+	if limit := routes["POST /api/todos"]; limit.Burst != 5 {
+		t.Errorf("Unexpected route limit %+v", limit)
+	}
+
+	if _, _, err := ParseRateLimits("default=10/d"); err == nil {
+		t.Error("Expected error for unknown unit")
+	}
+}
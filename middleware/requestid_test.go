@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"test-server/requestid"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		sent string
+		keep bool
+	}{
+		{"client ID", "req-123", true},
+		{"missing", "", false},
+		{"malformed", "two words", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = requestid.FromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/api/todos", nil)
+			if tt.sent != "" {
+				req.Header.Set(RequestIDHeader, tt.sent)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if seen == "" || w.Header().Get(RequestIDHeader) != seen {
+				t.Fatalf("Expected the response to echo %q, got %q", seen, w.Header().Get(RequestIDHeader))
+			}
+			if (seen == tt.sent) != tt.keep {
+				t.Errorf("Unexpected request ID %q for %q", seen, tt.sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"test-server/auth"
+	"test-server/tenant"
+)
+
+type TenantConfig struct {
+	// Header carrying the tenant ID, e.g. "X-Tenant-ID". Empty disables it.
+	Header string
+	// BaseDomain enables subdomain resolution: "acme.todo.example.com" with
+	// BaseDomain "todo.example.com" resolves to "acme".
+	BaseDomain string
+	// Claim names the token claim carrying the tenant ID. A tenant taken from
+	// a verified token cannot be overridden by the header or subdomain.
+	Claim string
+	// Required rejects requests that resolve no tenant instead of falling
+	// back to tenant.DefaultID.
+	Required bool
+}
+
+func Tenant(cfg TenantConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.Header != "" {
+				// Responses differ per tenant, so caches must key on it.
+				w.Header().Add("Vary", cfg.Header)
+			}
+			id, fromClaim := tenantFromClaim(r, cfg.Claim)
+
+			requested := tenantFromHeader(r, cfg.Header)
+			if requested == "" {
+				requested = tenantFromSubdomain(r, cfg.BaseDomain)
+			}
+
+			switch {
+			case fromClaim && requested != "" && requested != id:
+				respondWithError(w, http.StatusForbidden, "Tenant does not match token")
+				return
+			case !fromClaim:
+				id = requested
+			}
+
+			if id == "" {
+				if cfg.Required {
+					respondWithError(w, http.StatusBadRequest, "Tenant is required")
+					return
+				}
+				id = tenant.DefaultID
+			}
+
+			if !tenant.ValidID(id) {
+				respondWithError(w, http.StatusBadRequest, "Invalid tenant")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(tenant.WithID(r.Context(), id)))
+		})
+	}
+}
+
+func tenantFromClaim(r *http.Request, claim string) (string, bool) {
+	if claim == "" {
+		return "", false
+	}
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		return "", false
+	}
+	id := claims.String(claim)
+	return id, id != ""
+}
+
+func tenantFromHeader(r *http.Request, header string) string {
+	if header == "" {
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get(header))
+}
+
+func tenantFromSubdomain(r *http.Request, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	sub, ok := strings.CutSuffix(host, "."+strings.ToLower(baseDomain))
+	if !ok || sub == "" || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
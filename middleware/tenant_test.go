@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"test-server/auth"
+	"test-server/tenant"
+)
+
+func tenantEcho() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(tenant.IDFromContext(r.Context())))
+	})
+}
+
+func TestTenantFromHeader(t *testing.T) {
+	handler := Tenant(TenantConfig{Header: "X-Tenant-ID"})(tenantEcho())
+
+	req := httptest.NewRequest("GET", "/api/todos", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Body.String() != "acme" {
+		t.Errorf("Expected tenant acme, got %s", w.Body.String())
+	}
+}
+
+func TestTenantFromSubdomain(t *testing.T) {
+	handler := Tenant(TenantConfig{BaseDomain: "todo.example.com"})(tenantEcho())
+
+	req := httptest.NewRequest("GET", "http://globex.todo.example.com:8080/api/todos", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Body.String() != "globex" {
+		t.Errorf("Expected tenant globex, got %s", w.Body.String())
+	}
+}
+
+func TestTenantFallsBackToDefault(t *testing.T) {
+	handler := Tenant(TenantConfig{Header: "X-Tenant-ID"})(tenantEcho())
+
+	req := httptest.NewRequest("GET", "/api/todos", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Body.String() != tenant.DefaultID {
+		t.Errorf("Expected tenant %s, got %s", tenant.DefaultID, w.Body.String())
+	}
+}
+
+func TestTenantRequired(t *testing.T) {
+	handler := Tenant(TenantConfig{Header: "X-Tenant-ID", Required: true})(tenantEcho())
+
+	req := httptest.NewRequest("GET", "/api/todos", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestTenantInvalidID(t *testing.T) {
+	handler := Tenant(TenantConfig{Header: "X-Tenant-ID"})(tenantEcho())
+
+	req := httptest.NewRequest("GET", "/api/todos", nil)
+	req.Header.Set("X-Tenant-ID", "../acme")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestTenantFromTokenClaim(t *testing.T) {
+	secret := []byte("secret")
+	handler := Authenticate(secret)(Tenant(TenantConfig{Header: "X-Tenant-ID", Claim: "tenant_id"})(tenantEcho()))
+	token, _ := auth.SignToken(auth.Claims{"sub": "alice", "tenant_id": "acme"}, secret)
+
+	req := httptest.NewRequest("GET", "/api/todos", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Body.String() != "acme" {
+		t.Errorf("Expected tenant acme, got %s", w.Body.String())
+	}
+
+	// A token-bound caller cannot switch tenants with the header.
+	req = httptest.NewRequest("GET", "/api/todos", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Tenant-ID", "globex")
+	w = httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestAuthenticateRejectsInvalidToken(t *testing.T) {
+	handler := Authenticate([]byte("secret"))(tenantEcho())
+
+	req := httptest.NewRequest("GET", "/api/todos", nil)
+	req.Header.Set("Authorization", "Bearer not-a-token")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
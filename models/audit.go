@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditEntry records one change to a todo. Changes holds only the fields
+// that changed, keyed by their JSON name; Before is null on create and After
+// is null on delete.
+type AuditEntry struct {
+	ID        int64                  `json:"id"`
+	TenantID  string                 `json:"tenant_id"`
+	TodoID    int                    `json:"todo_id"`
+	Actor     string                 `json:"actor"`
+	Action    string                 `json:"action"`
+	Changes   map[string]AuditChange `json:"changes"`
+	RequestID string                 `json:"request_id"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+type AuditChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// AuditFilter narrows GET /api/audit. Entries come newest first; BeforeID
+// continues from the last entry of the previous page.
+type AuditFilter struct {
+	TodoID    *int
+	Actor     string
+	Action    string
+	RequestID string
+	Since     *time.Time
+	Until     *time.Time
+	BeforeID  int64
+	Limit     int
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Comment is a note on a todo. Body holds the Markdown as written; BodyHTML
+// is only filled in when the caller asks for rendered comments.
+type Comment struct {
+	ID        int       `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	TodoID    int       `json:"todo_id"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	BodyHTML  string    `json:"body_html,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AnonymousUser is recorded as the author of comments and the actor of
+// changes made without a token.
+const AnonymousUser = "anonymous"
+
+type CreateCommentRequest struct {
+	Body string `json:"body"`
+}
+
+type UpdateCommentRequest struct {
+	Body string `json:"body"`
+}
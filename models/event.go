@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+const (
+	EventTodoCreated = "todo.created"
+	EventTodoUpdated = "todo.updated"
+	EventTodoDeleted = "todo.deleted"
+)
+
+// EventTypes lists every event type, in the order they are documented.
+var EventTypes = []string{EventTodoCreated, EventTodoUpdated, EventTodoDeleted}
+
+// TodoEvent describes a committed change to a todo. Todo is the todo as it
+// was after the change, or just before it for deletions; Changes is the same
+// field diff the audit log records. ID is unique per event and stays the same
+// when an event is published more than once, so consumers can use it as an
+// idempotency key.
+type TodoEvent struct {
+	ID string `json:"id"`
+	// Sequence is the event's position in the outbox, which orders events
+	// by when they were written. It is not part of the payload.
+	Sequence   int64                  `json:"-"`
+	Type       string                 `json:"type"`
+	TenantID   string                 `json:"tenant_id"`
+	TodoID     int                    `json:"todo_id"`
+	Actor      string                 `json:"actor"`
+	Todo       *Todo                  `json:"todo"`
+	Changes    map[string]AuditChange `json:"changes"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+// EventType returns the event type for an audit action.
+func EventType(action string) string {
+	switch action {
+	case AuditCreate:
+		return EventTodoCreated
+	case AuditDelete:
+		return EventTodoDeleted
+	default:
+		return EventTodoUpdated
+	}
+}
+
+// OutboxEvent is an event waiting in the outbox to be published. Sequence
+// orders events by when their changes were made.
+type OutboxEvent struct {
+	Sequence int64
+	Event    TodoEvent
+	Attempts int
+}
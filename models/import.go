@@ -0,0 +1,26 @@
+package models
+
+// ImportRowError is a problem with one row of an import. Rows are numbered
+// as in a spreadsheet, with the header as row 1.
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportResult reports an import. Imported counts the todos created, or
+// that would be created in a dry run. Duplicates lists the rows skipped by
+// deduplication and IgnoredColumns the header cells that map to no field.
+type ImportResult struct {
+	DryRun         bool             `json:"dry_run"`
+	Rows           int              `json:"rows"`
+	Imported       int              `json:"imported"`
+	Duplicates     []int            `json:"duplicates"`
+	Errors         []ImportRowError `json:"errors"`
+	IgnoredColumns []string         `json:"ignored_columns"`
+}
+
+// ImportProgress is sent while a large import is being written.
+type ImportProgress struct {
+	Imported int `json:"imported"`
+	Total    int `json:"total"`
+}
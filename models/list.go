@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// List groups todos. Todos without a list live in the tenant's inbox, which
+// is reported as a list with ID 0 and Inbox set.
+type List struct {
+	ID             int       `json:"id"`
+	TenantID       string    `json:"tenant_id"`
+	Name           string    `json:"name"`
+	Inbox          bool      `json:"inbox"`
+	OpenCount      int       `json:"open_count"`
+	CompletedCount int       `json:"completed_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// InboxListID addresses the inbox in list_id fields and list routes.
+const InboxListID = 0
+
+type CreateListRequest struct {
+	Name string `json:"name"`
+}
+
+type UpdateListRequest struct {
+	Name *string `json:"name"`
+}
+
+const (
+	// ListDeleteMoveToInbox keeps a deleted list's todos in the inbox.
+	ListDeleteMoveToInbox = "inbox"
+	// ListDeleteCascade deletes a list together with its todos.
+	ListDeleteCascade = "delete"
+)
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"test-server/recurrence"
+)
+
+// Recurrence is how a todo repeats. Completing the todo creates the next
+// occurrence, which carries the rule forward, so changing the rule affects
+// this and future occurrences.
+type Recurrence struct {
+	Rule string `json:"rule"`
+	// Timezone is the IANA zone whose wall clock occurrences keep.
+	Timezone string `json:"timezone"`
+	// Occurrence is the todo's place in its series, starting at 1.
+	Occurrence int `json:"occurrence"`
+}
+
+// NormalizeRecurrence validates a recurrence shorthand or RRULE and returns
+// its canonical form. An empty value stays empty.
+func NormalizeRecurrence(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+	rule, err := recurrence.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("recurrence: %w", err)
+	}
+	return rule.String(), nil
+}
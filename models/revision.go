@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// TodoRevision is a full snapshot of a todo as it was after a change.
+// Revisions are numbered from 1 per todo.
+type TodoRevision struct {
+	TodoID    int       `json:"todo_id"`
+	Revision  int       `json:"revision"`
+	Actor     string    `json:"actor"`
+	Todo      Todo      `json:"todo"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RevisionDiff lists the fields that differ between two revisions, in the
+// same form as audit entries.
+type RevisionDiff struct {
+	TodoID  int                    `json:"todo_id"`
+	From    int                    `json:"from"`
+	To      int                    `json:"to"`
+	Changes map[string]AuditChange `json:"changes"`
+}
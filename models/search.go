@@ -0,0 +1,14 @@
+package models
+
+type SearchResult struct {
+	Todo
+	Score      float64          `json:"score"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+// SearchHighlights hold HTML-escaped fragments with matches wrapped in
+// <mark> tags.
+type SearchHighlights struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import "fmt"
+
+const (
+	StatusTodo       = "todo"
+	StatusInProgress = "in_progress"
+	StatusBlocked    = "blocked"
+	StatusDone       = "done"
+	StatusArchived   = "archived"
+)
+
+const (
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+// statusTransitions lists the statuses reachable from each status. Staying
+// in the same status is always allowed.
+var statusTransitions = map[string][]string{
+	StatusTodo:       {StatusInProgress, StatusBlocked, StatusDone, StatusArchived},
+	StatusInProgress: {StatusTodo, StatusBlocked, StatusDone, StatusArchived},
+	StatusBlocked:    {StatusTodo, StatusInProgress, StatusDone, StatusArchived},
+	StatusDone:       {StatusTodo, StatusArchived},
+	StatusArchived:   {StatusTodo},
+}
+
+var priorities = map[string]bool{
+	PriorityLow:    true,
+	PriorityMedium: true,
+	PriorityHigh:   true,
+	PriorityUrgent: true,
+}
+
+func ValidStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+func ValidPriority(priority string) bool {
+	return priorities[priority]
+}
+
+// TransitionError is returned for a status change the workflow does not
+// allow.
+type TransitionError struct {
+	From, To string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot move todo from %s to %s", e.From, e.To)
+}
+
+func CanTransition(from, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusForCompleted maps the legacy completed flag onto the workflow for
+// clients that predate status. Marking an unfinished todo incomplete keeps
+// its current status.
+func StatusForCompleted(completed bool, current string) string {
+	if completed {
+		return StatusDone
+	}
+	if current == StatusDone {
+		return StatusTodo
+	}
+	return current
+}
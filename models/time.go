@@ -0,0 +1,67 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q", name)
+	}
+	return loc, nil
+}
+
+// ParseDateTime parses an RFC 3339 timestamp, or a local date/time in loc.
+// The result is normalised to UTC.
+func ParseDateTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date/time %q", value)
+}
+
+func parseSchedule(dueAt, remindAt *string, timezone string) (*time.Time, *time.Time, error) {
+	loc, err := LoadTimezone(timezone)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	due, err := parseOptional(dueAt, loc, "due_at")
+	if err != nil {
+		return nil, nil, err
+	}
+	remind, err := parseOptional(remindAt, loc, "remind_at")
+	if err != nil {
+		return nil, nil, err
+	}
+	return due, remind, nil
+}
+
+func parseOptional(value *string, loc *time.Location, field string) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	t, err := ParseDateTime(*value, loc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", field, err)
+	}
+	return &t, nil
+}
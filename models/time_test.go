@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateTime(t *testing.T) {
+	berlin, err := LoadTimezone("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("Failed to load timezone: %v", err)
+	}
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2026-07-01T09:30:00+02:00", time.Date(2026, 7, 1, 7, 30, 0, 0, time.UTC)},
+		{"2026-07-01T09:30", time.Date(2026, 7, 1, 7, 30, 0, 0, time.UTC)},
+		{"2026-01-15", time.Date(2026, 1, 14, 23, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDateTime(tt.value, berlin)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) || got.Location() != time.UTC {
+			t.Errorf("%s: expected %v, got %v", tt.value, tt.want, got)
+		}
+	}
+
+	if _, err := ParseDateTime("tomorrow", berlin); err == nil {
+		t.Error("Expected error for unparseable value")
+	}
+}
+
+func TestUpdateTodoRequestParseTimesClears(t *testing.T) {
+	empty := ""
+	req := UpdateTodoRequest{DueAt: &empty, Timezone: "Europe/Berlin"}
+
+	if err := req.ParseTimes(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if req.ParsedDueAt != nil {
+		t.Error("Expected empty due_at to clear the field")
+	}
+
+	req.Timezone = "Mars/Olympus"
+	if err := req.ParseTimes(); err == nil {
+		t.Error("Expected error for unknown timezone")
+	}
+}
@@ -3,21 +3,116 @@ package models
 import "time"
 
 type Todo struct {
-	ID          int       `json:"id" db:"id"`
-	Title       string    `json:"title" db:"title"`
-	Description string    `json:"description" db:"description"`
-	Completed   bool      `json:"completed" db:"completed"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID             int         `json:"id" db:"id"`
+	TenantID       string      `json:"tenant_id" db:"tenant_id"`
+	ListID         *int        `json:"list_id" db:"list_id"`
+	ParentID       *int        `json:"parent_id" db:"parent_id"`
+	Title          string      `json:"title" db:"title"`
+	Description    string      `json:"description" db:"description"`
+	Completed      bool        `json:"completed" db:"completed"`
+	Status         string      `json:"status" db:"status"`
+	Priority       string      `json:"priority" db:"priority"`
+	CompletedAt    *time.Time  `json:"completed_at" db:"completed_at"`
+	Tags           []string    `json:"tags" db:"-"`
+	CommentCount   int         `json:"comment_count" db:"-"`
+	Position       string      `json:"position" db:"position"`
+	Recurrence     *Recurrence `json:"recurrence" db:"-"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
+	DueAt          *time.Time  `json:"due_at" db:"due_at"`
+	RemindAt       *time.Time  `json:"remind_at" db:"remind_at"`
+	ReminderSentAt *time.Time  `json:"reminder_sent_at,omitempty" db:"reminder_sent_at"`
 }
 
+// CreateTodoRequest and UpdateTodoRequest accept due_at and remind_at as
+// RFC 3339 timestamps, or as local "2006-01-02T15:04" / "2006-01-02" values
+// interpreted in Timezone (an IANA name, UTC by default). ParseTimes fills
+// the parsed fields the repository stores. Recurrence takes a shorthand
+// ("daily", "weekly", "monthly", "yearly") or an RRULE, repeating in
+// Timezone.
 type CreateTodoRequest struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description"`
+	Title       string   `json:"title" binding:"required"`
+	Description string   `json:"description"`
+	ListID      *int     `json:"list_id"`
+	ParentID    *int     `json:"parent_id"`
+	Status      string   `json:"status"`
+	Priority    string   `json:"priority"`
+	Tags        []string `json:"tags"`
+	Recurrence  *string  `json:"recurrence"`
+	DueAt       *string  `json:"due_at"`
+	RemindAt    *string  `json:"remind_at"`
+	Timezone    string   `json:"timezone"`
+
+	ParsedDueAt    *time.Time `json:"-"`
+	ParsedRemindAt *time.Time `json:"-"`
 }
 
+// For updates a nil DueAt or RemindAt leaves the field unchanged and an
+// empty string clears it; an empty Recurrence stops the todo repeating.
+// Tags, when present, replace the todo's tags. A ListID of InboxListID
+// moves the todo to the inbox and a ParentID of 0 makes it a top-level todo.
 type UpdateTodoRequest struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
-	Completed   *bool   `json:"completed"`
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+	Completed   *bool     `json:"completed"`
+	ListID      *int      `json:"list_id"`
+	ParentID    *int      `json:"parent_id"`
+	Status      *string   `json:"status"`
+	Priority    *string   `json:"priority"`
+	Tags        *[]string `json:"tags"`
+	Recurrence  *string   `json:"recurrence"`
+	DueAt       *string   `json:"due_at"`
+	RemindAt    *string   `json:"remind_at"`
+	Timezone    string    `json:"timezone"`
+
+	ParsedDueAt    *time.Time `json:"-"`
+	ParsedRemindAt *time.Time `json:"-"`
+}
+
+func (r *CreateTodoRequest) ParseTimes() (err error) {
+	r.ParsedDueAt, r.ParsedRemindAt, err = parseSchedule(r.DueAt, r.RemindAt, r.Timezone)
+	return err
+}
+
+func (r *UpdateTodoRequest) ParseTimes() (err error) {
+	r.ParsedDueAt, r.ParsedRemindAt, err = parseSchedule(r.DueAt, r.RemindAt, r.Timezone)
+	return err
+}
+
+// TodoFilter narrows GetAll. Zero values do not filter.
+type TodoFilter struct {
+	// ListID restricts the result to one list; InboxListID selects the
+	// inbox.
+	ListID    *int
+	DueBefore *time.Time
+	Overdue   bool
+	Status    string
+	Priority  string
+	Tags      []string
+	// TagMatch is "any" (default) or "all".
+	TagMatch string
+}
+
+// TodoListVersion summarises the todos matching a filter, for answering
+// conditional requests without loading them. ChangeID is the tenant's
+// latest audit entry; Comments and LastCommentID cover the comment counts.
+type TodoListVersion struct {
+	Count         int
+	LastModified  time.Time
+	ChangeID      int64
+	Comments      int
+	LastCommentID int64
+}
+
+// MoveTodoRequest places a todo directly after After, directly before
+// Before, or between the two.
+type MoveTodoRequest struct {
+	Before *int `json:"before"`
+	After  *int `json:"after"`
+}
+
+// TodoNode is a todo with its subtasks, as returned by the tree endpoint.
+type TodoNode struct {
+	Todo
+	Children []*TodoNode `json:"children"`
 }
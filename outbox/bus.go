@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+
+	"test-server/models"
+)
+
+// Bus fans events out to subscribers within the process. It never blocks on
+// a subscriber: one whose buffer is full is dropped, and its channel closed,
+// so that it can catch up some other way.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: map[*Subscription]struct{}{}}
+}
+
+type Subscription struct {
+	// C receives events until the subscription is closed or dropped.
+	C <-chan models.TodoEvent
+
+	bus *Bus
+	ch  chan models.TodoEvent
+}
+
+// Subscribe registers a subscriber that can fall up to buffer events
+// behind.
+func (b *Bus) Subscribe(buffer int) *Subscription {
+	ch := make(chan models.TodoEvent, buffer)
+	sub := &Subscription{C: ch, bus: b, ch: ch}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Close unsubscribes. It is safe to call more than once, and after the
+// subscription was dropped.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s)
+}
+
+// remove must be called with b.mu held.
+func (b *Bus) remove(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+func (b *Bus) Publish(ctx context.Context, event models.TodoEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		select {
+		case sub.ch <- event:
+		default:
+			b.remove(sub)
+		}
+	}
+	return nil
+}
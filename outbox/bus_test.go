@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+
+	"test-server/models"
+)
+
+func TestBusDropsSlowSubscribers(t *testing.T) {
+	bus := NewBus()
+	fast := bus.Subscribe(4)
+	defer fast.Close()
+	slow := bus.Subscribe(1)
+	defer slow.Close()
+
+	for _, id := range []string{"e1", "e2", "e3"} {
+		bus.Publish(context.Background(), models.TodoEvent{ID: id})
+	}
+
+	for _, want := range []string{"e1", "e2", "e3"} {
+		if got := (<-fast.C).ID; got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+	}
+
+	if got := (<-slow.C).ID; got != "e1" {
+		t.Errorf("Expected e1, got %s", got)
+	}
+	if _, ok := <-slow.C; ok {
+		t.Error("Expected the slow subscriber to be dropped")
+	}
+}
+
+func TestSubscriptionCloseIsIdempotent(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(1)
+	sub.Close()
+	sub.Close()
+
+	bus.Publish(context.Background(), models.TodoEvent{ID: "e1"})
+	if _, ok := <-sub.C; ok {
+		t.Error("Expected no events after Close")
+	}
+}
@@ -0,0 +1,37 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"test-server/models"
+)
+
+// EventPublisher hands an event on to its consumers. Publishing is
+// at-least-once: an event whose publish failed, or whose success could not
+// be recorded, is published again with the same ID.
+type EventPublisher interface {
+	Publish(ctx context.Context, event models.TodoEvent) error
+}
+
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, event models.TodoEvent) error {
+	log.Printf("Event %s: %s todo %d for tenant %s by %s", event.ID, event.Type, event.TodoID, event.TenantID, event.Actor)
+	return nil
+}
+
+// Publishers publishes each event to every publisher in turn. If any of them
+// fails, the event is retried on all of them.
+type Publishers []EventPublisher
+
+func (p Publishers) Publish(ctx context.Context, event models.TodoEvent) error {
+	var errs []error
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
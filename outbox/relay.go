@@ -0,0 +1,165 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"test-server/models"
+)
+
+// maxErrorLength bounds the error message stored with a failed publish.
+const maxErrorLength = 1000
+
+type Store interface {
+	DueEvents(ctx context.Context, now time.Time, limit int) ([]models.OutboxEvent, error)
+	ClaimEvent(ctx context.Context, sequence int64, now, until time.Time) (bool, error)
+	MarkPublished(ctx context.Context, sequence int64, now time.Time) error
+	MarkUnpublished(ctx context.Context, sequence int64, message string, next time.Time) error
+	NextEventAt(ctx context.Context) (*time.Time, error)
+	PruneEvents(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// Relay publishes events from the outbox, oldest first. Events are written
+// in the same transaction as the changes they describe, so none are lost if
+// the process dies after a change commits: the relay picks them up on the
+// next start. An event whose publish fails is retried with exponential
+// backoff; later events do not wait for it, so consumers must not rely on
+// strict ordering.
+type Relay struct {
+	store     Store
+	publisher EventPublisher
+	// Lease is how long a claimed event is left alone before another relay
+	// may assume the publish was lost.
+	Lease       time.Duration
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Retention is how long published events are kept.
+	Retention time.Duration
+	// MaxSleep bounds how long the relay sleeps without re-reading the
+	// outbox, covering events written by other instances.
+	MaxSleep  time.Duration
+	BatchSize int
+
+	wake       chan struct{}
+	now        func() time.Time
+	lastPruned time.Time
+}
+
+func NewRelay(store Store, publisher EventPublisher) *Relay {
+	return &Relay{
+		store:       store,
+		publisher:   publisher,
+		Lease:       time.Minute,
+		BaseBackoff: time.Second,
+		MaxBackoff:  5 * time.Minute,
+		Retention:   24 * time.Hour,
+		MaxSleep:    time.Minute,
+		BatchSize:   100,
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+	}
+}
+
+// Wake makes the relay check the outbox immediately. It never blocks.
+func (r *Relay) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	for {
+		if err := r.publishDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to publish events: %v", err)
+		}
+		r.prune(ctx)
+
+		timer := time.NewTimer(r.sleepDuration(ctx))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-r.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *Relay) publishDue(ctx context.Context) error {
+	for {
+		now := r.now().UTC()
+		events, err := r.store.DueEvents(ctx, now, r.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			claimed, err := r.store.ClaimEvent(ctx, event.Sequence, now, now.Add(r.Lease))
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+			if err := r.publish(ctx, event); err != nil {
+				return err
+			}
+		}
+
+		if len(events) < r.BatchSize {
+			return nil
+		}
+	}
+}
+
+// publish publishes one event and records the outcome. Only failures to
+// record it are returned.
+func (r *Relay) publish(ctx context.Context, event models.OutboxEvent) error {
+	err := r.publisher.Publish(ctx, event.Event)
+	if err == nil {
+		return r.store.MarkPublished(ctx, event.Sequence, r.now().UTC())
+	}
+
+	message := err.Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+	log.Printf("Failed to publish event %s (attempt %d): %s", event.Event.ID, event.Attempts+1, message)
+	return r.store.MarkUnpublished(ctx, event.Sequence, message, r.now().UTC().Add(r.backoff(event.Attempts+1)))
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (r *Relay) backoff(attempts int) time.Duration {
+	wait := r.BaseBackoff
+	for i := 1; i < attempts && wait < r.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, r.MaxBackoff)
+}
+
+// prune drops old published events, at most once an hour.
+func (r *Relay) prune(ctx context.Context) {
+	now := r.now()
+	if now.Sub(r.lastPruned) < time.Hour {
+		return
+	}
+	r.lastPruned = now
+	if _, err := r.store.PruneEvents(ctx, now.UTC().Add(-r.Retention)); err != nil && ctx.Err() == nil {
+		log.Printf("Failed to prune outbox: %v", err)
+	}
+}
+
+func (r *Relay) sleepDuration(ctx context.Context) time.Duration {
+	next, err := r.store.NextEventAt(ctx)
+	if err != nil || next == nil {
+		return r.MaxSleep
+	}
+	wait := next.Sub(r.now())
+	if wait < 0 {
+		return 0
+	}
+	return min(wait, r.MaxSleep)
+}
@@ -0,0 +1,175 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"test-server/models"
+)
+
+type memoryStore struct {
+	mu        sync.Mutex
+	events    []models.OutboxEvent
+	next      map[int64]time.Time
+	published map[int64]bool
+}
+
+func newMemoryStore(events ...models.TodoEvent) *memoryStore {
+	s := &memoryStore{next: map[int64]time.Time{}, published: map[int64]bool{}}
+	for i, event := range events {
+		s.events = append(s.events, models.OutboxEvent{Sequence: int64(i + 1), Event: event})
+	}
+	return s
+}
+
+func (s *memoryStore) DueEvents(ctx context.Context, now time.Time, limit int) ([]models.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []models.OutboxEvent
+	for _, event := range s.events {
+		if !s.published[event.Sequence] && !s.next[event.Sequence].After(now) && len(due) < limit {
+			due = append(due, event)
+		}
+	}
+	return due, nil
+}
+
+func (s *memoryStore) ClaimEvent(ctx context.Context, sequence int64, now, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.published[sequence] || s.next[sequence].After(now) {
+		return false, nil
+	}
+	s.next[sequence] = until
+	return true, nil
+}
+
+func (s *memoryStore) MarkPublished(ctx context.Context, sequence int64, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.published[sequence] = true
+	s.events[sequence-1].Attempts++
+	return nil
+}
+
+func (s *memoryStore) MarkUnpublished(ctx context.Context, sequence int64, message string, next time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next[sequence] = next
+	s.events[sequence-1].Attempts++
+	return nil
+}
+
+func (s *memoryStore) NextEventAt(ctx context.Context) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next *time.Time
+	for _, event := range s.events {
+		if at := s.next[event.Sequence]; !s.published[event.Sequence] && (next == nil || at.Before(*next)) {
+			next = &at
+		}
+	}
+	return next, nil
+}
+
+func (s *memoryStore) PruneEvents(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// flakyPublisher fails the first publish of each event.
+type flakyPublisher struct {
+	mu        sync.Mutex
+	attempts  map[string]int
+	published []string
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event models.TodoEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts[event.ID]++
+	if p.attempts[event.ID] == 1 {
+		return fmt.Errorf("broker unavailable")
+	}
+	p.published = append(p.published, event.ID)
+	return nil
+}
+
+func TestRelayRetriesFailedPublishWithBackoff(t *testing.T) {
+	store := newMemoryStore(models.TodoEvent{ID: "e1"}, models.TodoEvent{ID: "e2"})
+	publisher := &flakyPublisher{attempts: map[string]int{}}
+	now := time.Now()
+
+	relay := NewRelay(store, publisher)
+	relay.now = func() time.Time { return now }
+
+	if err := relay.publishDue(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(publisher.published) != 0 {
+		t.Fatalf("Expected nothing published yet, got %v", publisher.published)
+	}
+
+	// Not due again until the backoff has passed.
+	now = now.Add(relay.BaseBackoff / 2)
+	relay.publishDue(context.Background())
+	if publisher.attempts["e1"] != 1 {
+		t.Fatalf("Expected no early retry, got %d attempts", publisher.attempts["e1"])
+	}
+
+	now = now.Add(relay.BaseBackoff)
+	if err := relay.publishDue(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(publisher.published) != 2 || publisher.published[0] != "e1" || publisher.published[1] != "e2" {
+		t.Errorf("Expected e1 and e2 published in order, got %v", publisher.published)
+	}
+	if store.events[0].Attempts != 2 {
+		t.Errorf("Expected two attempts, got %d", store.events[0].Attempts)
+	}
+}
+
+func TestRelayWakesForNewEvent(t *testing.T) {
+	store := newMemoryStore()
+	bus := NewBus()
+	sub := bus.Subscribe(1)
+	defer sub.Close()
+
+	relay := NewRelay(store, bus)
+	relay.MaxSleep = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go relay.Run(ctx)
+
+	store.mu.Lock()
+	store.events = append(store.events, models.OutboxEvent{Sequence: 1, Event: models.TodoEvent{ID: "e1"}})
+	store.mu.Unlock()
+	relay.Wake()
+
+	select {
+	case event := <-sub.C:
+		if event.ID != "e1" {
+			t.Errorf("Expected e1, got %s", event.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Event was not published")
+	}
+}
+
+func TestPublishersJoinErrors(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(1)
+	defer sub.Close()
+	failing := &flakyPublisher{attempts: map[string]int{}}
+
+	err := Publishers{failing, bus}.Publish(context.Background(), models.TodoEvent{ID: "e1"})
+	if err == nil {
+		t.Fatal("Expected the failure to be reported")
+	}
+	if event := <-sub.C; event.ID != "e1" {
+		t.Errorf("Expected the bus to still get e1, got %s", event.ID)
+	}
+}
@@ -0,0 +1,148 @@
+// Package ranking generates lexicographic sort keys that always leave room
+// for another key between any two, so moving an item rewrites only that
+// item's key.
+//
+// Keys use the digits 0-9A-Za-z, compare bytewise and never end in '0', the
+// smallest digit, which would leave no room directly below them.
+package ranking
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+var (
+	// ErrNoRoom is returned when the lower bound does not sort before the
+	// upper one, typically because two items share a key.
+	ErrNoRoom = errors.New("ranking: no key between bounds")
+	// ErrInvalidKey is returned for bounds that are not keys.
+	ErrInvalidKey = errors.New("ranking: invalid key")
+)
+
+// MaxLength is the key length beyond which callers should Spread their keys
+// again.
+const MaxLength = 16
+
+// Between returns a key sorting strictly between a and b. An empty a means
+// no lower bound and an empty b no upper bound.
+func Between(a, b string) (string, error) {
+	if err := validate(a); err != nil {
+		return "", err
+	}
+	if err := validate(b); err != nil {
+		return "", err
+	}
+
+	switch {
+	case a == "" && b == "":
+		return digits[base/2 : base/2+1], nil
+	case a == "":
+		return before(b), nil
+	case b == "":
+		return after(a), nil
+	case a >= b:
+		return "", fmt.Errorf("%w: %q, %q", ErrNoRoom, a, b)
+	}
+	return midpoint(a, b), nil
+}
+
+// Spread returns n evenly spaced keys in ascending order.
+func Spread(n int) []string {
+	width, space := 1, base
+	for space <= n {
+		width++
+		space *= base
+	}
+	step := space / (n + 1)
+
+	keys := make([]string, n)
+	for i := range keys {
+		value := (i + 1) * step
+		key := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			key[j] = digits[value%base]
+			value /= base
+		}
+		keys[i] = strings.TrimRight(string(key), "0")
+	}
+	return keys
+}
+
+func validate(key string) error {
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	if strings.HasSuffix(key, "0") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return nil
+}
+
+func digit(key string, i int) int {
+	if i >= len(key) {
+		return 0
+	}
+	return strings.IndexByte(digits, key[i])
+}
+
+// before steps down from b one digit at a time, so repeatedly prepending
+// grows keys slowly.
+func before(b string) string {
+	switch d := digit(b, 0); {
+	case d > 1:
+		return digits[d-1 : d]
+	case d == 1:
+		return "0" + digits[base-1:]
+	default:
+		return "0" + before(b[1:])
+	}
+}
+
+func after(a string) string {
+	if a == "" {
+		return digits[base/2 : base/2+1]
+	}
+	if d := digit(a, 0); d < base-1 {
+		return digits[d+1 : d+2]
+	}
+	return digits[base-1:] + after(a[1:])
+}
+
+// midpoint assumes a < b, with a possibly empty.
+func midpoint(a, b string) string {
+	n := 0
+	for n < len(b) && digit(a, n) == digit(b, n) {
+		n++
+	}
+	if n > 0 {
+		rest := ""
+		if n < len(a) {
+			rest = a[n:]
+		}
+		return b[:n] + midpoint(rest, b[n:])
+	}
+
+	da, db := digit(a, 0), base
+	if b != "" {
+		db = digit(b, 0)
+	}
+	if db-da > 1 {
+		mid := (da + db) / 2
+		return digits[mid : mid+1]
+	}
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return digits[da:da+1] + midpoint(rest, "")
+}
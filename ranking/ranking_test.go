@@ -0,0 +1,112 @@
+package ranking
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"", ""},
+		{"", "V"},
+		{"", "1"},
+		{"", "01"},
+		{"V", ""},
+		{"z", ""},
+		{"zz", ""},
+		{"A", "B"},
+		{"A", "A1"},
+		{"A1", "B"},
+		{"Az", "B"},
+		{"0z", "1"},
+		{"V", "W"},
+	}
+
+	for _, tt := range tests {
+		key, err := Between(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("Between(%q, %q) returned %v", tt.a, tt.b, err)
+			continue
+		}
+		if key <= tt.a || (tt.b != "" && key >= tt.b) {
+			t.Errorf("Between(%q, %q) = %q, not between", tt.a, tt.b, key)
+		}
+		if strings.HasSuffix(key, "0") {
+			t.Errorf("Between(%q, %q) = %q ends in 0", tt.a, tt.b, key)
+		}
+	}
+}
+
+func TestBetweenRejectsBadBounds(t *testing.T) {
+	for _, bounds := range [][2]string{{"B", "A"}, {"A", "A"}, {"A0", ""}, {"", "a-b"}} {
+		if _, err := Between(bounds[0], bounds[1]); err == nil {
+			t.Errorf("Between(%q, %q) should fail", bounds[0], bounds[1])
+		}
+	}
+}
+
+func TestRandomInsertsStayOrdered(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	keys := []string{}
+
+	for i := 0; i < 2000; i++ {
+		pos := rng.Intn(len(keys) + 1)
+		var a, b string
+		if pos > 0 {
+			a = keys[pos-1]
+		}
+		if pos < len(keys) {
+			b = keys[pos]
+		}
+
+		key, err := Between(a, b)
+		if err != nil {
+			t.Fatalf("Between(%q, %q) returned %v", a, b, err)
+		}
+		keys = append(keys[:pos], append([]string{key}, keys[pos:]...)...)
+	}
+
+	if !sort.StringsAreSorted(keys) {
+		t.Fatal("Keys are out of order")
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i] == keys[i-1] {
+			t.Fatalf("Duplicate key %q", keys[i])
+		}
+	}
+}
+
+func TestPrependGrowsSlowly(t *testing.T) {
+	key := ""
+	for i := 0; i < 200; i++ {
+		next, err := Between("", key)
+		if err != nil {
+			t.Fatalf("Between returned %v", err)
+		}
+		key = next
+	}
+	if len(key) > 8 {
+		t.Errorf("Expected short keys after 200 prepends, got %q", key)
+	}
+}
+
+func TestSpread(t *testing.T) {
+	for _, n := range []int{0, 1, 61, 62, 1000} {
+		keys := Spread(n)
+		if len(keys) != n {
+			t.Fatalf("Spread(%d) returned %d keys", n, len(keys))
+		}
+		for i, key := range keys {
+			if key == "" || strings.HasSuffix(key, "0") {
+				t.Errorf("Spread(%d) produced invalid key %q", n, key)
+			}
+			if i > 0 && keys[i-1] >= key {
+				t.Errorf("Spread(%d) keys out of order: %q >= %q", n, keys[i-1], key)
+			}
+		}
+	}
+}
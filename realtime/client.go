@@ -0,0 +1,122 @@
+package realtime
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+
+	"test-server/models"
+)
+
+// Client is one connection's view of the hub. Its messages queue on a
+// bounded channel; a client that lets it fill up is dropped rather than
+// allowed to hold up everyone else.
+type Client struct {
+	tenantID string
+
+	mu    sync.Mutex
+	lists map[int]bool
+	todos map[int]bool
+
+	send     chan []byte
+	dropped  chan struct{}
+	dropOnce sync.Once
+}
+
+// Send yields the encoded messages to write to the connection.
+func (c *Client) Send() <-chan []byte {
+	return c.send
+}
+
+// Dropped is closed once the client fell too far behind.
+func (c *Client) Dropped() <-chan struct{} {
+	return c.dropped
+}
+
+// Deliver queues an encoded message without blocking. It reports false, and
+// drops the client, if the queue is full.
+func (c *Client) Deliver(data []byte) bool {
+	select {
+	case <-c.dropped:
+		return false
+	default:
+	}
+	select {
+	case c.send <- data:
+		return true
+	default:
+		c.dropOnce.Do(func() { close(c.dropped) })
+		return false
+	}
+}
+
+// Subscribe adds lists and todos to the client's subscriptions. List
+// models.InboxListID stands for the inbox.
+func (c *Client) Subscribe(lists, todos []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range lists {
+		c.lists[id] = true
+	}
+	for _, id := range todos {
+		c.todos[id] = true
+	}
+}
+
+func (c *Client) Unsubscribe(lists, todos []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range lists {
+		delete(c.lists, id)
+	}
+	for _, id := range todos {
+		delete(c.todos, id)
+	}
+}
+
+// Subscriptions returns the subscribed lists and todos, sorted.
+func (c *Client) Subscriptions() (lists, todos []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.lists {
+		lists = append(lists, id)
+	}
+	for id := range c.todos {
+		todos = append(todos, id)
+	}
+	slices.Sort(lists)
+	slices.Sort(todos)
+	return lists, todos
+}
+
+// wants reports whether the event concerns a subscribed todo, a subtask of
+// one, or a todo in, or moved out of, a subscribed list.
+func (c *Client) wants(event models.TodoEvent) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.todos[event.TodoID] {
+		return true
+	}
+	if todo := event.Todo; todo != nil {
+		if todo.ParentID != nil && c.todos[*todo.ParentID] {
+			return true
+		}
+		if c.lists[listID(todo.ListID)] {
+			return true
+		}
+	}
+	if change, ok := event.Changes["list_id"]; ok {
+		var previous *int
+		if json.Unmarshal(change.Before, &previous) == nil && c.lists[listID(previous)] {
+			return true
+		}
+	}
+	return false
+}
+
+func listID(id *int) int {
+	if id == nil {
+		return models.InboxListID
+	}
+	return *id
+}
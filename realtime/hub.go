@@ -0,0 +1,171 @@
+// Package realtime routes todo events to WebSocket clients according to
+// the lists and todos each client subscribed to.
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+
+	"test-server/models"
+	"test-server/outbox"
+)
+
+// Message types sent to clients.
+const (
+	MessageEvent      = "event"
+	MessageSubscribed = "subscribed"
+	MessageUpdated    = "updated"
+	MessageError      = "error"
+	// MessageResync tells clients events were lost and they should refetch.
+	MessageResync = "resync"
+)
+
+// Message is what clients receive. Ref echoes the command it answers.
+type Message struct {
+	Type   string            `json:"type"`
+	Ref    string            `json:"ref,omitempty"`
+	Event  *models.TodoEvent `json:"event,omitempty"`
+	Todo   *models.Todo      `json:"todo,omitempty"`
+	Lists  []int             `json:"lists,omitempty"`
+	Todos  []int             `json:"todos,omitempty"`
+	Status int               `json:"status,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+type EventSource interface {
+	Subscribe(buffer int) *outbox.Subscription
+}
+
+// Hub reads events from the bus and hands each one to the clients of its
+// tenant that subscribed to the todo, its parent or its list.
+type Hub struct {
+	source EventSource
+	// Buffer is how far the hub itself may fall behind the bus before it
+	// loses events and has every client resync.
+	Buffer int
+
+	mu      sync.Mutex
+	tenants map[string]map[*Client]struct{}
+	start   sync.Once
+	done    chan struct{}
+	stop    sync.Once
+}
+
+func NewHub(source EventSource) *Hub {
+	return &Hub{
+		source:  source,
+		Buffer:  1024,
+		tenants: map[string]map[*Client]struct{}{},
+		done:    make(chan struct{}),
+	}
+}
+
+// Join registers a client for tenantID that can fall buffer messages
+// behind before it is dropped. The hub starts reading events on the first
+// join.
+func (h *Hub) Join(tenantID string, buffer int) *Client {
+	h.start.Do(func() { go h.run(h.source.Subscribe(h.Buffer)) })
+
+	c := &Client{
+		tenantID: tenantID,
+		lists:    map[int]bool{},
+		todos:    map[int]bool{},
+		send:     make(chan []byte, buffer),
+		dropped:  make(chan struct{}),
+	}
+	h.mu.Lock()
+	clients := h.tenants[tenantID]
+	if clients == nil {
+		clients = map[*Client]struct{}{}
+		h.tenants[tenantID] = clients
+	}
+	clients[c] = struct{}{}
+	h.mu.Unlock()
+	return c
+}
+
+// Leave unregisters a client.
+func (h *Hub) Leave(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if clients := h.tenants[c.tenantID]; clients != nil {
+		delete(clients, c)
+		if len(clients) == 0 {
+			delete(h.tenants, c.tenantID)
+		}
+	}
+}
+
+// Close stops the hub reading events.
+func (h *Hub) Close() {
+	h.stop.Do(func() { close(h.done) })
+}
+
+func (h *Hub) run(sub *outbox.Subscription) {
+	for h.pump(sub) {
+		// The bus dropped the hub, so some events never reached anyone.
+		log.Printf("realtime: hub fell behind the event bus, asking clients to resync")
+		sub = h.source.Subscribe(h.Buffer)
+		h.broadcastAll(Message{Type: MessageResync})
+	}
+	sub.Close()
+}
+
+// pump forwards events until the subscription is dropped, returning false
+// once the hub is closed.
+func (h *Hub) pump(sub *outbox.Subscription) bool {
+	for {
+		select {
+		case <-h.done:
+			return false
+		case event, ok := <-sub.C:
+			if !ok {
+				return true
+			}
+			h.Broadcast(event)
+		}
+	}
+}
+
+// Broadcast delivers event to the interested clients of its tenant.
+func (h *Hub) Broadcast(event models.TodoEvent) {
+	h.mu.Lock()
+	var targets []*Client
+	for c := range h.tenants[event.TenantID] {
+		if c.wants(event) {
+			targets = append(targets, c)
+		}
+	}
+	h.mu.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(Message{Type: MessageEvent, Event: &event})
+	if err != nil {
+		log.Printf("realtime: failed to encode event %s: %v", event.ID, err)
+		return
+	}
+	for _, c := range targets {
+		c.Deliver(data)
+	}
+}
+
+func (h *Hub) broadcastAll(msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	var targets []*Client
+	for _, clients := range h.tenants {
+		for c := range clients {
+			targets = append(targets, c)
+		}
+	}
+	h.mu.Unlock()
+	for _, c := range targets {
+		c.Deliver(data)
+	}
+}
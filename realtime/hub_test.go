@@ -0,0 +1,120 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"test-server/models"
+	"test-server/outbox"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestClientWants(t *testing.T) {
+	hub := NewHub(outbox.NewBus())
+	c := hub.Join("default", 4)
+	c.Subscribe([]int{3, models.InboxListID}, []int{10})
+
+	movedOut := models.TodoEvent{TodoID: 2, Todo: &models.Todo{ListID: intPtr(4)},
+		Changes: map[string]models.AuditChange{"list_id": {Before: json.RawMessage("3"), After: json.RawMessage("4")}}}
+
+	tests := []struct {
+		name  string
+		event models.TodoEvent
+		want  bool
+	}{
+		{"subscribed list", models.TodoEvent{TodoID: 1, Todo: &models.Todo{ListID: intPtr(3)}}, true},
+		{"inbox", models.TodoEvent{TodoID: 1, Todo: &models.Todo{}}, true},
+		{"other list", models.TodoEvent{TodoID: 1, Todo: &models.Todo{ListID: intPtr(4)}}, false},
+		{"subscribed todo", models.TodoEvent{TodoID: 10, Todo: &models.Todo{ListID: intPtr(4)}}, true},
+		{"subtask", models.TodoEvent{TodoID: 11, Todo: &models.Todo{ListID: intPtr(4), ParentID: intPtr(10)}}, true},
+		{"moved out of list", movedOut, true},
+	}
+	for _, tt := range tests {
+		if got := c.wants(tt.event); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestBroadcastStaysWithinTenant(t *testing.T) {
+	bus := outbox.NewBus()
+	hub := NewHub(bus)
+	defer hub.Close()
+	mine := hub.Join("acme", 4)
+	mine.Subscribe([]int{3}, nil)
+	theirs := hub.Join("globex", 4)
+	theirs.Subscribe([]int{3}, nil)
+
+	bus.Publish(context.Background(), models.TodoEvent{ID: "e1", TenantID: "acme", TodoID: 1,
+		Todo: &models.Todo{ListID: intPtr(3)}})
+
+	select {
+	case data := <-mine.Send():
+		var msg Message
+		json.Unmarshal(data, &msg)
+		if msg.Type != MessageEvent || msg.Event.ID != "e1" {
+			t.Errorf("Unexpected message %s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the event to be delivered")
+	}
+	select {
+	case data := <-theirs.Send():
+		t.Errorf("Expected no event for another tenant, got %s", data)
+	default:
+	}
+}
+
+func TestSlowClientIsDropped(t *testing.T) {
+	hub := NewHub(outbox.NewBus())
+	c := hub.Join("default", 1)
+	c.Subscribe(nil, []int{1})
+
+	hub.Broadcast(models.TodoEvent{TenantID: "default", TodoID: 1})
+	select {
+	case <-c.Dropped():
+		t.Fatal("Expected the client to keep up with one event")
+	default:
+	}
+
+	hub.Broadcast(models.TodoEvent{TenantID: "default", TodoID: 1})
+	select {
+	case <-c.Dropped():
+	default:
+		t.Fatal("Expected the client to be dropped")
+	}
+	if c.Deliver([]byte("{}")) {
+		t.Error("Expected no deliveries after the drop")
+	}
+}
+
+func TestHubResyncsAfterFallingBehind(t *testing.T) {
+	bus := outbox.NewBus()
+	hub := NewHub(bus)
+	hub.Buffer = 1
+	defer hub.Close()
+	c := hub.Join("default", 8)
+
+	// Hold the hub's lock so that it cannot drain the bus.
+	hub.mu.Lock()
+	for range 3 {
+		bus.Publish(context.Background(), models.TodoEvent{TenantID: "default"})
+	}
+	hub.mu.Unlock()
+
+	select {
+	case data := <-c.Send():
+		var msg Message
+		json.Unmarshal(data, &msg)
+		if msg.Type != MessageResync {
+			t.Errorf("Expected resync, got %s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a resync message")
+	}
+}
@@ -0,0 +1,357 @@
+// Package recurrence parses the subset of RFC 5545 recurrence rules that
+// repeating todos use and computes their next occurrence.
+//
+// Supported parts are FREQ (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL,
+// BYDAY (with ordinals such as 1MO or -1FR for MONTHLY rules), BYMONTHDAY
+// (MONTHLY only), WKST, COUNT and UNTIL. Occurrences keep the wall-clock time
+// of the occurrence they follow in the rule's location.
+package recurrence
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods bounds the search for an occurrence, so rules such as the 31st
+// of February end instead of looping.
+const maxPeriods = 1000
+
+// Day is a BYDAY entry. Ordinal 0 means every such weekday; otherwise it
+// picks the nth (or, when negative, nth from last) in the month.
+type Day struct {
+	Weekday time.Weekday
+	Ordinal int
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []Day
+	ByMonthDay []int
+	WeekStart  time.Weekday
+	// Count limits the series to this many occurrences; 0 means unlimited.
+	Count int
+	Until *time.Time
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+var shorthands = map[string]Frequency{
+	"daily": Daily, "weekly": Weekly, "monthly": Monthly, "yearly": Yearly,
+}
+
+// Parse accepts "daily", "weekly", "monthly" or "yearly", or an RRULE with
+// or without its "RRULE:" prefix.
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimSpace(value)
+	if freq, ok := shorthands[strings.ToLower(value)]; ok {
+		return &Rule{Freq: freq, Interval: 1, WeekStart: time.Monday}, nil
+	}
+
+	if len(value) >= 6 && strings.EqualFold(value[:6], "RRULE:") {
+		value = value[6:]
+	}
+	if value == "" {
+		return nil, fmt.Errorf("recurrence rule is empty")
+	}
+
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		val = strings.ToUpper(strings.TrimSpace(val))
+		if !ok || val == "" {
+			return nil, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("recurrence rule repeats %s", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			rule.Freq = Frequency(val)
+			switch rule.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				err = fmt.Errorf("unsupported FREQ %q", val)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+			if err != nil || rule.Interval < 1 {
+				err = fmt.Errorf("INTERVAL must be a positive integer")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+			if err != nil || rule.Count < 1 {
+				err = fmt.Errorf("COUNT must be a positive integer")
+			}
+		case "UNTIL":
+			rule.Until, err = parseUntil(val)
+		case "WKST":
+			day, ok := weekdays[val]
+			if !ok {
+				err = fmt.Errorf("invalid WKST %q", val)
+			}
+			rule.WeekStart = day
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(val)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseByMonthDay(val)
+		default:
+			err = fmt.Errorf("unsupported recurrence rule part %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("recurrence rule needs FREQ")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("COUNT and UNTIL cannot both be set")
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq != Monthly {
+		return nil, fmt.Errorf("BYMONTHDAY is only supported with FREQ=MONTHLY")
+	}
+	if rule.Freq == Yearly && len(rule.ByDay) > 0 {
+		return nil, fmt.Errorf("BYDAY is not supported with FREQ=YEARLY")
+	}
+	for _, day := range rule.ByDay {
+		if day.Ordinal != 0 && rule.Freq != Monthly {
+			return nil, fmt.Errorf("BYDAY ordinals are only supported with FREQ=MONTHLY")
+		}
+	}
+
+	return rule, nil
+}
+
+func parseUntil(value string) (*time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				// A date-only UNTIL includes that whole day.
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+func parseByDay(value string) ([]Day, error) {
+	var days []Day
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+		weekday, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+		day := Day{Weekday: weekday}
+		if prefix := item[:len(item)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("invalid BYDAY %q", item)
+			}
+			day.Ordinal = n
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func parseByMonthDay(value string) ([]int, error) {
+	var monthDays []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n == 0 || n < -31 || n > 31 {
+			return nil, fmt.Errorf("invalid BYMONTHDAY %q", item)
+		}
+		monthDays = append(monthDays, n)
+	}
+	return monthDays, nil
+}
+
+// String renders the rule as a canonical RRULE value without the prefix.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = dayName(day.Weekday)
+			if day.Ordinal != 0 {
+				days[i] = strconv.Itoa(day.Ordinal) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		monthDays := make([]string, len(r.ByMonthDay))
+		for i, n := range r.ByMonthDay {
+			monthDays[i] = strconv.Itoa(n)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(monthDays, ","))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+dayName(r.WeekStart))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+func dayName(weekday time.Weekday) string {
+	for name, day := range weekdays {
+		if day == weekday {
+			return name
+		}
+	}
+	return ""
+}
+
+// Next returns the first occurrence following from, an occurrence of the
+// series, that is also after after. It reports false when the series ends
+// first. COUNT is not applied here since only the caller knows how many
+// occurrences came before.
+func (r *Rule) Next(from, after time.Time, loc *time.Location) (time.Time, bool) {
+	start := from.In(loc)
+	for period := 0; period < maxPeriods; period++ {
+		for _, candidate := range r.expand(start, period) {
+			if !candidate.After(start) || !candidate.After(after) {
+				continue
+			}
+			if r.Until != nil && candidate.After(*r.Until) {
+				return time.Time{}, false
+			}
+			return candidate.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// expand returns the candidate occurrences, in order, in the period that is
+// n intervals after the one containing start.
+func (r *Rule) expand(start time.Time, n int) []time.Time {
+	h, m, s := start.Clock()
+	loc := start.Location()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, h, m, s, 0, loc)
+	}
+
+	switch r.Freq {
+	case Daily:
+		day := at(start.Year(), start.Month(), start.Day()+n*r.Interval)
+		if len(r.ByDay) > 0 && !r.hasWeekday(day.Weekday()) {
+			return nil
+		}
+		return []time.Time{day}
+
+	case Weekly:
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := at(start.Year(), start.Month(), start.Day()-offset+7*n*r.Interval)
+		if len(r.ByDay) == 0 {
+			return []time.Time{weekStart.AddDate(0, 0, offset)}
+		}
+		var days []time.Time
+		for _, day := range r.ByDay {
+			days = append(days, weekStart.AddDate(0, 0, (int(day.Weekday)-int(r.WeekStart)+7)%7))
+		}
+		return sortDays(days)
+
+	case Monthly:
+		first := at(start.Year(), start.Month()+time.Month(n*r.Interval), 1)
+		year, month := first.Year(), first.Month()
+		daysIn := at(year, month+1, 0).Day()
+
+		monthDays := map[int]bool{}
+		for _, md := range r.ByMonthDay {
+			if md < 0 {
+				md += daysIn + 1
+			}
+			monthDays[md] = true
+		}
+		if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+			monthDays[start.Day()] = true
+		}
+
+		var days []time.Time
+		for day := 1; day <= daysIn; day++ {
+			date := at(year, month, day)
+			if len(r.ByMonthDay) > 0 || len(r.ByDay) == 0 {
+				if !monthDays[day] {
+					continue
+				}
+			}
+			if len(r.ByDay) > 0 && !r.matchesMonthlyDay(date, daysIn) {
+				continue
+			}
+			days = append(days, date)
+		}
+		return days
+
+	case Yearly:
+		year := start.Year() + n*r.Interval
+		day := at(year, start.Month(), start.Day())
+		if day.Month() != start.Month() {
+			// 29 February only occurs in leap years.
+			return nil
+		}
+		return []time.Time{day}
+	}
+	return nil
+}
+
+func (r *Rule) hasWeekday(weekday time.Weekday) bool {
+	for _, day := range r.ByDay {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthlyDay(date time.Time, daysIn int) bool {
+	nth := (date.Day()-1)/7 + 1
+	nthFromLast := -((daysIn-date.Day())/7 + 1)
+	for _, day := range r.ByDay {
+		if day.Weekday != date.Weekday() {
+			continue
+		}
+		if day.Ordinal == 0 || day.Ordinal == nth || day.Ordinal == nthFromLast {
+			return true
+		}
+	}
+	return false
+}
+
+func sortDays(days []time.Time) []time.Time {
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
@@ -0,0 +1,113 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, value string) *Rule {
+	t.Helper()
+	rule, err := Parse(value)
+	if err != nil {
+		t.Fatalf("Parse(%q) returned %v", value, err)
+	}
+	return rule
+}
+
+func TestParseCanonicalises(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"daily", "FREQ=DAILY"},
+		{"Weekly", "FREQ=WEEKLY"},
+		{"RRULE:FREQ=WEEKLY;BYDAY=MO,WE", "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{"freq=monthly;byday=-1fr;interval=2", "FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1;COUNT=6", "FREQ=MONTHLY;BYMONTHDAY=1,-1;COUNT=6"},
+		{"FREQ=DAILY;UNTIL=20261231T000000Z", "FREQ=DAILY;UNTIL=20261231T000000Z"},
+	}
+
+	for _, tt := range tests {
+		if got := mustParse(t, tt.in).String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"hourly",
+		"FREQ=HOURLY",
+		"INTERVAL=2",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20261231",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=DAILY;FREQ=WEEKLY",
+	} {
+		if _, err := Parse(value); err == nil {
+			t.Errorf("Parse(%q) should fail", value)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// Thursday 15 January 2026, 09:30 UTC.
+	from := time.Date(2026, 1, 15, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		rule  string
+		after time.Time
+		want  time.Time
+	}{
+		{"daily", from, time.Date(2026, 1, 16, 9, 30, 0, 0, time.UTC)},
+		{"FREQ=DAILY;INTERVAL=3", from, time.Date(2026, 1, 18, 9, 30, 0, 0, time.UTC)},
+		{"FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", from.AddDate(0, 0, 1), time.Date(2026, 1, 19, 9, 30, 0, 0, time.UTC)},
+		{"weekly", from, time.Date(2026, 1, 22, 9, 30, 0, 0, time.UTC)},
+		{"FREQ=WEEKLY;BYDAY=MO,FR", from, time.Date(2026, 1, 16, 9, 30, 0, 0, time.UTC)},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", from, time.Date(2026, 1, 26, 9, 30, 0, 0, time.UTC)},
+		{"monthly", from, time.Date(2026, 2, 15, 9, 30, 0, 0, time.UTC)},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", from, time.Date(2026, 1, 31, 9, 30, 0, 0, time.UTC)},
+		{"FREQ=MONTHLY;BYDAY=-1FR", from, time.Date(2026, 1, 30, 9, 30, 0, 0, time.UTC)},
+		{"FREQ=MONTHLY;BYDAY=1MO", from, time.Date(2026, 2, 2, 9, 30, 0, 0, time.UTC)},
+		{"yearly", from, time.Date(2027, 1, 15, 9, 30, 0, 0, time.UTC)},
+		// Completing late skips the occurrences already missed.
+		{"daily", from.AddDate(0, 0, 5), time.Date(2026, 1, 21, 9, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, ok := mustParse(t, tt.rule).Next(from, tt.after, time.UTC)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("%s: Next = %v, %v; want %v", tt.rule, got, ok, tt.want)
+		}
+	}
+}
+
+func TestNextSkipsShortMonths(t *testing.T) {
+	from := time.Date(2026, 1, 31, 8, 0, 0, 0, time.UTC)
+	got, ok := mustParse(t, "monthly").Next(from, from, time.UTC)
+	if !ok || !got.Equal(time.Date(2026, 3, 31, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 31 March, got %v", got)
+	}
+}
+
+func TestNextKeepsLocalTimeAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("tzdata unavailable")
+	}
+	// 09:00 in Berlin the day before clocks go forward.
+	from := time.Date(2026, 3, 28, 9, 0, 0, 0, loc)
+	got, ok := mustParse(t, "daily").Next(from, from, loc)
+	if !ok || got.In(loc).Hour() != 9 {
+		t.Errorf("Expected 09:00 local, got %v", got.In(loc))
+	}
+}
+
+func TestNextStopsAtUntil(t *testing.T) {
+	from := time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)
+	if _, ok := mustParse(t, "FREQ=WEEKLY;UNTIL=20260120").Next(from, from, time.UTC); ok {
+		t.Error("Expected the series to end")
+	}
+}
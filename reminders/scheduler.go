@@ -0,0 +1,118 @@
+package reminders
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"test-server/models"
+	"test-server/tenant"
+)
+
+type Store interface {
+	DueReminders(ctx context.Context, now time.Time, limit int) ([]models.Todo, error)
+	ClaimReminder(ctx context.Context, id int, now time.Time) (bool, error)
+	NextReminderAt(ctx context.Context) (*time.Time, error)
+}
+
+// Notifier receives a reminder event once remind_at has passed.
+type Notifier interface {
+	Remind(ctx context.Context, todo models.Todo) error
+}
+
+type LogNotifier struct{}
+
+func (LogNotifier) Remind(ctx context.Context, todo models.Todo) error {
+	log.Printf("Reminder: todo %d (%s) for tenant %s", todo.ID, todo.Title, todo.TenantID)
+	return nil
+}
+
+// Scheduler fires reminders. All state lives in the database, so reminders
+// that came due while the process was down fire on the next start.
+type Scheduler struct {
+	store    Store
+	notifier Notifier
+	// MaxSleep bounds how long the scheduler sleeps without re-reading the
+	// database, covering writes from other instances.
+	MaxSleep  time.Duration
+	BatchSize int
+
+	wake chan struct{}
+	now  func() time.Time
+}
+
+func NewScheduler(store Store, notifier Notifier) *Scheduler {
+	return &Scheduler{
+		store:     store,
+		notifier:  notifier,
+		MaxSleep:  time.Minute,
+		BatchSize: 100,
+		wake:      make(chan struct{}, 1),
+		now:       time.Now,
+	}
+}
+
+// Wake makes the scheduler re-plan immediately. It never blocks.
+func (s *Scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		if err := s.fireDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to fire reminders: %v", err)
+		}
+
+		timer := time.NewTimer(s.sleepDuration(ctx))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Scheduler) fireDue(ctx context.Context) error {
+	for {
+		now := s.now().UTC()
+		todos, err := s.store.DueReminders(ctx, now, s.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, todo := range todos {
+			claimed, err := s.store.ClaimReminder(tenant.WithID(ctx, todo.TenantID), todo.ID, now)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+			if err := s.notifier.Remind(ctx, todo); err != nil {
+				log.Printf("Failed to deliver reminder for todo %d: %v", todo.ID, err)
+			}
+		}
+
+		if len(todos) < s.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *Scheduler) sleepDuration(ctx context.Context) time.Duration {
+	next, err := s.store.NextReminderAt(ctx)
+	if err != nil || next == nil {
+		return s.MaxSleep
+	}
+	d := next.Sub(s.now())
+	if d < 0 {
+		return 0
+	}
+	return min(d, s.MaxSleep)
+}
@@ -0,0 +1,132 @@
+package reminders
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"test-server/models"
+	"test-server/tenant"
+)
+
+type memoryStore struct {
+	mu    sync.Mutex
+	todos []models.Todo
+	// claimedBy records the tenant each claim was made under.
+	claimedBy []string
+}
+
+func (s *memoryStore) DueReminders(ctx context.Context, now time.Time, limit int) ([]models.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []models.Todo
+	for _, todo := range s.todos {
+		if todo.RemindAt != nil && !todo.RemindAt.After(now) && todo.ReminderSentAt == nil && len(due) < limit {
+			due = append(due, todo)
+		}
+	}
+	return due, nil
+}
+
+func (s *memoryStore) ClaimReminder(ctx context.Context, id int, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claimedBy = append(s.claimedBy, tenant.IDFromContext(ctx))
+	for i := range s.todos {
+		if s.todos[i].ID == id && s.todos[i].ReminderSentAt == nil {
+			s.todos[i].ReminderSentAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryStore) NextReminderAt(ctx context.Context) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next *time.Time
+	for _, todo := range s.todos {
+		if todo.RemindAt != nil && todo.ReminderSentAt == nil && (next == nil || todo.RemindAt.Before(*next)) {
+			next = todo.RemindAt
+		}
+	}
+	return next, nil
+}
+
+type recordingNotifier struct {
+	fired chan int
+}
+
+func (n *recordingNotifier) Remind(ctx context.Context, todo models.Todo) error {
+	n.fired <- todo.ID
+	return nil
+}
+
+func TestSchedulerFiresDueRemindersOnce(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	store := &memoryStore{todos: []models.Todo{
+		{ID: 1, RemindAt: &past},
+		{ID: 2, RemindAt: &future},
+	}}
+	notifier := &recordingNotifier{fired: make(chan int, 10)}
+
+	scheduler := NewScheduler(store, notifier)
+	if err := scheduler.fireDue(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := scheduler.fireDue(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	close(notifier.fired)
+	var fired []int
+	for id := range notifier.fired {
+		fired = append(fired, id)
+	}
+	if len(fired) != 1 || fired[0] != 1 {
+		t.Errorf("Expected only todo 1 to fire once, got %v", fired)
+	}
+}
+
+func TestSchedulerWakesForNewReminder(t *testing.T) {
+	store := &memoryStore{}
+	notifier := &recordingNotifier{fired: make(chan int, 1)}
+
+	scheduler := NewScheduler(store, notifier)
+	scheduler.MaxSleep = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go scheduler.Run(ctx)
+
+	soon := time.Now().Add(20 * time.Millisecond)
+	store.mu.Lock()
+	store.todos = append(store.todos, models.Todo{ID: 7, RemindAt: &soon})
+	store.mu.Unlock()
+	scheduler.Wake()
+
+	select {
+	case id := <-notifier.fired:
+		if id != 7 {
+			t.Errorf("Expected todo 7, got %d", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Reminder did not fire")
+	}
+}
+
+func TestSchedulerClaimsUnderTheTodosTenant(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	store := &memoryStore{todos: []models.Todo{{ID: 1, TenantID: "acme", RemindAt: &past}}}
+
+	scheduler := NewScheduler(store, &recordingNotifier{fired: make(chan int, 1)})
+	if err := scheduler.fireDue(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(store.claimedBy) != 1 || store.claimedBy[0] != "acme" {
+		t.Errorf("Expected one claim for acme, got %v", store.claimedBy)
+	}
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"test-server/auth"
+	"test-server/models"
+	"test-server/requestid"
+	"test-server/tenant"
+)
+
+const auditColumns = `id, tenant_id, todo_id, actor, action, changes, request_id, created_at`
+
+// auditIgnored are todo fields left out of audit diffs because they never
+// change or only change as a side effect of other changes. Positions
+// renumbered by a rebalance are not audited either, as the order stays the
+// same.
+var auditIgnored = []string{"id", "tenant_id", "created_at", "updated_at", "comment_count"}
+
+var jsonNull = json.RawMessage("null")
+
+func auditFields(todo *models.Todo) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if todo == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode todo for audit: %w", err)
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode todo for audit: %w", err)
+	}
+	for _, name := range auditIgnored {
+		delete(fields, name)
+	}
+	return fields, nil
+}
+
+// todoChanges diffs two snapshots of a todo, either of which may be nil.
+func todoChanges(before, after *models.Todo) (map[string]models.AuditChange, error) {
+	old, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for name := range old {
+		names[name] = true
+	}
+	for name := range cur {
+		names[name] = true
+	}
+
+	changes := map[string]models.AuditChange{}
+	for name := range names {
+		b, a := old[name], cur[name]
+		if b == nil {
+			b = jsonNull
+		}
+		if a == nil {
+			a = jsonNull
+		}
+		if !bytes.Equal(b, a) {
+			changes[name] = models.AuditChange{Before: b, After: a}
+		}
+	}
+	return changes, nil
+}
+
+// actor names who is making the change.
+func actor(ctx context.Context) string {
+	if user := auth.UserID(ctx); user != "" {
+		return user
+	}
+	return models.AnonymousUser
+}
+
+// writeAudit records a change to a todo as part of the caller's transaction,
+// along with its event and the todo's new revision unless it was deleted.
+// Updates that change nothing are not recorded.
+func writeAudit(ctx context.Context, q querier, tenantID, action string, todoID int, before, after *models.Todo) error {
+	changes, err := todoChanges(before, after)
+	if err != nil {
+		return err
+	}
+	if action == models.AuditUpdate && len(changes) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit changes: %w", err)
+	}
+
+	_, err = q.ExecContext(ctx, `INSERT INTO todo_audit (tenant_id, todo_id, actor, action, changes, request_id)
+		VALUES (?, ?, ?, ?, ?, ?)`, tenantID, todoID, actor(ctx), action, data, requestid.FromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	if err := writeOutbox(ctx, q, tenantID, action, todoID, before, after, changes); err != nil {
+		return err
+	}
+
+	if after != nil {
+		return writeRevision(ctx, q, tenantID, after)
+	}
+	return nil
+}
+
+// auditUpdate reloads a todo after it was updated and records the update
+// against before. It returns the reloaded todo.
+func auditUpdate(ctx context.Context, q querier, tenantID string, before *models.Todo) (*models.Todo, error) {
+	after, err := getTodo(ctx, q, before.ID, tenantID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAudit(ctx, q, tenantID, models.AuditUpdate, before.ID, before, after); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+func scanAuditEntry(row rowScanner) (*models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var changes []byte
+	err := row.Scan(&entry.ID, &entry.TenantID, &entry.TodoID, &entry.Actor, &entry.Action, &changes,
+		&entry.RequestID, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+		return nil, fmt.Errorf("failed to decode audit changes: %w", err)
+	}
+	return &entry, nil
+}
+
+func (r *TodoRepository) queryAudit(ctx context.Context, query string, args ...interface{}) ([]models.AuditEntry, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetTodoHistory returns a todo's audit entries, oldest first. The history
+// outlives the todo, so deleted todos still have one.
+func (r *TodoRepository) GetTodoHistory(ctx context.Context, todoID int) ([]models.AuditEntry, error) {
+	query := `SELECT ` + auditColumns + ` FROM todo_audit WHERE tenant_id = ? AND todo_id = ? ORDER BY id`
+	return r.queryAudit(ctx, query, tenant.IDFromContext(ctx), todoID)
+}
+
+// GetAuditLog returns the tenant's audit entries matching filter, newest
+// first.
+func (r *TodoRepository) GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	where := []string{"tenant_id = ?"}
+	args := []interface{}{tenant.IDFromContext(ctx)}
+
+	if filter.TodoID != nil {
+		where = append(where, "todo_id = ?")
+		args = append(args, *filter.TodoID)
+	}
+	if filter.Actor != "" {
+		where = append(where, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.RequestID != "" {
+		where = append(where, "request_id = ?")
+		args = append(args, filter.RequestID)
+	}
+	if filter.Since != nil {
+		where = append(where, "created_at >= ?")
+		args = append(args, *filter.Since)
+	}
+	if filter.Until != nil {
+		where = append(where, "created_at < ?")
+		args = append(args, *filter.Until)
+	}
+	if filter.BeforeID > 0 {
+		where = append(where, "id < ?")
+		args = append(args, filter.BeforeID)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM todo_audit WHERE ` + strings.Join(where, " AND ") + ` ORDER BY id DESC LIMIT ?`
+	args = append(args, filter.Limit)
+	return r.queryAudit(ctx, query, args...)
+}
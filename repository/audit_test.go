@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"test-server/auth"
+	"test-server/models"
+	"test-server/requestid"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var auditRowColumns = []string{"id", "tenant_id", "todo_id", "actor", "action", "changes", "request_id", "created_at"}
+
+func TestTodoChanges(t *testing.T) {
+	now := time.Now()
+	before := &models.Todo{ID: 1, Title: "Old", Status: models.StatusTodo, Tags: []string{}, CreatedAt: now, UpdatedAt: now}
+	after := *before
+	after.Title = "New"
+	after.Tags = []string{"home"}
+	after.UpdatedAt = now.Add(time.Minute)
+
+	changes, err := todoChanges(before, &after)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("Expected title and tags to change, got %v", changes)
+	}
+	if string(changes["title"].Before) != `"Old"` || string(changes["title"].After) != `"New"` {
+		t.Errorf("Unexpected title change %+v", changes["title"])
+	}
+
+	created, err := todoChanges(nil, before)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := created["due_at"]; ok {
+		t.Error("Expected unset fields to be left out of a create")
+	}
+	if string(created["title"].Before) != "null" {
+		t.Errorf("Expected null before on create, got %s", created["title"].Before)
+	}
+}
+
+func TestAuditRecordsActorAndRequestID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ctx := auth.WithClaims(context.Background(), auth.Claims{"sub": "alice"})
+	ctx = requestid.WithID(ctx, "req-1")
+	todo := &models.Todo{ID: 1, Title: "Test Todo"}
+
+	mock.ExpectExec("INSERT INTO todo_audit").
+		WithArgs("default", 1, "alice", models.AuditDelete, sqlmock.AnyArg(), "req-1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO outbox").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := writeAudit(ctx, db, "default", models.AuditDelete, 1, todo, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetAuditLogFilters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+	todoID := 1
+
+	mock.ExpectQuery("SELECT (.+) FROM todo_audit WHERE tenant_id = (.+) AND todo_id = (.+) AND actor = (.+) AND id < (.+) ORDER BY id DESC LIMIT ?").
+		WithArgs("default", 1, "alice", int64(50), 10).
+		WillReturnRows(sqlmock.NewRows(auditRowColumns).
+			AddRow(42, "default", 1, "alice", "update", []byte(`{"title":{"before":"Old","after":"New"}}`), "req-1", now))
+
+	entries, err := repo.GetAuditLog(context.Background(), models.AuditFilter{TodoID: &todoID, Actor: "alice", BeforeID: 50, Limit: 10})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(entries) != 1 || string(entries[0].Changes["title"].After) != `"New"` {
+		t.Errorf("Unexpected entries %+v", entries)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"test-server/models"
+	"test-server/tenant"
+)
+
+const commentColumns = `id, tenant_id, todo_id, author, body, created_at, updated_at`
+
+func scanComment(row rowScanner) (*models.Comment, error) {
+	var comment models.Comment
+	err := row.Scan(&comment.ID, &comment.TenantID, &comment.TodoID, &comment.Author, &comment.Body,
+		&comment.CreatedAt, &comment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func todoExists(ctx context.Context, q querier, tenantID string, todoID int) error {
+	var id int
+	err := q.QueryRowContext(ctx, `SELECT id FROM todos WHERE id = ? AND tenant_id = ?`, todoID, tenantID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("todo not found")
+		}
+		return fmt.Errorf("failed to get todo: %w", err)
+	}
+	return nil
+}
+
+func getComment(ctx context.Context, q querier, tenantID string, todoID, id int, forUpdate bool) (*models.Comment, error) {
+	query := `SELECT ` + commentColumns + ` FROM comments WHERE id = ? AND todo_id = ? AND tenant_id = ?`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	comment, err := scanComment(q.QueryRowContext(ctx, query, id, todoID, tenantID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("comment not found")
+		}
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+	return comment, nil
+}
+
+func (r *TodoRepository) CreateComment(ctx context.Context, todoID int, author string, req *models.CreateCommentRequest) (*models.Comment, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	if err := todoExists(ctx, r.db, tenantID, todoID); err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.ExecContext(ctx, `INSERT INTO comments (tenant_id, todo_id, author, body) VALUES (?, ?, ?, ?)`,
+		tenantID, todoID, author, req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return r.GetComment(ctx, todoID, int(id))
+}
+
+// GetComments returns a todo's comments, oldest first.
+func (r *TodoRepository) GetComments(ctx context.Context, todoID int) ([]models.Comment, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	if err := todoExists(ctx, r.db, tenantID, todoID); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + commentColumns + ` FROM comments WHERE todo_id = ? AND tenant_id = ? ORDER BY created_at, id`
+	rows, err := r.db.QueryContext(ctx, query, todoID, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments = append(comments, *comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+func (r *TodoRepository) GetComment(ctx context.Context, todoID, id int) (*models.Comment, error) {
+	return getComment(ctx, r.db, tenant.IDFromContext(ctx), todoID, id, false)
+}
+
+// ownsComment reports whether author wrote comment. Anonymous comments have
+// no owner, since every anonymous caller shares the name.
+func ownsComment(comment *models.Comment, author string) bool {
+	return author != "" && author != models.AnonymousUser && comment.Author == author
+}
+
+// UpdateComment replaces a comment's body. Only its author may edit it.
+func (r *TodoRepository) UpdateComment(ctx context.Context, todoID, id int, author string, req *models.UpdateCommentRequest) (*models.Comment, error) {
+	tenantID := tenant.IDFromContext(ctx)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getComment(ctx, tx, tenantID, todoID, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if !ownsComment(current, author) {
+		return nil, fmt.Errorf("comment belongs to another author")
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE comments SET body = ? WHERE id = ? AND tenant_id = ?`, req.Body, id, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit comment update: %w", err)
+	}
+
+	return r.GetComment(ctx, todoID, id)
+}
+
+// DeleteComment removes a comment. Only its author may delete it.
+func (r *TodoRepository) DeleteComment(ctx context.Context, todoID, id int, author string) error {
+	tenantID := tenant.IDFromContext(ctx)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getComment(ctx, tx, tenantID, todoID, id, true)
+	if err != nil {
+		return err
+	}
+	if !ownsComment(current, author) {
+		return fmt.Errorf("comment belongs to another author")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = ? AND tenant_id = ?`, id, tenantID); err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit comment delete: %w", err)
+	}
+
+	return nil
+}
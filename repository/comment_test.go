@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"test-server/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var commentRowColumns = []string{"id", "tenant_id", "todo_id", "author", "body", "created_at", "updated_at"}
+
+func TestCreateComment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT id FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO comments").
+		WithArgs("default", 1, "alice", "Looks **good**").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectQuery("SELECT (.+) FROM comments WHERE id = (.+) AND todo_id = (.+) AND tenant_id = ?").
+		WithArgs(7, 1, "default").
+		WillReturnRows(sqlmock.NewRows(commentRowColumns).AddRow(7, "default", 1, "alice", "Looks **good**", now, now))
+
+	comment, err := repo.CreateComment(context.Background(), 1, "alice", &models.CreateCommentRequest{Body: "Looks **good**"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if comment.ID != 7 || comment.Author != "alice" {
+		t.Errorf("Unexpected comment %+v", comment)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetCommentsTodoNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+
+	mock.ExpectQuery("SELECT id FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(9, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = repo.GetComments(context.Background(), 9)
+	if err == nil || err.Error() != "todo not found" {
+		t.Errorf("Expected todo not found, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateCommentByOtherAuthor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM comments WHERE id = (.+) AND todo_id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(7, 1, "default").
+		WillReturnRows(sqlmock.NewRows(commentRowColumns).AddRow(7, "default", 1, "alice", "Hi", now, now))
+	mock.ExpectRollback()
+
+	_, err = repo.UpdateComment(context.Background(), 1, 7, "bob", &models.UpdateCommentRequest{Body: "Edited"})
+	if err == nil || err.Error() != "comment belongs to another author" {
+		t.Errorf("Expected author error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM comments WHERE id = (.+) AND todo_id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(7, 1, "default").
+		WillReturnRows(sqlmock.NewRows(commentRowColumns).AddRow(7, "default", 1, "alice", "Hi", now, now))
+	mock.ExpectExec("DELETE FROM comments WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(7, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.DeleteComment(context.Background(), 1, 7, "alice"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteAnonymousCommentIsRefused(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM comments WHERE id = (.+) AND todo_id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(7, 1, "default").
+		WillReturnRows(sqlmock.NewRows(commentRowColumns).AddRow(7, "default", 1, models.AnonymousUser, "Hi", now, now))
+	mock.ExpectRollback()
+
+	err = repo.DeleteComment(context.Background(), 1, 7, models.AnonymousUser)
+	if err == nil || err.Error() != "comment belongs to another author" {
+		t.Errorf("Expected author error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
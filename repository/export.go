@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"test-server/models"
+)
+
+// ExportTodos calls fn for each of the tenant's todos matching filter, in
+// list order, one row at a time, so that memory use does not grow with the
+// number of todos. An error from fn stops the export and is returned.
+func (r *TodoRepository) ExportTodos(ctx context.Context, filter models.TodoFilter, fn func(*models.Todo) error) error {
+	where, args := todoFilterWhere(ctx, filter)
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE ` + where + ` ORDER BY position, created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query todos: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan todo: %w", err)
+		}
+		if err := fn(todo); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating todos: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"test-server/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestExportTodosStreamsRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE tenant_id = (.+) AND priority = (.+) ORDER BY position, created_at DESC").
+		WithArgs("default", models.PriorityHigh).
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).
+			AddRow(todoRow(1, "default", "Todo 1", "", false, now)...).
+			AddRow(todoRow(2, "default", "Todo 2", "", false, now)...).
+			AddRow(todoRow(3, "default", "Todo 3", "", false, now)...))
+
+	var seen []int
+	err = repo.ExportTodos(context.Background(), models.TodoFilter{Priority: models.PriorityHigh}, func(todo *models.Todo) error {
+		seen = append(seen, todo.ID)
+		if len(seen) == 2 {
+			return fmt.Errorf("client went away")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "client went away" {
+		t.Errorf("Expected the callback's error, got %v", err)
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected the export to stop after the error, saw %v", seen)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"test-server/models"
+	"test-server/ranking"
+	"test-server/tenant"
+)
+
+// dedupeColumns maps the fields an import can be deduplicated on to their
+// columns.
+var dedupeColumns = map[string]string{"id": "id", "title": "title", "description": "description"}
+
+// dedupeChunk bounds the number of values looked up per query.
+const dedupeChunk = 500
+
+// ExistingValues reports which of values the tenant's todos already have in
+// field, keyed by the lower-cased value. Matching follows the column's
+// collation, which ignores case.
+func (r *TodoRepository) ExistingValues(ctx context.Context, field string, values []string) (map[string]bool, error) {
+	column, ok := dedupeColumns[field]
+	if !ok {
+		return nil, fmt.Errorf("invalid dedupe field")
+	}
+
+	existing := map[string]bool{}
+	for start := 0; start < len(values); start += dedupeChunk {
+		chunk := values[start:min(start+dedupeChunk, len(values))]
+		args := []interface{}{tenant.IDFromContext(ctx)}
+		for _, value := range chunk {
+			args = append(args, value)
+		}
+		query := `SELECT ` + column + ` FROM todos WHERE tenant_id = ? AND ` + column +
+			` IN (?` + strings.Repeat(", ?", len(chunk)-1) + `)`
+
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query existing todos: %w", err)
+		}
+		for rows.Next() {
+			var value string
+			if err := rows.Scan(&value); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan existing todo: %w", err)
+			}
+			existing[strings.ToLower(value)] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating existing todos: %w", err)
+		}
+	}
+	return existing, nil
+}
+
+// ImportTodos creates todos in one transaction, ahead of the tenant's
+// existing todos and in the order given. They are inserted batchSize rows
+// per statement, and progress, when set, is called with the number inserted
+// so far after each batch. Either all of the todos are created or none are.
+// The requests must already be validated, as for Create.
+func (r *TodoRepository) ImportTodos(ctx context.Context, todos []*models.CreateTodoRequest, batchSize int, progress func(int)) error {
+	tenantID := tenant.IDFromContext(ctx)
+	if batchSize <= 0 {
+		batchSize = len(todos)
+	}
+
+	tx, err := r.beginChange(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkQuota(ctx, tx, tenantID, r.quotas.Limit(tenantID), len(todos)); err != nil {
+		return err
+	}
+
+	// Imported todos take every key below the current first one. Creates
+	// lock that row too, so nothing else can claim keys in the range before
+	// this transaction ends.
+	first, err := lockFirstPosition(ctx, tx, tenantID)
+	if err != nil {
+		return err
+	}
+	positions, err := positionsBetween("", first, len(todos))
+	if err != nil {
+		return fmt.Errorf("failed to allocate positions: %w", err)
+	}
+
+	lists := map[int]interface{}{}
+	now := time.Now().UTC()
+	reminders := false
+
+	for start := 0; start < len(todos); start += batchSize {
+		end := min(start+batchSize, len(todos))
+		batch := todos[start:end]
+
+		var values []string
+		var args []interface{}
+		for i, todo := range batch {
+			var listID interface{}
+			if todo.ListID != nil {
+				id, ok := lists[*todo.ListID]
+				if !ok {
+					if id, err = listArg(ctx, tx, tenantID, todo.ListID); err != nil {
+						return err
+					}
+					lists[*todo.ListID] = id
+				}
+				listID = id
+			}
+
+			status := todo.Status
+			if status == "" {
+				status = models.StatusTodo
+			}
+			priority := todo.Priority
+			if priority == "" {
+				priority = models.PriorityMedium
+			}
+			var completedAt interface{}
+			if status == models.StatusDone {
+				completedAt = now
+			}
+			var rule string
+			if todo.Recurrence != nil {
+				rule = *todo.Recurrence
+			}
+			ruleArg, tzArg := recurrenceArgs(rule, todo.Timezone)
+			reminders = reminders || todo.ParsedRemindAt != nil
+
+			values = append(values, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+			args = append(args, tenantID, todo.Title, todo.Description,
+				timeArg(todo.ParsedDueAt), timeArg(todo.ParsedRemindAt),
+				status, priority, status == models.StatusDone, completedAt, listID, positions[start+i], ruleArg, tzArg)
+		}
+
+		query := `INSERT INTO todos (tenant_id, title, description, due_at, remind_at, status, priority, completed, completed_at,
+				list_id, position, recurrence_rule, recurrence_tz)
+			VALUES ` + strings.Join(values, ", ")
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to import todos: %w", err)
+		}
+
+		// The batch's keys are contiguous and unique, so they find its rows
+		// in order.
+		ids, err := importedIDs(ctx, tx, tenantID, positions[start], positions[end-1])
+		if err != nil {
+			return err
+		}
+		if len(ids) != len(batch) {
+			return fmt.Errorf("failed to import todos: expected %d rows, found %d", len(batch), len(ids))
+		}
+		for i, todo := range batch {
+			if len(todo.Tags) > 0 {
+				if err := setTodoTags(ctx, tx, tenantID, ids[i], todo.Tags); err != nil {
+					return err
+				}
+			}
+		}
+
+		created, err := queryTodos(ctx, tx, `SELECT `+todoColumns+` FROM todos WHERE tenant_id = ? AND position BETWEEN ? AND ? ORDER BY position`,
+			tenantID, positions[start], positions[end-1])
+		if err != nil {
+			return err
+		}
+		for i := range created {
+			if err := writeAudit(ctx, tx, tenantID, models.AuditCreate, created[i].ID, nil, &created[i]); err != nil {
+				return err
+			}
+		}
+
+		if progress != nil {
+			progress(end)
+		}
+	}
+
+	if slices.ContainsFunc(positions, func(p string) bool { return len(p) > ranking.MaxLength }) {
+		if err := rebalance(ctx, tx, tenantID); err != nil {
+			return err
+		}
+	}
+
+	if err := r.commitChange(ctx, tx); err != nil {
+		return fmt.Errorf("failed to commit import: %w", err)
+	}
+
+	if reminders {
+		r.reminderChanged()
+	}
+	return nil
+}
+
+func importedIDs(ctx context.Context, q querier, tenantID, from, to string) ([]int, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id FROM todos WHERE tenant_id = ? AND position BETWEEN ? AND ? ORDER BY position`,
+		tenantID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query imported todos: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan imported todo: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating imported todos: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"test-server/models"
+	"test-server/tenant"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestImportTodosInBatches(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+	keys, _ := positionsBetween("", "V", 3)
+	todos := []*models.CreateTodoRequest{
+		{Title: "First", Tags: []string{"home"}},
+		{Title: "Second", Status: models.StatusDone},
+		{Title: "Third", Priority: models.PriorityHigh},
+	}
+
+	mock.ExpectBegin()
+	expectFirstPosition(mock, "default", "V")
+
+	mock.ExpectExec("INSERT INTO todos (.+) VALUES (.+), (.+)").
+		WithArgs("default", "First", "", nil, nil, "todo", "medium", false, nil, nil, keys[0], nil, nil,
+			"default", "Second", "", nil, nil, "done", "medium", true, sqlmock.AnyArg(), nil, keys[1], nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectQuery("SELECT id FROM todos WHERE tenant_id = (.+) AND position BETWEEN (.+) ORDER BY position").
+		WithArgs("default", keys[0], keys[1]).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectExec("DELETE FROM todo_tags WHERE todo_id = ?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO tags (.+) ON DUPLICATE KEY UPDATE").
+		WithArgs("default", "home").
+		WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectExec("INSERT INTO todo_tags").
+		WithArgs(1, int64(10)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) AS tags FROM todos WHERE tenant_id = (.+) AND position BETWEEN (.+) ORDER BY position").
+		WithArgs("default", keys[0], keys[1]).
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).
+			AddRow(todoRow(1, "default", "First", "", false, now)...).
+			AddRow(todoRow(2, "default", "Second", "", true, now)...))
+	expectAudit(mock, "default", 1, models.AuditCreate)
+	expectAudit(mock, "default", 2, models.AuditCreate)
+
+	mock.ExpectExec("INSERT INTO todos").
+		WithArgs("default", "Third", "", nil, nil, "todo", "high", false, nil, nil, keys[2], nil, nil).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectQuery("SELECT id FROM todos").
+		WithArgs("default", keys[2], keys[2]).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("SELECT (.+) AS tags FROM todos").
+		WithArgs("default", keys[2], keys[2]).
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(todoRow(3, "default", "Third", "", false, now)...))
+	expectAudit(mock, "default", 3, models.AuditCreate)
+	mock.ExpectCommit()
+
+	var progress []int
+	err = repo.ImportTodos(context.Background(), todos, 2, func(n int) { progress = append(progress, n) })
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(progress) != 2 || progress[0] != 2 || progress[1] != 3 {
+		t.Errorf("Expected progress after each batch, got %v", progress)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestImportTodosQuotaExceeded(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db).WithQuotas(tenant.Quotas{PerTenant: map[string]int{"acme": 3}})
+	ctx := tenant.WithID(context.Background(), "acme")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM todos WHERE tenant_id = (.+) FOR UPDATE").
+		WithArgs("acme").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	todos := []*models.CreateTodoRequest{{Title: "One"}, {Title: "Two"}}
+	err = repo.ImportTodos(ctx, todos, 100, nil)
+	if err == nil || err.Error() != "todo quota exceeded" {
+		t.Errorf("Expected quota error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestExistingValues(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+
+	mock.ExpectQuery("SELECT title FROM todos WHERE tenant_id = (.+) AND title IN (.+)").
+		WithArgs("default", "buy milk", "call mum").
+		WillReturnRows(sqlmock.NewRows([]string{"title"}).AddRow("Buy milk"))
+
+	existing, err := repo.ExistingValues(context.Background(), "title", []string{"buy milk", "call mum"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !existing["buy milk"] || existing["call mum"] {
+		t.Errorf("Unexpected existing values %v", existing)
+	}
+
+	if _, err := repo.ExistingValues(context.Background(), "status", []string{"todo"}); err == nil || err.Error() != "invalid dedupe field" {
+		t.Errorf("Expected invalid dedupe field, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
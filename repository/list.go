@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"test-server/models"
+	"test-server/tenant"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// listCounts are the open and completed todo counts selected after a list's
+// own columns. Archived todos count as neither.
+const listCounts = `COALESCE(SUM(todos.status NOT IN ('done', 'archived')), 0), COALESCE(SUM(todos.status = 'done'), 0)`
+
+const listQuery = `SELECT lists.id, lists.tenant_id, lists.name, lists.created_at, lists.updated_at, ` + listCounts + `
+	FROM lists LEFT JOIN todos ON todos.list_id = lists.id AND todos.tenant_id = lists.tenant_id
+	WHERE lists.tenant_id = ?`
+
+func scanList(row rowScanner) (*models.List, error) {
+	var list models.List
+	err := row.Scan(&list.ID, &list.TenantID, &list.Name, &list.CreatedAt, &list.UpdatedAt,
+		&list.OpenCount, &list.CompletedCount)
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (r *TodoRepository) inbox(ctx context.Context, tenantID string) (*models.List, error) {
+	list := models.List{ID: models.InboxListID, TenantID: tenantID, Name: "Inbox", Inbox: true}
+	query := `SELECT ` + listCounts + ` FROM todos WHERE tenant_id = ? AND list_id IS NULL`
+	if err := r.db.QueryRowContext(ctx, query, tenantID).Scan(&list.OpenCount, &list.CompletedCount); err != nil {
+		return nil, fmt.Errorf("failed to count inbox: %w", err)
+	}
+	return &list, nil
+}
+
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+func (r *TodoRepository) CreateList(ctx context.Context, req *models.CreateListRequest) (*models.List, error) {
+	result, err := r.db.ExecContext(ctx, `INSERT INTO lists (tenant_id, name) VALUES (?, ?)`,
+		tenant.IDFromContext(ctx), req.Name)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, fmt.Errorf("list name already exists")
+		}
+		return nil, fmt.Errorf("failed to create list: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return r.GetList(ctx, int(id))
+}
+
+// GetLists returns the inbox followed by the tenant's lists by name.
+func (r *TodoRepository) GetLists(ctx context.Context) ([]models.List, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	inbox, err := r.inbox(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, listQuery+` GROUP BY lists.id ORDER BY lists.name`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lists: %w", err)
+	}
+	defer rows.Close()
+
+	lists := []models.List{*inbox}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan list: %w", err)
+		}
+		lists = append(lists, *list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lists: %w", err)
+	}
+
+	return lists, nil
+}
+
+func (r *TodoRepository) GetList(ctx context.Context, id int) (*models.List, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	if id == models.InboxListID {
+		return r.inbox(ctx, tenantID)
+	}
+
+	list, err := scanList(r.db.QueryRowContext(ctx, listQuery+` AND lists.id = ? GROUP BY lists.id`, tenantID, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("list not found")
+		}
+		return nil, fmt.Errorf("failed to get list: %w", err)
+	}
+	return list, nil
+}
+
+func (r *TodoRepository) UpdateList(ctx context.Context, id int, req *models.UpdateListRequest) (*models.List, error) {
+	if req.Name == nil {
+		return r.GetList(ctx, id)
+	}
+
+	_, err := r.db.ExecContext(ctx, `UPDATE lists SET name = ? WHERE id = ? AND tenant_id = ?`,
+		*req.Name, id, tenant.IDFromContext(ctx))
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, fmt.Errorf("list name already exists")
+		}
+		return nil, fmt.Errorf("failed to update list: %w", err)
+	}
+
+	// Renaming to the current name affects no rows, so a missing list is
+	// left for GetList to report.
+	return r.GetList(ctx, id)
+}
+
+// DeleteList removes a list and, depending on mode, moves its todos to the
+// inbox or deletes them with it.
+func (r *TodoRepository) DeleteList(ctx context.Context, id int, mode string) error {
+	tenantID := tenant.IDFromContext(ctx)
+
+	tx, err := r.beginChange(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := listArg(ctx, tx, tenantID, &id); err != nil {
+		return err
+	}
+
+	// The affected todos are read first so that each change is audited.
+	switch mode {
+	case models.ListDeleteCascade:
+		removed, err := queryTodos(ctx, tx, subtreeQuery("list_id = ?")+` FOR UPDATE`, id, tenantID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM todos WHERE list_id = ? AND tenant_id = ?`, id, tenantID); err != nil {
+			return fmt.Errorf("failed to release list todos: %w", err)
+		}
+		for i := range removed {
+			if err := writeAudit(ctx, tx, tenantID, models.AuditDelete, removed[i].ID, &removed[i], nil); err != nil {
+				return err
+			}
+		}
+	default:
+		moved, err := queryTodos(ctx, tx, `SELECT `+todoColumns+` FROM todos WHERE list_id = ? AND tenant_id = ? FOR UPDATE`, id, tenantID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE todos SET list_id = NULL WHERE list_id = ? AND tenant_id = ?`, id, tenantID); err != nil {
+			return fmt.Errorf("failed to release list todos: %w", err)
+		}
+		for i := range moved {
+			after := moved[i]
+			after.ListID = nil
+			if err := writeAudit(ctx, tx, tenantID, models.AuditUpdate, after.ID, &moved[i], &after); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM lists WHERE id = ? AND tenant_id = ?`, id, tenantID); err != nil {
+		return fmt.Errorf("failed to delete list: %w", err)
+	}
+
+	if err := r.commitChange(ctx, tx); err != nil {
+		return fmt.Errorf("failed to commit list delete: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"test-server/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var listRowColumns = []string{"id", "tenant_id", "name", "created_at", "updated_at", "open_count", "completed_count"}
+
+func TestGetListsIncludesInbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE tenant_id = (.+) AND list_id IS NULL").
+		WithArgs("default").
+		WillReturnRows(sqlmock.NewRows([]string{"open_count", "completed_count"}).AddRow(2, 1))
+	mock.ExpectQuery("SELECT (.+) FROM lists LEFT JOIN todos (.+) WHERE lists.tenant_id = (.+) GROUP BY lists.id ORDER BY lists.name").
+		WithArgs("default").
+		WillReturnRows(sqlmock.NewRows(listRowColumns).AddRow(4, "default", "Work", now, now, 3, 0))
+
+	lists, err := repo.GetLists(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(lists) != 2 {
+		t.Fatalf("Expected 2 lists, got %d", len(lists))
+	}
+	if !lists[0].Inbox || lists[0].OpenCount != 2 || lists[0].CompletedCount != 1 {
+		t.Errorf("Unexpected inbox %+v", lists[0])
+	}
+	if lists[1].Name != "Work" || lists[1].OpenCount != 3 {
+		t.Errorf("Unexpected list %+v", lists[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteList(t *testing.T) {
+	tests := []struct {
+		mode   string
+		todos  string
+		query  string
+		action string
+	}{
+		{models.ListDeleteMoveToInbox, "SELECT (.+) FROM todos WHERE list_id = (.+) AND tenant_id = (.+) FOR UPDATE",
+			"UPDATE todos SET list_id = NULL WHERE list_id = (.+) AND tenant_id = ?", models.AuditUpdate},
+		{models.ListDeleteCascade, "WITH RECURSIVE subtree (.+) WHERE list_id = (.+) FOR UPDATE",
+			"DELETE FROM todos WHERE list_id = (.+) AND tenant_id = ?", models.AuditDelete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock: %v", err)
+			}
+			defer db.Close()
+
+			repo := NewTodoRepository(db)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT id FROM lists WHERE id = (.+) AND tenant_id = ?").
+				WithArgs(4, "default").
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+			row := todoRow(7, "default", "Test Todo", "", false, time.Now())
+			setColumn(row, "list_id", 4)
+			mock.ExpectQuery(tt.todos).
+				WithArgs(4, "default").
+				WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(row...))
+			mock.ExpectExec(tt.query).
+				WithArgs(4, "default").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectAudit(mock, "default", 7, tt.action)
+			mock.ExpectExec("DELETE FROM lists WHERE id = (.+) AND tenant_id = ?").
+				WithArgs(4, "default").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			if err := repo.DeleteList(context.Background(), 4, tt.mode); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateTodoInOtherTenantsList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	listID := 4
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM lists WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(4, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	_, err = repo.Create(context.Background(), &models.CreateTodoRequest{Title: "Test Todo", ListID: &listID})
+	if err == nil || err.Error() != "list not found" {
+		t.Errorf("Expected list not found, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"test-server/models"
+	"test-server/requestid"
+	"test-server/tenant"
+)
+
+// WithOutboxHook registers fn to run after a transaction that queued events
+// commits, so an outbox relay can publish them without waiting for its next
+// poll.
+func (r *TodoRepository) WithOutboxHook(fn func()) *TodoRepository {
+	r.outboxHook = fn
+	return r
+}
+
+// changeTx is a transaction that changes todos. writeAudit queues an event
+// in the outbox for every change it records, and commitChange tells the
+// relay about them.
+type changeTx struct {
+	*sql.Tx
+	queued bool
+}
+
+func (r *TodoRepository) beginChange(ctx context.Context) (*changeTx, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &changeTx{Tx: tx}, nil
+}
+
+func (r *TodoRepository) commitChange(ctx context.Context, tx *changeTx) error {
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if tx.queued && r.outboxHook != nil {
+		r.outboxHook()
+	}
+	return nil
+}
+
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate event id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// writeOutbox queues the event for a change as part of the caller's
+// transaction, so the event exists exactly when the change does.
+func writeOutbox(ctx context.Context, q querier, tenantID, action string, todoID int,
+	before, after *models.Todo, changes map[string]models.AuditChange) error {
+	id, err := newEventID()
+	if err != nil {
+		return err
+	}
+	todo := after
+	if todo == nil {
+		todo = before
+	}
+	now := time.Now().UTC()
+	event := models.TodoEvent{
+		ID:         id,
+		Type:       models.EventType(action),
+		TenantID:   tenantID,
+		TodoID:     todoID,
+		Actor:      actor(ctx),
+		Todo:       todo,
+		Changes:    changes,
+		RequestID:  requestid.FromContext(ctx),
+		OccurredAt: now,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	_, err = q.ExecContext(ctx, `INSERT INTO outbox (tenant_id, event_id, event_type, todo_id, payload, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, tenantID, id, event.Type, todoID, payload, now)
+	if err != nil {
+		return fmt.Errorf("failed to queue event: %w", err)
+	}
+
+	if tx, ok := q.(*changeTx); ok {
+		tx.queued = true
+	}
+	return nil
+}
+
+// The outbox queries below run on behalf of the relay and therefore span
+// all tenants.
+
+// DueEvents returns unpublished events whose next attempt is due, oldest
+// first.
+func (r *TodoRepository) DueEvents(ctx context.Context, now time.Time, limit int) ([]models.OutboxEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, payload, attempts FROM outbox
+		WHERE published_at IS NULL AND next_attempt_at <= ?
+		ORDER BY id
+		LIMIT ?`, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var event models.OutboxEvent
+		var payload []byte
+		if err := rows.Scan(&event.Sequence, &payload, &event.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		if err := json.Unmarshal(payload, &event.Event); err != nil {
+			return nil, fmt.Errorf("failed to decode outbox event: %w", err)
+		}
+		event.Event.Sequence = event.Sequence
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox: %w", err)
+	}
+
+	return events, nil
+}
+
+// ClaimEvent pushes a due event's next attempt out to until and reports
+// whether this caller won it, so that relays on several instances do not
+// publish the same event at once.
+func (r *TodoRepository) ClaimEvent(ctx context.Context, sequence int64, now, until time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE outbox SET next_attempt_at = ?
+		WHERE id = ? AND published_at IS NULL AND next_attempt_at <= ?`, until, sequence, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim event: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *TodoRepository) MarkPublished(ctx context.Context, sequence int64, now time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE outbox SET published_at = ?, attempts = attempts + 1, last_error = NULL
+		WHERE id = ?`, now, sequence)
+	if err != nil {
+		return fmt.Errorf("failed to mark event published: %w", err)
+	}
+	return nil
+}
+
+func (r *TodoRepository) MarkUnpublished(ctx context.Context, sequence int64, message string, next time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+		WHERE id = ?`, message, next, sequence)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule event: %w", err)
+	}
+	return nil
+}
+
+func (r *TodoRepository) NextEventAt(ctx context.Context) (*time.Time, error) {
+	var next sql.NullTime
+	err := r.db.QueryRowContext(ctx, `SELECT MIN(next_attempt_at) FROM outbox WHERE published_at IS NULL`).Scan(&next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next event: %w", err)
+	}
+	return nullTimePtr(next), nil
+}
+
+// PruneEvents deletes events published before cutoff.
+func (r *TodoRepository) PruneEvents(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM outbox WHERE published_at < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune outbox: %w", err)
+	}
+
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return pruned, nil
+}
+
+// EventsSince returns up to limit of the tenant's events written after
+// sequence, published or not, oldest first. Published events are only kept
+// for the relay's retention period.
+func (r *TodoRepository) EventsSince(ctx context.Context, sequence int64, limit int) ([]models.TodoEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, payload FROM outbox WHERE tenant_id = ? AND id > ? ORDER BY id LIMIT ?`,
+		tenant.IDFromContext(ctx), sequence, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.TodoEvent{}
+	for rows.Next() {
+		var event models.TodoEvent
+		var payload []byte
+		if err := rows.Scan(&event.Sequence, &payload); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating events: %w", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"test-server/auth"
+	"test-server/models"
+	"test-server/requestid"
+	"test-server/tenant"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// eventArg captures the event payload written to the outbox.
+type eventArg struct {
+	event models.TodoEvent
+}
+
+func (a *eventArg) Match(v driver.Value) bool {
+	data, ok := v.([]byte)
+	return ok && json.Unmarshal(data, &a.event) == nil
+}
+
+func TestWriteOutboxQueuesEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	ctx := auth.WithClaims(context.Background(), auth.Claims{"sub": "alice"})
+	ctx = requestid.WithID(ctx, "req-1")
+	before := &models.Todo{ID: 1, Title: "Draft"}
+	after := &models.Todo{ID: 1, Title: "Final"}
+	changes, _ := todoChanges(before, after)
+
+	payload := &eventArg{}
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs("acme", sqlmock.AnyArg(), models.EventTodoUpdated, 1, payload, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := writeOutbox(ctx, db, "acme", models.AuditUpdate, 1, before, after, changes); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	event := payload.event
+	if len(event.ID) != 32 || event.Actor != "alice" || event.RequestID != "req-1" || event.Todo.Title != "Final" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if string(event.Changes["title"].After) != `"Final"` {
+		t.Errorf("Expected the title change, got %+v", event.Changes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func expectDeleteSubtree(mock sqlmock.Sqlmock, now time.Time) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("WITH RECURSIVE subtree (.+) WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).
+			AddRow(todoRow(1, "default", "Test Todo", "", false, now)...))
+	mock.ExpectExec("DELETE FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, "default", 1, models.AuditDelete)
+}
+
+func TestOutboxHookRunsAfterCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	woken := 0
+	repo := NewTodoRepository(db).WithOutboxHook(func() { woken++ })
+
+	expectDeleteSubtree(mock, time.Now())
+	mock.ExpectCommit().WillReturnError(fmt.Errorf("connection lost"))
+	if err := repo.Delete(context.Background(), 1); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if woken != 0 {
+		t.Errorf("Expected no wake-up for a failed commit, got %d", woken)
+	}
+
+	expectDeleteSubtree(mock, time.Now())
+	mock.ExpectCommit()
+	if err := repo.Delete(context.Background(), 1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if woken != 1 {
+		t.Errorf("Expected one wake-up, got %d", woken)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDueEventsDecodesPayload(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT id, payload, attempts FROM outbox WHERE published_at IS NULL AND next_attempt_at <= (.+) ORDER BY id").
+		WithArgs(now, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payload", "attempts"}).
+			AddRow(5, `{"id":"e5","type":"todo.created","tenant_id":"acme","todo_id":3}`, 2))
+
+	events, err := repo.DueEvents(context.Background(), now, 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(events) != 1 || events[0].Sequence != 5 || events[0].Attempts != 2 || events[0].Event.ID != "e5" {
+		t.Errorf("Unexpected events %+v", events)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestEventsSinceIsTenantScoped(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	ctx := tenant.WithID(context.Background(), "acme")
+
+	mock.ExpectQuery("SELECT id, payload FROM outbox WHERE tenant_id = (.+) AND id > (.+) ORDER BY id LIMIT ?").
+		WithArgs("acme", int64(4), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payload"}).
+			AddRow(5, `{"id":"e5","type":"todo.created","tenant_id":"acme","todo_id":3}`))
+
+	events, err := repo.EventsSince(ctx, 4, 100)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(events) != 1 || events[0].Sequence != 5 || events[0].ID != "e5" {
+		t.Errorf("Unexpected events %+v", events)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"test-server/models"
+	"test-server/ranking"
+	"test-server/tenant"
+)
+
+// firstPosition returns a position ahead of every todo the tenant has, so
+// new todos start at the top. Locking the current first row keeps concurrent
+// creates from picking the same key.
+func firstPosition(ctx context.Context, q querier, tenantID string) (string, error) {
+	first, err := lockFirstPosition(ctx, q, tenantID)
+	if err != nil {
+		return "", err
+	}
+	return ranking.Between("", first)
+}
+
+// lockFirstPosition returns the tenant's smallest position, or "" if it has
+// no todos, locking it for the rest of the transaction.
+func lockFirstPosition(ctx context.Context, q querier, tenantID string) (string, error) {
+	var first string
+	err := q.QueryRowContext(ctx, `SELECT position FROM todos WHERE tenant_id = ? ORDER BY position LIMIT 1 FOR UPDATE`,
+		tenantID).Scan(&first)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get first position: %w", err)
+	}
+	return first, nil
+}
+
+// positionsBetween returns n ascending keys between a and b. Bisecting the
+// range keeps them short, where prepending one at a time would add a digit
+// every few dozen keys.
+func positionsBetween(a, b string, n int) ([]string, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	mid, err := ranking.Between(a, b)
+	if err != nil {
+		return nil, err
+	}
+	lower, err := positionsBetween(a, mid, n/2)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := positionsBetween(mid, b, n-n/2-1)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(lower, mid), upper...), nil
+}
+
+// rebalance gives all of a tenant's todos short, evenly spaced positions in
+// their current order.
+func rebalance(ctx context.Context, q querier, tenantID string) error {
+	rows, err := q.QueryContext(ctx, `SELECT id FROM todos WHERE tenant_id = ? ORDER BY position, created_at DESC FOR UPDATE`, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to query positions: %w", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan position: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating positions: %w", err)
+	}
+
+	for i, key := range ranking.Spread(len(ids)) {
+		if _, err := q.ExecContext(ctx, `UPDATE todos SET position = ? WHERE id = ?`, key, ids[i]); err != nil {
+			return fmt.Errorf("failed to rebalance positions: %w", err)
+		}
+	}
+	return nil
+}
+
+// neighbour returns the position next to position on one side, skipping the
+// todo being moved, or "" at the end of the list.
+func neighbour(ctx context.Context, q querier, tenantID string, id int, position string, above bool) (string, error) {
+	query := `SELECT position FROM todos WHERE tenant_id = ? AND position > ? AND id <> ? ORDER BY position LIMIT 1`
+	if above {
+		query = `SELECT position FROM todos WHERE tenant_id = ? AND position < ? AND id <> ? ORDER BY position DESC LIMIT 1`
+	}
+	var next string
+	err := q.QueryRowContext(ctx, query, tenantID, position, id).Scan(&next)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get neighbouring position: %w", err)
+	}
+	return next, nil
+}
+
+// movePosition works out the key for placing id according to req.
+func movePosition(ctx context.Context, q querier, tenantID string, id int, req *models.MoveTodoRequest) (string, error) {
+	var lower, upper string
+	if req.After != nil {
+		anchor, err := getTodo(ctx, q, *req.After, tenantID, false)
+		if err != nil {
+			if err.Error() == "todo not found" {
+				return "", fmt.Errorf("anchor todo not found")
+			}
+			return "", err
+		}
+		lower = anchor.Position
+	}
+	if req.Before != nil {
+		anchor, err := getTodo(ctx, q, *req.Before, tenantID, false)
+		if err != nil {
+			if err.Error() == "todo not found" {
+				return "", fmt.Errorf("anchor todo not found")
+			}
+			return "", err
+		}
+		upper = anchor.Position
+	}
+
+	var err error
+	switch {
+	case req.Before == nil:
+		upper, err = neighbour(ctx, q, tenantID, id, lower, false)
+	case req.After == nil:
+		lower, err = neighbour(ctx, q, tenantID, id, upper, true)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if req.After != nil && req.Before != nil && lower > upper {
+		return "", fmt.Errorf("after todo is not above before todo")
+	}
+	return ranking.Between(lower, upper)
+}
+
+// Move repositions a todo relative to its new neighbours, rewriting only its
+// own position unless keys have grown long enough to need a rebalance.
+func (r *TodoRepository) Move(ctx context.Context, id int, req *models.MoveTodoRequest) (*models.Todo, error) {
+	tenantID := tenant.IDFromContext(ctx)
+
+	tx, err := r.beginChange(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getTodo(ctx, tx, id, tenantID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	position, err := movePosition(ctx, tx, tenantID, id, req)
+	if errors.Is(err, ranking.ErrNoRoom) || errors.Is(err, ranking.ErrInvalidKey) {
+		// Duplicate or malformed positions leave no room between the
+		// neighbours; spreading the keys out makes room.
+		if err := rebalance(ctx, tx, tenantID); err != nil {
+			return nil, err
+		}
+		position, err = movePosition(ctx, tx, tenantID, id, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE todos SET position = ? WHERE id = ? AND tenant_id = ?`, position, id, tenantID); err != nil {
+		return nil, fmt.Errorf("failed to move todo: %w", err)
+	}
+
+	if len(position) > ranking.MaxLength {
+		if err := rebalance(ctx, tx, tenantID); err != nil {
+			return nil, err
+		}
+	}
+
+	moved, err := auditUpdate(ctx, tx, tenantID, current)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.commitChange(ctx, tx); err != nil {
+		return nil, fmt.Errorf("failed to commit todo: %w", err)
+	}
+
+	return moved, nil
+}
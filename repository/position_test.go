@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"test-server/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// positionedRow returns a todoRow at position.
+func positionedRow(id int, position string, now time.Time) []driver.Value {
+	row := todoRow(id, "default", "Test Todo", "", false, now)
+	setColumn(row, "position", position)
+	return row
+}
+
+func TestMoveTodoAfter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+	after := 2
+
+	expectLockTodo(mock, 1, "default", now)
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(2, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(positionedRow(2, "A", now)...))
+	mock.ExpectQuery("SELECT position FROM todos WHERE tenant_id = (.+) AND position > (.+) AND id <> (.+) ORDER BY position LIMIT 1").
+		WithArgs("default", "A", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow("C"))
+	mock.ExpectExec("UPDATE todos SET position = (.+) WHERE id = (.+) AND tenant_id = ?").
+		WithArgs("B", 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(positionedRow(1, "B", now)...))
+	expectAudit(mock, "default", 1, models.AuditUpdate)
+	mock.ExpectCommit()
+
+	todo, err := repo.Move(context.Background(), 1, &models.MoveTodoRequest{After: &after})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if todo.Position != "B" {
+		t.Errorf("Expected position B, got %q", todo.Position)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestMoveTodoRebalancesDuplicatePositions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+	after, before := 2, 3
+
+	expectAnchors := func(lower, upper string) {
+		mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+			WithArgs(2, "default").
+			WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(positionedRow(2, lower, now)...))
+		mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+			WithArgs(3, "default").
+			WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(positionedRow(3, upper, now)...))
+	}
+
+	expectLockTodo(mock, 1, "default", now)
+	expectAnchors("K", "K")
+	mock.ExpectQuery("SELECT id FROM todos WHERE tenant_id = (.+) ORDER BY position, created_at DESC FOR UPDATE").
+		WithArgs("default").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(3).AddRow(1))
+	for _, id := range []int{2, 3, 1} {
+		mock.ExpectExec("UPDATE todos SET position = (.+) WHERE id = ?").
+			WithArgs(sqlmock.AnyArg(), id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	expectAnchors("F", "V")
+	mock.ExpectExec("UPDATE todos SET position = (.+) WHERE id = (.+) AND tenant_id = ?").
+		WithArgs("N", 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(positionedRow(1, "N", now)...))
+	expectAudit(mock, "default", 1, models.AuditUpdate)
+	mock.ExpectCommit()
+
+	if _, err := repo.Move(context.Background(), 1, &models.MoveTodoRequest{After: &after, Before: &before}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestMoveTodoAnchorsOutOfOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+	after, before := 2, 3
+
+	expectLockTodo(mock, 1, "default", now)
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(2, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(positionedRow(2, "X", now)...))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(3, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(positionedRow(3, "B", now)...))
+	mock.ExpectRollback()
+
+	_, err = repo.Move(context.Background(), 1, &models.MoveTodoRequest{After: &after, Before: &before})
+	if err == nil || err.Error() != "after todo is not above before todo" {
+		t.Errorf("Expected ordering error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestPositionsBetweenStaysShort(t *testing.T) {
+	keys, err := positionsBetween("", "V", 10000)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(keys) != 10000 {
+		t.Fatalf("Expected 10000 keys, got %d", len(keys))
+	}
+	for i, key := range keys {
+		if key >= "V" || (i > 0 && key <= keys[i-1]) {
+			t.Fatalf("Key %d %q is out of order", i, key)
+		}
+		if len(key) > 8 {
+			t.Fatalf("Expected short keys, got %q", key)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"test-server/models"
+	"test-server/recurrence"
+)
+
+// recurrenceArgs returns the recurrence_rule and recurrence_tz values for a
+// normalised rule, where an empty rule means the todo does not repeat.
+func recurrenceArgs(rule, timezone string) (interface{}, interface{}) {
+	if rule == "" {
+		return nil, nil
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	return rule, timezone
+}
+
+// spawnOccurrence creates the occurrence following from, which was just
+// completed, and reports whether it has a reminder. Nothing is created once
+// the series has ended. The occurrence counts against the tenant's quota,
+// so a tenant at its limit cannot complete the todo.
+func spawnOccurrence(ctx context.Context, q querier, tenantID string, from *models.Todo,
+	series models.Recurrence, now time.Time, quota int) (bool, error) {
+	rule, err := recurrence.Parse(series.Rule)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse recurrence: %w", err)
+	}
+	loc, err := models.LoadTimezone(series.Timezone)
+	if err != nil {
+		return false, err
+	}
+
+	if rule.Count > 0 && series.Occurrence >= rule.Count {
+		return false, nil
+	}
+
+	// Completing late moves on to the next occurrence still ahead.
+	base, after := now, now
+	if from.DueAt != nil {
+		base = *from.DueAt
+		if base.After(now) {
+			after = base
+		}
+	}
+	next, ok := rule.Next(base, after, loc)
+	if !ok {
+		return false, nil
+	}
+
+	var remindAt interface{}
+	if from.RemindAt != nil && from.DueAt != nil {
+		remindAt = next.Add(from.RemindAt.Sub(*from.DueAt))
+	}
+
+	if err := checkQuota(ctx, q, tenantID, quota, 1); err != nil {
+		return false, err
+	}
+
+	position, err := firstPosition(ctx, q, tenantID)
+	if err != nil {
+		return false, err
+	}
+
+	ruleArg, tzArg := recurrenceArgs(series.Rule, series.Timezone)
+	query := `INSERT INTO todos (tenant_id, title, description, due_at, remind_at, status, priority, list_id, parent_id, position,
+			recurrence_rule, recurrence_tz, occurrence)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := q.ExecContext(ctx, query, tenantID, from.Title, from.Description, next, remindAt,
+		models.StatusTodo, from.Priority, intArg(from.ListID), intArg(from.ParentID), position,
+		ruleArg, tzArg, series.Occurrence+1)
+	if err != nil {
+		return false, fmt.Errorf("failed to create next occurrence: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return false, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	if len(from.Tags) > 0 {
+		if err := setTodoTags(ctx, q, tenantID, int(id), from.Tags); err != nil {
+			return false, err
+		}
+	}
+
+	created, err := getTodo(ctx, q, int(id), tenantID, false)
+	if err != nil {
+		return false, err
+	}
+	if err := writeAudit(ctx, q, tenantID, models.AuditCreate, created.ID, nil, created); err != nil {
+		return false, err
+	}
+
+	return remindAt != nil, nil
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"test-server/models"
+	"test-server/tenant"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCompletingRecurringTodoCreatesNextOccurrence(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	status := models.StatusDone
+	now := time.Now()
+	due := now.Add(2 * time.Hour).UTC().Truncate(time.Second)
+	remind := due.Add(-30 * time.Minute)
+
+	current := todoRow(1, "default", "Water plants", "", false, now)
+	setColumn(current, "due_at", due)
+	setColumn(current, "remind_at", remind)
+	setColumn(current, "recurrence_rule", "FREQ=DAILY")
+	setColumn(current, "recurrence_tz", "UTC")
+	setColumn(current, "occurrence", 3)
+
+	completed := todoRow(1, "default", "Water plants", "", true, now)
+	setColumn(completed, "due_at", due)
+	setColumn(completed, "remind_at", remind)
+
+	next := todoRow(2, "default", "Water plants", "", false, now)
+	setColumn(next, "due_at", due.AddDate(0, 0, 1))
+	setColumn(next, "remind_at", remind.AddDate(0, 0, 1))
+	setColumn(next, "recurrence_rule", "FREQ=DAILY")
+	setColumn(next, "recurrence_tz", "UTC")
+	setColumn(next, "occurrence", 4)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(current...))
+	mock.ExpectExec("UPDATE todos SET status = (.+), recurrence_rule = NULL, recurrence_tz = NULL WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(status, sqlmock.AnyArg(), 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(completed...))
+	expectAudit(mock, "default", 1, models.AuditUpdate)
+	expectFirstPosition(mock, "default", "V")
+	mock.ExpectExec("INSERT INTO todos").
+		WithArgs("default", "Water plants", "", due.AddDate(0, 0, 1), remind.AddDate(0, 0, 1), "todo", "medium",
+			nil, nil, "U", "FREQ=DAILY", "UTC", 4).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(2, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(next...))
+	expectAudit(mock, "default", 2, models.AuditCreate)
+	mock.ExpectCommit()
+
+	woken := false
+	repo.WithReminderHook(func() { woken = true })
+
+	if _, err := repo.Update(context.Background(), 1, &models.UpdateTodoRequest{Status: &status}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !woken {
+		t.Error("Expected the next occurrence's reminder to wake the scheduler")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCompletingLastOccurrenceEndsSeries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	status := models.StatusDone
+	now := time.Now()
+
+	current := todoRow(1, "default", "Test Todo", "", false, now)
+	setColumn(current, "recurrence_rule", "FREQ=WEEKLY;COUNT=2")
+	setColumn(current, "recurrence_tz", "UTC")
+	setColumn(current, "occurrence", 2)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(current...))
+	mock.ExpectExec("UPDATE todos SET (.+), recurrence_rule = NULL, recurrence_tz = NULL WHERE id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(todoRow(1, "default", "Test Todo", "", true, now)...))
+	expectAudit(mock, "default", 1, models.AuditUpdate)
+	mock.ExpectCommit()
+
+	if _, err := repo.Update(context.Background(), 1, &models.UpdateTodoRequest{Status: &status}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCompletingRecurringTodoAtQuota(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db).WithQuotas(tenant.Quotas{Default: 1})
+	status := models.StatusDone
+	now := time.Now()
+
+	current := todoRow(1, "default", "Water plants", "", false, now)
+	setColumn(current, "recurrence_rule", "FREQ=DAILY")
+	setColumn(current, "recurrence_tz", "UTC")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(current...))
+	mock.ExpectExec("UPDATE todos SET (.+), recurrence_rule = NULL, recurrence_tz = NULL WHERE id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(todoRow(1, "default", "Water plants", "", true, now)...))
+	expectAudit(mock, "default", 1, models.AuditUpdate)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM todos WHERE tenant_id = (.+) FOR UPDATE").
+		WithArgs("default").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	_, err = repo.Update(context.Background(), 1, &models.UpdateTodoRequest{Status: &status})
+	if err == nil || err.Error() != "todo quota exceeded" {
+		t.Errorf("Expected quota error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"test-server/models"
+)
+
+// The reminder queries run on behalf of the scheduler and therefore span
+// all tenants.
+
+func (r *TodoRepository) DueReminders(ctx context.Context, now time.Time, limit int) ([]models.Todo, error) {
+	query := `SELECT ` + todoColumns + ` FROM todos
+		WHERE remind_at <= ? AND reminder_sent_at IS NULL AND status NOT IN ('done', 'archived')
+		ORDER BY remind_at
+		LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due reminders: %w", err)
+	}
+	defer rows.Close()
+
+	var todos []models.Todo
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan todo: %w", err)
+		}
+		todos = append(todos, *todo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating due reminders: %w", err)
+	}
+
+	return todos, nil
+}
+
+// ClaimReminder marks a reminder as sent and reports whether this caller won
+// it, so that several scheduler instances never fire the same reminder.
+func (r *TodoRepository) ClaimReminder(ctx context.Context, id int, now time.Time) (bool, error) {
+	// updated_at is pinned so that sending a reminder is not a user edit.
+	query := `UPDATE todos SET reminder_sent_at = ?, updated_at = updated_at WHERE id = ? AND reminder_sent_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, now, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim reminder: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *TodoRepository) NextReminderAt(ctx context.Context) (*time.Time, error) {
+	query := `SELECT MIN(remind_at) FROM todos WHERE reminder_sent_at IS NULL AND status NOT IN ('done', 'archived')`
+	var next sql.NullTime
+	if err := r.db.QueryRowContext(ctx, query).Scan(&next); err != nil {
+		return nil, fmt.Errorf("failed to get next reminder: %w", err)
+	}
+	return nullTimePtr(next), nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDueReminders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now().UTC()
+
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE remind_at <= (.+) AND reminder_sent_at IS NULL").
+		WithArgs(now, 10).
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).
+			AddRow(todoRow(1, "acme", "Call back", "", false, now)...).
+			AddRow(todoRow(2, "globex", "Renew domain", "", false, now)...))
+
+	todos, err := repo.DueReminders(context.Background(), now, 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(todos) != 2 {
+		t.Errorf("Expected reminders across tenants, got %d", len(todos))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestClaimReminder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now().UTC()
+
+	mock.ExpectExec("UPDATE todos SET reminder_sent_at = (.+) WHERE id = (.+) AND reminder_sent_at IS NULL").
+		WithArgs(now, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE todos SET reminder_sent_at = (.+) WHERE id = (.+) AND reminder_sent_at IS NULL").
+		WithArgs(now, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if claimed, _ := repo.ClaimReminder(context.Background(), 1, now); !claimed {
+		t.Error("Expected first claim to succeed")
+	}
+	if claimed, _ := repo.ClaimReminder(context.Background(), 1, now); claimed {
+		t.Error("Expected second claim to fail")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"test-server/models"
+	"test-server/tenant"
+)
+
+const revisionColumns = `todo_id, revision, actor, snapshot, created_at`
+
+// writeRevision stores todo as the todo's next revision.
+func writeRevision(ctx context.Context, q querier, tenantID string, todo *models.Todo) error {
+	snapshot, err := json.Marshal(todo)
+	if err != nil {
+		return fmt.Errorf("failed to encode revision: %w", err)
+	}
+
+	// Callers hold the todo's row lock, so numbering cannot race.
+	_, err = q.ExecContext(ctx, `INSERT INTO todo_revisions (tenant_id, todo_id, revision, actor, snapshot)
+		SELECT ?, ?, COALESCE(MAX(revision), 0) + 1, ?, ? FROM todo_revisions WHERE todo_id = ?`,
+		tenantID, todo.ID, actor(ctx), snapshot, todo.ID)
+	if err != nil {
+		return fmt.Errorf("failed to write revision: %w", err)
+	}
+	return nil
+}
+
+func scanRevision(row rowScanner) (*models.TodoRevision, error) {
+	var revision models.TodoRevision
+	var snapshot []byte
+	if err := row.Scan(&revision.TodoID, &revision.Revision, &revision.Actor, &snapshot, &revision.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(snapshot, &revision.Todo); err != nil {
+		return nil, fmt.Errorf("failed to decode revision: %w", err)
+	}
+	return &revision, nil
+}
+
+func getRevision(ctx context.Context, q querier, tenantID string, todoID, n int) (*models.TodoRevision, error) {
+	query := `SELECT ` + revisionColumns + ` FROM todo_revisions WHERE todo_id = ? AND revision = ? AND tenant_id = ?`
+	revision, err := scanRevision(q.QueryRowContext(ctx, query, todoID, n, tenantID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("revision not found")
+		}
+		return nil, fmt.Errorf("failed to get revision: %w", err)
+	}
+	return revision, nil
+}
+
+// GetRevisions returns a todo's revisions, oldest first.
+func (r *TodoRepository) GetRevisions(ctx context.Context, todoID int) ([]models.TodoRevision, error) {
+	query := `SELECT ` + revisionColumns + ` FROM todo_revisions WHERE todo_id = ? AND tenant_id = ? ORDER BY revision`
+	rows, err := r.db.QueryContext(ctx, query, todoID, tenant.IDFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []models.TodoRevision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		revisions = append(revisions, *revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+func (r *TodoRepository) GetRevision(ctx context.Context, todoID, n int) (*models.TodoRevision, error) {
+	return getRevision(ctx, r.db, tenant.IDFromContext(ctx), todoID, n)
+}
+
+func (r *TodoRepository) DiffRevisions(ctx context.Context, todoID, from, to int) (*models.RevisionDiff, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	older, err := getRevision(ctx, r.db, tenantID, todoID, from)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := getRevision(ctx, r.db, tenantID, todoID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := todoChanges(&older.Todo, &newer.Todo)
+	if err != nil {
+		return nil, err
+	}
+	return &models.RevisionDiff{TodoID: todoID, From: from, To: to, Changes: changes}, nil
+}
+
+// Revert restores a todo's content to revision n. The restored state is
+// saved as a new revision, so the revisions in between are kept. The
+// revert is applied as an update of the fields that differ, so it follows
+// the same status workflow and completion rules. The todo's position is
+// left alone, and a list or parent that no longer exists makes the revert
+// fail.
+func (r *TodoRepository) Revert(ctx context.Context, todoID, n int) (*models.Todo, error) {
+	tenantID := tenant.IDFromContext(ctx)
+
+	tx, err := r.beginChange(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getTodo(ctx, tx, todoID, tenantID, true)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := getRevision(ctx, tx, tenantID, todoID, n)
+	if err != nil {
+		return nil, err
+	}
+
+	reverted, reminderSet, err := r.applyUpdate(ctx, tx, tenantID, current, revertRequest(current, &revision.Todo))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.commitChange(ctx, tx); err != nil {
+		return nil, fmt.Errorf("failed to commit revert: %w", err)
+	}
+
+	if reminderSet {
+		r.reminderChanged()
+	}
+
+	return reverted, nil
+}
+
+// revertRequest is the update that takes current back to old. A reminder
+// that was already sent stays sent unless its time changes.
+func revertRequest(current, old *models.Todo) *models.UpdateTodoRequest {
+	req := &models.UpdateTodoRequest{}
+	if old.Title != current.Title {
+		req.Title = &old.Title
+	}
+	if old.Description != current.Description {
+		req.Description = &old.Description
+	}
+	if old.Status != current.Status {
+		req.Status = &old.Status
+	}
+	if old.Priority != current.Priority {
+		req.Priority = &old.Priority
+	}
+	if !sameTime(old.DueAt, current.DueAt) {
+		req.DueAt, req.ParsedDueAt = timeString(old.DueAt), old.DueAt
+	}
+	if !sameTime(old.RemindAt, current.RemindAt) {
+		req.RemindAt, req.ParsedRemindAt = timeString(old.RemindAt), old.RemindAt
+	}
+	if !sameInt(old.ListID, current.ListID) {
+		listID := models.InboxListID
+		if old.ListID != nil {
+			listID = *old.ListID
+		}
+		req.ListID = &listID
+	}
+	if !sameInt(old.ParentID, current.ParentID) {
+		parentID := 0
+		if old.ParentID != nil {
+			parentID = *old.ParentID
+		}
+		req.ParentID = &parentID
+	}
+	if !slices.Equal(old.Tags, current.Tags) {
+		tags := append([]string{}, old.Tags...)
+		req.Tags = &tags
+	}
+
+	var oldRule, currentRule string
+	if old.Recurrence != nil {
+		oldRule, req.Timezone = old.Recurrence.Rule, old.Recurrence.Timezone
+	}
+	if current.Recurrence != nil {
+		currentRule = current.Recurrence.Rule
+	}
+	if oldRule != currentRule || (old.Recurrence != nil && current.Recurrence != nil && req.Timezone != current.Recurrence.Timezone) {
+		req.Recurrence = &oldRule
+	}
+	return req
+}
+
+func timeString(t *time.Time) *string {
+	s := ""
+	if t != nil {
+		s = t.UTC().Format(time.RFC3339)
+	}
+	return &s
+}
+
+func sameInt(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"test-server/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var revisionRowColumns = []string{"todo_id", "revision", "actor", "snapshot", "created_at"}
+
+func revisionRow(t *testing.T, n int, todo models.Todo, now time.Time) []driver.Value {
+	t.Helper()
+	snapshot, err := json.Marshal(todo)
+	if err != nil {
+		t.Fatalf("Failed to encode snapshot: %v", err)
+	}
+	return []driver.Value{todo.ID, n, "alice", snapshot, now}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+	older := models.Todo{ID: 1, Title: "Draft", Priority: models.PriorityLow, Tags: []string{}}
+	newer := older
+	newer.Title = "Final"
+
+	for n, todo := range map[int]models.Todo{1: older, 3: newer} {
+		mock.ExpectQuery("SELECT (.+) FROM todo_revisions WHERE todo_id = (.+) AND revision = (.+) AND tenant_id = ?").
+			WithArgs(1, n, "default").
+			WillReturnRows(sqlmock.NewRows(revisionRowColumns).AddRow(revisionRow(t, n, todo, now)...))
+	}
+	mock.MatchExpectationsInOrder(false)
+
+	diff, err := repo.DiffRevisions(context.Background(), 1, 1, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(diff.Changes) != 1 || string(diff.Changes["title"].After) != `"Final"` {
+		t.Errorf("Unexpected diff %+v", diff.Changes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRevertRestoresSnapshotAsNewRevision(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+	old := models.Todo{ID: 1, Title: "Original", Description: "first", Status: models.StatusTodo,
+		Priority: models.PriorityHigh, Tags: []string{"home"}}
+
+	reverted := todoRow(1, "default", "Original", "first", false, now)
+	setColumn(reverted, "priority", models.PriorityHigh)
+	setColumn(reverted, "tags", "home")
+
+	expectLockTodo(mock, 1, "default", now)
+	mock.ExpectQuery("SELECT (.+) FROM todo_revisions WHERE todo_id = (.+) AND revision = (.+) AND tenant_id = ?").
+		WithArgs(1, 2, "default").
+		WillReturnRows(sqlmock.NewRows(revisionRowColumns).AddRow(revisionRow(t, 2, old, now)...))
+	mock.ExpectExec("DELETE FROM todo_tags WHERE todo_id = ?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO tags").
+		WithArgs("default", "home").
+		WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectExec("INSERT INTO todo_tags").
+		WithArgs(1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE todos SET title = \\?, description = \\?, priority = \\? WHERE id = (.+) AND tenant_id = ?").
+		WithArgs("Original", "first", models.PriorityHigh, 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(reverted...))
+	expectAudit(mock, "default", 1, models.AuditUpdate)
+	mock.ExpectCommit()
+
+	todo, err := repo.Revert(context.Background(), 1, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if todo.Title != "Original" || len(todo.Tags) != 1 {
+		t.Errorf("Unexpected todo %+v", todo)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRevertFollowsStatusWorkflow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+	old := models.Todo{ID: 1, Title: "Test Todo", Status: models.StatusDone, Completed: true,
+		Priority: models.PriorityMedium, Tags: []string{}}
+
+	current := todoRow(1, "default", "Test Todo", "", false, now)
+	setColumn(current, "status", models.StatusArchived)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(current...))
+	mock.ExpectQuery("SELECT (.+) FROM todo_revisions WHERE todo_id = (.+) AND revision = (.+) AND tenant_id = ?").
+		WithArgs(1, 2, "default").
+		WillReturnRows(sqlmock.NewRows(revisionRowColumns).AddRow(revisionRow(t, 2, old, now)...))
+	mock.ExpectRollback()
+
+	_, err = repo.Revert(context.Background(), 1, 2)
+	var transition *models.TransitionError
+	if !errors.As(err, &transition) {
+		t.Errorf("Expected archived to done to be refused, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRevertUnknownRevision(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+
+	expectLockTodo(mock, 1, "default", time.Now())
+	mock.ExpectQuery("SELECT (.+) FROM todo_revisions WHERE todo_id = (.+) AND revision = (.+) AND tenant_id = ?").
+		WithArgs(1, 9, "default").
+		WillReturnRows(sqlmock.NewRows(revisionRowColumns))
+	mock.ExpectRollback()
+
+	_, err = repo.Revert(context.Background(), 1, 9)
+	if err == nil || err.Error() != "revision not found" {
+		t.Errorf("Expected revision not found, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
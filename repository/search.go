@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"test-server/models"
+	"test-server/tenant"
+)
+
+type SearchMode int
+
+const (
+	// SearchFullText uses the MySQL FULLTEXT index in boolean mode.
+	SearchFullText SearchMode = iota
+	// SearchLike uses portable LIKE matching and ranks in Go, for backends
+	// without full-text indexes.
+	SearchLike
+)
+
+const snippetRadius = 60
+
+type searchTerm struct {
+	text     string
+	required bool
+	excluded bool
+	phrase   bool
+	prefix   bool
+}
+
+// parseSearchQuery accepts the user-facing syntax: bare words are optional
+// and raise relevance, +word is required, -word is excluded, "quoted text"
+// is a phrase and word* matches a prefix. Anything else is dropped so that a
+// malformed query can never become a SQL error.
+func parseSearchQuery(q string) []searchTerm {
+	var terms []searchTerm
+	runes := []rune(q)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		var term searchTerm
+		switch runes[i] {
+		case '+':
+			term.required = true
+			i++
+		case '-':
+			term.excluded = true
+			i++
+		}
+
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			term.phrase = true
+			term.text = strings.Join(strings.FieldsFunc(string(runes[i+1:end]), isSeparator), " ")
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			word := string(runes[i:end])
+			term.prefix = strings.HasSuffix(word, "*")
+			term.text = strings.Join(strings.FieldsFunc(word, isSeparator), " ")
+			if strings.Contains(term.text, " ") {
+				// Punctuation inside a word, e.g. "e-mail", splits it.
+				term.phrase = true
+				term.prefix = false
+			}
+			i = end
+		}
+
+		if term.text != "" {
+			terms = append(terms, term)
+		}
+	}
+
+	return terms
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+}
+
+// booleanModeQuery renders terms as a MySQL IN BOOLEAN MODE expression.
+func booleanModeQuery(terms []searchTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		var b strings.Builder
+		switch {
+		case term.required:
+			b.WriteByte('+')
+		case term.excluded:
+			b.WriteByte('-')
+		}
+		if term.phrase {
+			b.WriteString(`"` + term.text + `"`)
+		} else {
+			b.WriteString(term.text)
+			if term.prefix {
+				b.WriteByte('*')
+			}
+		}
+		parts = append(parts, b.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func hasPositiveTerm(terms []searchTerm) bool {
+	for _, term := range terms {
+		if !term.excluded {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *TodoRepository) Search(ctx context.Context, q string, limit int) ([]models.SearchResult, error) {
+	terms := parseSearchQuery(q)
+	if !hasPositiveTerm(terms) {
+		return []models.SearchResult{}, nil
+	}
+
+	var results []models.SearchResult
+	var err error
+	if r.searchMode == SearchLike {
+		results, err = r.searchLike(ctx, terms, limit)
+	} else {
+		results, err = r.searchFullText(ctx, terms, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].Highlights = models.SearchHighlights{
+			Title:       highlight(results[i].Title, terms, false),
+			Description: highlight(results[i].Description, terms, true),
+		}
+	}
+	return results, nil
+}
+
+func (r *TodoRepository) searchFullText(ctx context.Context, terms []searchTerm, limit int) ([]models.SearchResult, error) {
+	against := booleanModeQuery(terms)
+	query := `SELECT ` + todoColumns + `, MATCH(title, description) AGAINST (? IN BOOLEAN MODE) AS score
+		FROM todos
+		WHERE tenant_id = ? AND MATCH(title, description) AGAINST (? IN BOOLEAN MODE)
+		ORDER BY score DESC, updated_at DESC
+		LIMIT ?`
+
+	rows, err := r.db.QueryContext(ctx, query, against, tenant.IDFromContext(ctx), against, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search todos: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var score float64
+		todo, err := scanTodo(rows, &score)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		results = append(results, models.SearchResult{Todo: *todo, Score: score})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search results: %w", err)
+	}
+
+	return results, nil
+}
+
+func (r *TodoRepository) searchLike(ctx context.Context, terms []searchTerm, limit int) ([]models.SearchResult, error) {
+	var required, optional []string
+	args := []interface{}{tenant.IDFromContext(ctx)}
+	var optionalArgs []interface{}
+
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term.text) + "%"
+		cond := `(title LIKE ? OR description LIKE ?)`
+		switch {
+		case term.excluded:
+			required = append(required, "NOT "+cond)
+			args = append(args, pattern, pattern)
+		case term.required:
+			required = append(required, cond)
+			args = append(args, pattern, pattern)
+		default:
+			optional = append(optional, cond)
+			optionalArgs = append(optionalArgs, pattern, pattern)
+		}
+	}
+
+	where := []string{"tenant_id = ?"}
+	where = append(where, required...)
+	// Optional terms only constrain the result when nothing is required,
+	// mirroring boolean mode.
+	if !hasRequiredTerm(terms) && len(optional) > 0 {
+		where = append(where, "("+strings.Join(optional, " OR ")+")")
+		args = append(args, optionalArgs...)
+	}
+
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE ` + strings.Join(where, " AND ")
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search todos: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		results = append(results, models.SearchResult{Todo: *todo, Score: likeScore(todo, terms)})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search results: %w", err)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].UpdatedAt.After(results[j].UpdatedAt)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func hasRequiredTerm(terms []searchTerm) bool {
+	for _, term := range terms {
+		if term.required {
+			return true
+		}
+	}
+	return false
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// likeScore counts term occurrences, weighting the title double.
+func likeScore(todo *models.Todo, terms []searchTerm) float64 {
+	title := strings.ToLower(todo.Title)
+	description := strings.ToLower(todo.Description)
+
+	var score float64
+	for _, term := range terms {
+		if term.excluded {
+			continue
+		}
+		text := strings.ToLower(term.text)
+		score += 2*float64(strings.Count(title, text)) + float64(strings.Count(description, text))
+	}
+	return score
+}
+
+// highlight HTML-escapes text and wraps matches of the positive terms in
+// <mark>. With snippet set, long text is cut to a window around the first
+// match.
+func highlight(text string, terms []searchTerm, snippet bool) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Offsets in lower must line up with text; fall back to
+		// case-sensitive matching for the rare runes that change width.
+		lower = text
+	}
+
+	type span struct{ start, end int }
+	var spans []span
+	for _, term := range terms {
+		if term.excluded {
+			continue
+		}
+		needle := strings.ToLower(term.text)
+		for offset := 0; ; {
+			idx := strings.Index(lower[offset:], needle)
+			if idx < 0 {
+				break
+			}
+			start := offset + idx
+			end := start + len(needle)
+			if term.prefix {
+				for end < len(lower) {
+					r, size := utf8.DecodeRuneInString(lower[end:])
+					if isSeparator(r) {
+						break
+					}
+					end += size
+				}
+			}
+			spans = append(spans, span{start, end})
+			offset = end
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	from, to := 0, len(text)
+	if snippet && len(text) > 2*snippetRadius {
+		center := 0
+		if len(spans) > 0 {
+			center = spans[0].start
+		}
+		from = max(0, center-snippetRadius)
+		to = min(len(text), center+snippetRadius)
+		// Avoid cutting through a multi-byte character.
+		for from > 0 && !utf8.RuneStart(text[from]) {
+			from--
+		}
+		for to < len(text) && !utf8.RuneStart(text[to]) {
+			to++
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, s := range spans {
+		if s.start < pos || s.end > to {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:s.start]))
+		b.WriteString("<mark>" + html.EscapeString(text[s.start:s.end]) + "</mark>")
+		pos = s.end
+	}
+	b.WriteString(html.EscapeString(text[pos:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
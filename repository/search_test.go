@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	terms := parseSearchQuery(`+milk -soy "oat bread" choc* e-mail @drop`)
+
+	got := booleanModeQuery(terms)
+	want := `+milk -soy "oat bread" choc* "e mail" drop`
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestParseSearchQueryUnbalancedQuote(t *testing.T) {
+	got := booleanModeQuery(parseSearchQuery(`"oat bread`))
+	if got != `"oat bread"` {
+		t.Errorf("Expected closed phrase, got %q", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	terms := parseSearchQuery(`milk choc* -soy`)
+
+	got := highlight("Buy <b>Milk</b> and chocolate, not soy", terms, false)
+	want := "Buy &lt;b&gt;<mark>Milk</mark>&lt;/b&gt; and <mark>chocolate</mark>, not soy"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	text := "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor " +
+		"incididunt ut labore et dolore magna aliqua. Remember the milk before the store closes " +
+		"at nine, and also pick up bread, eggs and coffee for the weekend breakfast."
+
+	got := highlight(text, parseSearchQuery("milk"), true)
+
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("Expected snippet with ellipses, got %q", got)
+	}
+	if want := "<mark>milk</mark>"; !strings.Contains(got, want) {
+		t.Errorf("Expected %q in snippet %q", want, got)
+	}
+}
+
+func TestSearchFullText(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT (.+) MATCH\\(title, description\\) AGAINST (.+) FROM todos WHERE tenant_id = (.+) ORDER BY score DESC").
+		WithArgs(`+milk "oat bread"`, "default", `+milk "oat bread"`, 10).
+		WillReturnRows(sqlmock.NewRows(append(todoRowColumns, "score")).
+			AddRow(append(todoRow(1, "default", "Buy milk", "And oat bread", false, now), 2.5)...))
+
+	results, err := repo.Search(context.Background(), `+milk "oat bread"`, 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(results) != 1 || results[0].Score != 2.5 {
+		t.Fatalf("Unexpected results %+v", results)
+	}
+	if results[0].Highlights.Title != "Buy <mark>milk</mark>" {
+		t.Errorf("Unexpected title highlight %q", results[0].Highlights.Title)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSearchLike(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db).WithSearchMode(SearchLike)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE tenant_id = (.+) AND \\(title LIKE (.+) AND NOT \\(title LIKE").
+		WithArgs("default", "%milk%", "%milk%", "%soy%", "%soy%").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).
+			AddRow(todoRow(1, "default", "Groceries", "milk", false, now)...).
+			AddRow(todoRow(2, "default", "Milk run", "milk and more milk", false, now)...))
+
+	results, err := repo.Search(context.Background(), "+milk -soy", 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(results) != 2 || results[0].ID != 2 {
+		t.Errorf("Expected todo 2 ranked first, got %+v", results)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSearchOnlyExcludedTerms(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	results, err := NewTodoRepository(db).Search(context.Background(), "-soy", 10)
+	if err != nil || len(results) != 0 {
+		t.Errorf("Expected empty result without querying, got %v, %v", results, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"test-server/models"
+	"test-server/tenant"
+)
+
+// CompletionRules tie a parent todo's completion to its subtasks.
+type CompletionRules struct {
+	// AutoCompleteParents marks a parent done once its last open subtask is
+	// done, repeating up the tree.
+	AutoCompleteParents bool
+	// BlockOpenChildren refuses to complete a todo with open subtasks.
+	BlockOpenChildren bool
+}
+
+// WithCompletionRules sets how completing a todo interacts with its
+// subtasks and parent.
+func (r *TodoRepository) WithCompletionRules(rules CompletionRules) *TodoRepository {
+	r.completionRules = rules
+	return r
+}
+
+// subtreeQuery selects the todos matching root, which is bound to the
+// tenant ID following its own arguments, together with all their
+// descendants.
+func subtreeQuery(root string) string {
+	return `WITH RECURSIVE subtree (id) AS (
+			SELECT id FROM todos WHERE ` + root + ` AND tenant_id = ?
+			UNION ALL
+			SELECT todos.id FROM todos JOIN subtree ON todos.parent_id = subtree.id
+		)
+		SELECT ` + todoColumns + ` FROM todos WHERE id IN (SELECT id FROM subtree)`
+}
+
+// parentArg converts a requested parent ID to the stored parent_id, where 0
+// means none. It checks that the parent belongs to the tenant and, for an
+// existing todo, that the todo is not the parent or one of its ancestors.
+// Each ancestor is locked before its parent is read, so two todos moved
+// under each other at once cannot both pass: the second move waits for the
+// first and then sees it.
+func parentArg(ctx context.Context, q querier, tenantID string, todoID int, parentID *int) (interface{}, error) {
+	if parentID == nil || *parentID == 0 {
+		return nil, nil
+	}
+
+	seen := map[int]bool{}
+	for id := *parentID; !seen[id]; {
+		if id == todoID {
+			return nil, fmt.Errorf("todo cannot be its own ancestor")
+		}
+		seen[id] = true
+
+		var next sql.NullInt64
+		err := q.QueryRowContext(ctx, `SELECT parent_id FROM todos WHERE id = ? AND tenant_id = ? FOR UPDATE`,
+			id, tenantID).Scan(&next)
+		if err == sql.ErrNoRows && id == *parentID {
+			return nil, fmt.Errorf("parent todo not found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ancestor: %w", err)
+		}
+		if !next.Valid {
+			break
+		}
+		id = int(next.Int64)
+	}
+	return *parentID, nil
+}
+
+func openChildren(ctx context.Context, q querier, tenantID string, id int) (int, error) {
+	var count int
+	err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM todos
+		WHERE parent_id = ? AND tenant_id = ? AND status NOT IN ('done', 'archived')`, id, tenantID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count subtasks: %w", err)
+	}
+	return count, nil
+}
+
+// completeParents marks parentID done if it has no open subtasks left. The
+// parent is completed through applyUpdate like any other todo, so a
+// recurring parent moves on to its next occurrence and its own parent is
+// checked in turn. It reports whether a reminder may have been set.
+func (r *TodoRepository) completeParents(ctx context.Context, tx *changeTx, tenantID string, parentID *int) (bool, error) {
+	if parentID == nil {
+		return false, nil
+	}
+	parent, err := getTodo(ctx, tx, *parentID, tenantID, true)
+	if err != nil {
+		return false, err
+	}
+	if parent.Status == models.StatusDone || !models.CanTransition(parent.Status, models.StatusDone) {
+		return false, nil
+	}
+
+	open, err := openChildren(ctx, tx, tenantID, parent.ID)
+	if err != nil {
+		return false, err
+	}
+	if open > 0 {
+		return false, nil
+	}
+
+	done := models.StatusDone
+	_, reminderSet, err := r.applyUpdate(ctx, tx, tenantID, parent, &models.UpdateTodoRequest{Status: &done})
+	if err != nil {
+		return false, fmt.Errorf("failed to complete parent todo: %w", err)
+	}
+	return reminderSet, nil
+}
+
+// GetChildren returns a todo's direct subtasks in list order.
+func (r *TodoRepository) GetChildren(ctx context.Context, id int) ([]models.Todo, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	if _, err := getTodo(ctx, r.db, id, tenantID, false); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE parent_id = ? AND tenant_id = ? ORDER BY position, created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, id, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subtasks: %w", err)
+	}
+	defer rows.Close()
+
+	todos := []models.Todo{}
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan todo: %w", err)
+		}
+		todos = append(todos, *todo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating subtasks: %w", err)
+	}
+
+	return todos, nil
+}
+
+// GetTree returns a todo with all of its descendants nested beneath it.
+func (r *TodoRepository) GetTree(ctx context.Context, id int) (*models.TodoNode, error) {
+	query := subtreeQuery("id = ?") + ` ORDER BY position, created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, id, tenant.IDFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query todo tree: %w", err)
+	}
+	defer rows.Close()
+
+	var nodes []*models.TodoNode
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan todo: %w", err)
+		}
+		nodes = append(nodes, &models.TodoNode{Todo: *todo, Children: []*models.TodoNode{}})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating todo tree: %w", err)
+	}
+
+	byID := make(map[int]*models.TodoNode, len(nodes))
+	for _, node := range nodes {
+		byID[node.ID] = node
+	}
+
+	root, ok := byID[id]
+	if !ok {
+		return nil, fmt.Errorf("todo not found")
+	}
+	for _, node := range nodes {
+		if node.ID == id || node.ParentID == nil {
+			continue
+		}
+		if parent, ok := byID[*node.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	return root, nil
+}
@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"test-server/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// subtaskRow returns a todoRow under parentID.
+func subtaskRow(id, parentID int, completed bool, now time.Time) []driver.Value {
+	row := todoRow(id, "default", "Test Todo", "", completed, now)
+	setColumn(row, "parent_id", parentID)
+	return row
+}
+
+// expectAncestor expects the parent walk to lock id and find parentID,
+// where 0 means a root.
+func expectAncestor(mock sqlmock.Sqlmock, id, parentID int) {
+	var parent driver.Value
+	if parentID != 0 {
+		parent = parentID
+	}
+	mock.ExpectQuery("SELECT parent_id FROM todos WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(id, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(parent))
+}
+
+func TestUpdateTodoParentCycle(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	parentID := 3
+
+	expectLockTodo(mock, 1, "default", time.Now())
+	expectAncestor(mock, 3, 2)
+	expectAncestor(mock, 2, 1)
+	mock.ExpectRollback()
+
+	_, err = repo.Update(context.Background(), 1, &models.UpdateTodoRequest{ParentID: &parentID})
+	if err == nil || err.Error() != "todo cannot be its own ancestor" {
+		t.Errorf("Expected cycle error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateTodoParentLocksAncestors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	parentID := 3
+	now := time.Now()
+
+	expectLockTodo(mock, 1, "default", now)
+	expectAncestor(mock, 3, 2)
+	expectAncestor(mock, 2, 0)
+	mock.ExpectExec("UPDATE todos SET parent_id = (.+) WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(3, 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(subtaskRow(1, 3, false, now)...))
+	expectAudit(mock, "default", 1, models.AuditUpdate)
+	mock.ExpectCommit()
+
+	todo, err := repo.Update(context.Background(), 1, &models.UpdateTodoRequest{ParentID: &parentID})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if todo.ParentID == nil || *todo.ParentID != 3 {
+		t.Errorf("Expected parent 3, got %v", todo.ParentID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateTodoCompletesParent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	status := models.StatusDone
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(2, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(subtaskRow(2, 1, false, now)...))
+	mock.ExpectExec("UPDATE todos SET status = (.+) WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(status, sqlmock.AnyArg(), 2, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(2, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(subtaskRow(2, 1, true, now)...))
+	expectAudit(mock, "default", 2, models.AuditUpdate)
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(todoRow(1, "default", "Parent", "", false, now)...))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM todos WHERE parent_id = (.+) AND status NOT IN").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("UPDATE todos SET status = (.+), completed = TRUE, (.+) WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(models.StatusDone, sqlmock.AnyArg(), 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(todoRow(1, "default", "Parent", "", true, now)...))
+	expectAudit(mock, "default", 1, models.AuditUpdate)
+	mock.ExpectCommit()
+
+	if _, err := repo.Update(context.Background(), 2, &models.UpdateTodoRequest{Status: &status}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCompletingLastSubtaskAdvancesRecurringParent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	status := models.StatusDone
+	now := time.Now()
+
+	parent := todoRow(1, "default", "Weekly review", "", false, now)
+	setColumn(parent, "recurrence_rule", "FREQ=WEEKLY")
+	setColumn(parent, "recurrence_tz", "UTC")
+	next := todoRow(3, "default", "Weekly review", "", false, now)
+	setColumn(next, "recurrence_rule", "FREQ=WEEKLY")
+	setColumn(next, "recurrence_tz", "UTC")
+	setColumn(next, "occurrence", 2)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(2, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(subtaskRow(2, 1, false, now)...))
+	mock.ExpectExec("UPDATE todos SET status = (.+) WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(status, sqlmock.AnyArg(), 2, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(2, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(subtaskRow(2, 1, true, now)...))
+	expectAudit(mock, "default", 2, models.AuditUpdate)
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(parent...))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM todos WHERE parent_id = (.+) AND status NOT IN").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("UPDATE todos SET status = (.+), recurrence_rule = NULL, recurrence_tz = NULL WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(status, sqlmock.AnyArg(), 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(todoRow(1, "default", "Weekly review", "", true, now)...))
+	expectAudit(mock, "default", 1, models.AuditUpdate)
+	expectFirstPosition(mock, "default", "V")
+	mock.ExpectExec("INSERT INTO todos").
+		WithArgs("default", "Weekly review", "", sqlmock.AnyArg(), nil, "todo", "medium",
+			nil, nil, "U", "FREQ=WEEKLY", "UTC", 2).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(3, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(next...))
+	expectAudit(mock, "default", 3, models.AuditCreate)
+	mock.ExpectCommit()
+
+	if _, err := repo.Update(context.Background(), 2, &models.UpdateTodoRequest{Status: &status}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateTodoBlockedByOpenSubtasks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db).WithCompletionRules(CompletionRules{BlockOpenChildren: true})
+	status := models.StatusDone
+
+	expectLockTodo(mock, 1, "default", time.Now())
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM todos WHERE parent_id = (.+) AND status NOT IN").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	_, err = repo.Update(context.Background(), 1, &models.UpdateTodoRequest{Status: &status})
+	if err == nil || err.Error() != "todo has open subtasks" {
+		t.Errorf("Expected open subtasks error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetTree(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery("WITH RECURSIVE subtree (.+) SELECT (.+) FROM todos WHERE id IN \\(SELECT id FROM subtree\\)").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).
+			AddRow(todoRow(1, "default", "Root", "", false, now)...).
+			AddRow(subtaskRow(2, 1, false, now)...).
+			AddRow(subtaskRow(3, 2, false, now)...).
+			AddRow(subtaskRow(4, 1, true, now)...))
+
+	tree, err := repo.GetTree(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(tree.Children) != 2 || tree.Children[0].ID != 2 || tree.Children[1].ID != 4 {
+		t.Fatalf("Unexpected children %+v", tree.Children)
+	}
+	if len(tree.Children[0].Children) != 1 || tree.Children[0].Children[0].ID != 3 {
+		t.Errorf("Expected grandchild 3, got %+v", tree.Children[0].Children)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetTreeNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+
+	mock.ExpectQuery("WITH RECURSIVE subtree").
+		WithArgs(9, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns))
+
+	_, err = repo.GetTree(context.Background(), 9)
+	if err == nil || err.Error() != "todo not found" {
+		t.Errorf("Expected todo not found, got %v", err)
+	}
+}
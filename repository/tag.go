@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"test-server/models"
+	"test-server/tenant"
+)
+
+// setTodoTags replaces a todo's tags, creating tags the tenant has not used
+// before. names must already be normalised.
+func setTodoTags(ctx context.Context, q querier, tenantID string, todoID int, names []string) error {
+	if _, err := q.ExecContext(ctx, `DELETE FROM todo_tags WHERE todo_id = ?`, todoID); err != nil {
+		return fmt.Errorf("failed to clear tags: %w", err)
+	}
+
+	for _, name := range names {
+		// LAST_INSERT_ID(id) makes an existing tag report its own ID.
+		result, err := q.ExecContext(ctx,
+			`INSERT INTO tags (tenant_id, name) VALUES (?, ?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`,
+			tenantID, name)
+		if err != nil {
+			return fmt.Errorf("failed to save tag: %w", err)
+		}
+		tagID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get tag id: %w", err)
+		}
+
+		if _, err := q.ExecContext(ctx, `INSERT INTO todo_tags (todo_id, tag_id) VALUES (?, ?)`, todoID, tagID); err != nil {
+			return fmt.Errorf("failed to tag todo: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// tagFilter returns a condition on todos.id matching todos tagged with any
+// (or all) of names.
+func tagFilter(tenantID string, names []string, matchAll bool) (string, []interface{}) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	args := []interface{}{tenantID}
+	for _, name := range names {
+		args = append(args, name)
+	}
+
+	cond := `id IN (SELECT todo_tags.todo_id FROM todo_tags JOIN tags ON tags.id = todo_tags.tag_id
+		WHERE tags.tenant_id = ? AND tags.name IN (` + placeholders + `)`
+	if matchAll {
+		cond += ` GROUP BY todo_tags.todo_id HAVING COUNT(DISTINCT tags.id) = ?`
+		args = append(args, len(names))
+	}
+	return cond + `)`, args
+}
+
+func (r *TodoRepository) ListTags(ctx context.Context) ([]models.TagCount, error) {
+	query := `SELECT tags.name, COUNT(todo_tags.todo_id) AS usage_count
+		FROM tags LEFT JOIN todo_tags ON todo_tags.tag_id = tags.id
+		WHERE tags.tenant_id = ?
+		GROUP BY tags.id, tags.name
+		ORDER BY usage_count DESC, tags.name`
+	rows, err := r.db.QueryContext(ctx, query, tenant.IDFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []models.TagCount{}
+	for rows.Next() {
+		var tag models.TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %w", err)
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+
+	"test-server/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCreateTodoWithTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	expectFirstPosition(mock, "default", "V")
+	mock.ExpectExec("INSERT INTO todos").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM todo_tags WHERE todo_id = ?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	for i, name := range []string{"home", "urgent"} {
+		mock.ExpectExec("INSERT INTO tags (.+) ON DUPLICATE KEY UPDATE").
+			WithArgs("default", name).
+			WillReturnResult(sqlmock.NewResult(int64(10+i), 1))
+		mock.ExpectExec("INSERT INTO todo_tags").
+			WithArgs(1, int64(10+i)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	row := todoRow(1, "default", "Test Todo", "", false, now)
+	row[len(row)-1] = "home,urgent"
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(row...))
+	expectAudit(mock, "default", 1, models.AuditCreate)
+	mock.ExpectCommit()
+
+	todo, err := repo.Create(context.Background(), &models.CreateTodoRequest{Title: "Test Todo", Tags: []string{"home", "urgent"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(todo.Tags, []string{"home", "urgent"}) {
+		t.Errorf("Expected tags [home urgent], got %v", todo.Tags)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetAllTodosByTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		match string
+		query string
+		args  []driver.Value
+	}{
+		{"any", "any", "SELECT (.+) FROM todos WHERE tenant_id = (.+) AND id IN \\(SELECT todo_tags.todo_id (.+) tags.name IN \\(\\?, \\?\\)\\) ORDER BY",
+			[]driver.Value{"default", "default", "home", "work"}},
+		{"all", "all", "SELECT (.+) FROM todos WHERE tenant_id = (.+) AND id IN \\(SELECT todo_tags.todo_id (.+) HAVING COUNT\\(DISTINCT tags.id\\) = \\?\\) ORDER BY",
+			[]driver.Value{"default", "default", "home", "work", 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectQuery(tt.query).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows(todoRowColumns))
+
+			filter := models.TodoFilter{Tags: []string{"home", "work"}, TagMatch: tt.match}
+			if _, err := NewTodoRepository(db).GetAll(context.Background(), filter); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestListTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT tags.name, COUNT\\(todo_tags.todo_id\\) (.+) WHERE tags.tenant_id = ?").
+		WithArgs("default").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "usage_count"}).
+			AddRow("home", 3).
+			AddRow("archive", 0))
+
+	tags, err := NewTodoRepository(db).ListTags(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := []models.TagCount{{Name: "home", Count: 3}, {Name: "archive", Count: 0}}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("Expected %v, got %v", want, tags)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
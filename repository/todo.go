@@ -1,24 +1,249 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"test-server/models"
+	"test-server/ranking"
+	"test-server/tenant"
 )
 
+// todoColumns ends with the todo's comment count and tags, folded into
+// single columns so that listing todos costs a single query.
+const todoColumns = `id, tenant_id, title, description, completed, created_at, updated_at, due_at, remind_at, reminder_sent_at, status, priority, completed_at, list_id, parent_id, position, recurrence_rule, recurrence_tz, occurrence, ` +
+	`(SELECT COUNT(*) FROM comments WHERE comments.todo_id = todos.id) AS comment_count, ` +
+	`(SELECT GROUP_CONCAT(tags.name ORDER BY tags.name SEPARATOR ',') FROM todo_tags JOIN tags ON tags.id = todo_tags.tag_id WHERE todo_tags.todo_id = todos.id) AS tags`
+
 type TodoRepository struct {
-	db *sql.DB
+	db         *sql.DB
+	quotas     tenant.Quotas
+	searchMode SearchMode
+	// reminderHook is called after a write that may move the next reminder.
+	reminderHook func()
+	// outboxHook is called after a transaction that queued events commits.
+	outboxHook func()
+	// deliveryHook is called after a webhook delivery is requeued.
+	deliveryHook    func()
+	completionRules CompletionRules
 }
 
 func NewTodoRepository(db *sql.DB) *TodoRepository {
-	return &TodoRepository{db: db}
+	return &TodoRepository{db: db, completionRules: CompletionRules{AutoCompleteParents: true}}
+}
+
+// WithQuotas sets the per-tenant todo limits enforced by Create, imports
+// and new occurrences of recurring todos.
+func (r *TodoRepository) WithQuotas(quotas tenant.Quotas) *TodoRepository {
+	r.quotas = quotas
+	return r
+}
+
+// WithSearchMode selects how Search matches todos.
+func (r *TodoRepository) WithSearchMode(mode SearchMode) *TodoRepository {
+	r.searchMode = mode
+	return r
+}
+
+// WithReminderHook registers fn to run whenever a todo's remind_at is set, so
+// a reminder scheduler can re-plan without waiting for its next poll.
+func (r *TodoRepository) WithReminderHook(fn func()) *TodoRepository {
+	r.reminderHook = fn
+	return r
 }
 
-func (r *TodoRepository) Create(todo *models.CreateTodoRequest) (*models.Todo, error) {
-	query := `INSERT INTO todos (title, description) VALUES (?, ?)`
-	result, err := r.db.Exec(query, todo.Title, todo.Description)
+func (r *TodoRepository) reminderChanged() {
+	if r.reminderHook != nil {
+		r.reminderHook()
+	}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// scanTodo scans todoColumns followed by any extra selected columns.
+func scanTodo(row rowScanner, extra ...interface{}) (*models.Todo, error) {
+	var todo models.Todo
+	var dueAt, remindAt, reminderSentAt, completedAt sql.NullTime
+	var tags sql.NullString
+	var listID, parentID sql.NullInt64
+	var rule, timezone sql.NullString
+	var occurrence int
+	dest := []interface{}{
+		&todo.ID, &todo.TenantID, &todo.Title, &todo.Description, &todo.Completed, &todo.CreatedAt, &todo.UpdatedAt,
+		&dueAt, &remindAt, &reminderSentAt, &todo.Status, &todo.Priority, &completedAt, &listID, &parentID, &todo.Position,
+		&rule, &timezone, &occurrence, &todo.CommentCount, &tags,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	todo.DueAt = nullTimePtr(dueAt)
+	todo.RemindAt = nullTimePtr(remindAt)
+	todo.ReminderSentAt = nullTimePtr(reminderSentAt)
+	todo.CompletedAt = nullTimePtr(completedAt)
+	todo.ListID = nullIntPtr(listID)
+	todo.ParentID = nullIntPtr(parentID)
+	if rule.Valid {
+		todo.Recurrence = &models.Recurrence{Rule: rule.String, Timezone: timezone.String, Occurrence: occurrence}
+	}
+	todo.Tags = []string{}
+	if tags.Valid && tags.String != "" {
+		todo.Tags = strings.Split(tags.String, ",")
+	}
+	return &todo, nil
+}
+
+// queryTodos runs a query selecting todoColumns.
+func queryTodos(ctx context.Context, q querier, query string, args ...interface{}) ([]models.Todo, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query todos: %w", err)
+	}
+	defer rows.Close()
+
+	todos := []models.Todo{}
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan todo: %w", err)
+		}
+		todos = append(todos, *todo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating todos: %w", err)
+	}
+
+	return todos, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullIntPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
+
+func intArg(n *int) interface{} {
+	if n == nil {
+		return nil
+	}
+	return *n
+}
+
+func timeArg(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+// listArg converts a requested list ID to the stored list_id, where the
+// inbox is NULL. It checks that the list belongs to the tenant.
+func listArg(ctx context.Context, q querier, tenantID string, listID *int) (interface{}, error) {
+	if listID == nil || *listID == models.InboxListID {
+		return nil, nil
+	}
+	var id int
+	err := q.QueryRowContext(ctx, `SELECT id FROM lists WHERE id = ? AND tenant_id = ?`, *listID, tenantID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("list not found")
+		}
+		return nil, fmt.Errorf("failed to get list: %w", err)
+	}
+	return id, nil
+}
+
+// checkQuota fails if adding todos would take the tenant over limit, where
+// zero means unlimited. Locking the tenant's rows serialises concurrent
+// creates so two requests cannot both slip in under the limit.
+func checkQuota(ctx context.Context, q querier, tenantID string, limit, adding int) error {
+	if limit <= 0 {
+		return nil
+	}
+	var count int
+	err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM todos WHERE tenant_id = ? FOR UPDATE`, tenantID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count todos: %w", err)
+	}
+	if count+adding > limit {
+		return fmt.Errorf("todo quota exceeded")
+	}
+	return nil
+}
+
+func (r *TodoRepository) Create(ctx context.Context, todo *models.CreateTodoRequest) (*models.Todo, error) {
+	tenantID := tenant.IDFromContext(ctx)
+
+	tx, err := r.beginChange(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkQuota(ctx, tx, tenantID, r.quotas.Limit(tenantID), 1); err != nil {
+		return nil, err
+	}
+
+	status := todo.Status
+	if status == "" {
+		status = models.StatusTodo
+	}
+	priority := todo.Priority
+	if priority == "" {
+		priority = models.PriorityMedium
+	}
+	var completedAt interface{}
+	if status == models.StatusDone {
+		completedAt = time.Now().UTC()
+	}
+
+	listID, err := listArg(ctx, tx, tenantID, todo.ListID)
+	if err != nil {
+		return nil, err
+	}
+	parentID, err := parentArg(ctx, tx, tenantID, 0, todo.ParentID)
+	if err != nil {
+		return nil, err
+	}
+
+	position, err := firstPosition(ctx, tx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rule string
+	if todo.Recurrence != nil {
+		rule = *todo.Recurrence
+	}
+	ruleArg, tzArg := recurrenceArgs(rule, todo.Timezone)
+
+	query := `INSERT INTO todos (tenant_id, title, description, due_at, remind_at, status, priority, completed, completed_at,
+			list_id, parent_id, position, recurrence_rule, recurrence_tz)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.ExecContext(ctx, query, tenantID, todo.Title, todo.Description,
+		timeArg(todo.ParsedDueAt), timeArg(todo.ParsedRemindAt),
+		status, priority, status == models.StatusDone, completedAt, listID, parentID, position, ruleArg, tzArg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create todo: %w", err)
 	}
@@ -28,12 +253,89 @@ func (r *TodoRepository) Create(todo *models.CreateTodoRequest) (*models.Todo, e
 		return nil, fmt.Errorf("failed to get last insert id: %w", err)
 	}
 
-	return r.GetByID(int(id))
+	if len(todo.Tags) > 0 {
+		if err := setTodoTags(ctx, tx, tenantID, int(id), todo.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(position) > ranking.MaxLength {
+		if err := rebalance(ctx, tx, tenantID); err != nil {
+			return nil, err
+		}
+	}
+
+	created, err := getTodo(ctx, tx, int(id), tenantID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAudit(ctx, tx, tenantID, models.AuditCreate, created.ID, nil, created); err != nil {
+		return nil, err
+	}
+
+	reminderSet := todo.ParsedRemindAt != nil
+	if parentID != nil && status == models.StatusDone && r.completionRules.AutoCompleteParents {
+		parentReminder, err := r.completeParents(ctx, tx, tenantID, todo.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		reminderSet = reminderSet || parentReminder
+	}
+
+	if err := r.commitChange(ctx, tx); err != nil {
+		return nil, fmt.Errorf("failed to commit todo: %w", err)
+	}
+
+	if reminderSet {
+		r.reminderChanged()
+	}
+
+	return created, nil
+}
+
+// todoFilterWhere builds the WHERE clause selecting the tenant's todos that
+// match filter.
+func todoFilterWhere(ctx context.Context, filter models.TodoFilter) (string, []interface{}) {
+	where := []string{"tenant_id = ?"}
+	args := []interface{}{tenant.IDFromContext(ctx)}
+
+	if filter.ListID != nil {
+		if *filter.ListID == models.InboxListID {
+			where = append(where, "list_id IS NULL")
+		} else {
+			where = append(where, "list_id = ?")
+			args = append(args, *filter.ListID)
+		}
+	}
+	if filter.DueBefore != nil {
+		where = append(where, "due_at < ?")
+		args = append(args, *filter.DueBefore)
+	}
+	if filter.Overdue {
+		where = append(where, "due_at < ? AND status NOT IN ('done', 'archived')")
+		args = append(args, time.Now().UTC())
+	}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.Priority != "" {
+		where = append(where, "priority = ?")
+		args = append(args, filter.Priority)
+	}
+	if len(filter.Tags) > 0 {
+		cond, tagArgs := tagFilter(tenant.IDFromContext(ctx), filter.Tags, filter.TagMatch == "all")
+		where = append(where, cond)
+		args = append(args, tagArgs...)
+	}
+
+	return strings.Join(where, " AND "), args
 }
 
-func (r *TodoRepository) GetAll() ([]models.Todo, error) {
-	query := `SELECT id, title, description, completed, created_at, updated_at FROM todos ORDER BY created_at DESC`
-	rows, err := r.db.Query(query)
+func (r *TodoRepository) GetAll(ctx context.Context, filter models.TodoFilter) ([]models.Todo, error) {
+	where, args := todoFilterWhere(ctx, filter)
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE ` + where + ` ORDER BY position, created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query todos: %w", err)
 	}
@@ -41,12 +343,11 @@ func (r *TodoRepository) GetAll() ([]models.Todo, error) {
 
 	var todos []models.Todo
 	for rows.Next() {
-		var todo models.Todo
-		err := rows.Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.CreatedAt, &todo.UpdatedAt)
+		todo, err := scanTodo(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan todo: %w", err)
 		}
-		todos = append(todos, todo)
+		todos = append(todos, *todo)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -56,10 +357,50 @@ func (r *TodoRepository) GetAll() ([]models.Todo, error) {
 	return todos, nil
 }
 
-func (r *TodoRepository) GetByID(id int) (*models.Todo, error) {
-	query := `SELECT id, title, description, completed, created_at, updated_at FROM todos WHERE id = ?`
-	var todo models.Todo
-	err := r.db.QueryRow(query, id).Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Completed, &todo.CreatedAt, &todo.UpdatedAt)
+// GetAllVersion summarises the todos GetAll would return without loading
+// them. The latest audit entry stands in for changes that leave no trace on
+// the remaining rows, such as deletes and edits within the same second. It
+// is one aggregate query over the same rows as GetAll, with a subquery per
+// todo for its comments, so a full list response costs both queries.
+func (r *TodoRepository) GetAllVersion(ctx context.Context, filter models.TodoFilter) (*models.TodoListVersion, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	where, args := todoFilterWhere(ctx, filter)
+	query := `SELECT COUNT(*), MAX(updated_at),
+		COALESCE(SUM((SELECT COUNT(*) FROM comments WHERE comments.todo_id = todos.id)), 0),
+		COALESCE(MAX((SELECT MAX(id) FROM comments WHERE comments.todo_id = todos.id)), 0),
+		(SELECT id FROM todo_audit WHERE todo_audit.tenant_id = ? ORDER BY created_at DESC, id DESC LIMIT 1),
+		(SELECT MAX(created_at) FROM todo_audit WHERE todo_audit.tenant_id = ?)
+		FROM todos WHERE ` + where
+
+	var version models.TodoListVersion
+	var updatedAt, changedAt sql.NullTime
+	var changeID sql.NullInt64
+	err := r.db.QueryRowContext(ctx, query, append([]interface{}{tenantID, tenantID}, args...)...).
+		Scan(&version.Count, &updatedAt, &version.Comments, &version.LastCommentID, &changeID, &changedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarise todos: %w", err)
+	}
+
+	version.ChangeID = changeID.Int64
+	version.LastModified = updatedAt.Time
+	if changedAt.Valid && changedAt.Time.After(version.LastModified) {
+		version.LastModified = changedAt.Time
+	}
+	return &version, nil
+}
+
+func (r *TodoRepository) GetByID(ctx context.Context, id int) (*models.Todo, error) {
+	return getTodo(ctx, r.db, id, tenant.IDFromContext(ctx), false)
+}
+
+// getTodo loads a tenant's todo, optionally locking the row for the rest of
+// the transaction.
+func getTodo(ctx context.Context, q querier, id int, tenantID string, forUpdate bool) (*models.Todo, error) {
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE id = ? AND tenant_id = ?`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	todo, err := scanTodo(q.QueryRowContext(ctx, query, id, tenantID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("todo not found")
@@ -67,10 +408,51 @@ func (r *TodoRepository) GetByID(id int) (*models.Todo, error) {
 		return nil, fmt.Errorf("failed to get todo: %w", err)
 	}
 
-	return &todo, nil
+	return todo, nil
+}
+
+func (r *TodoRepository) Update(ctx context.Context, id int, req *models.UpdateTodoRequest) (*models.Todo, error) {
+	if req.Title == nil && req.Description == nil && req.Status == nil && req.Completed == nil && req.Priority == nil &&
+		req.DueAt == nil && req.RemindAt == nil && req.Tags == nil && req.ListID == nil && req.ParentID == nil &&
+		req.Recurrence == nil {
+		return r.GetByID(ctx, id)
+	}
+
+	tenantID := tenant.IDFromContext(ctx)
+
+	tx, err := r.beginChange(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getTodo(ctx, tx, id, tenantID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, reminderSet, err := r.applyUpdate(ctx, tx, tenantID, current, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.commitChange(ctx, tx); err != nil {
+		return nil, fmt.Errorf("failed to commit todo: %w", err)
+	}
+
+	if reminderSet {
+		r.reminderChanged()
+	}
+
+	return updated, nil
 }
 
-func (r *TodoRepository) Update(id int, req *models.UpdateTodoRequest) (*models.Todo, error) {
+// applyUpdate applies req to current, which the caller has locked, checking
+// the status workflow against it and running the completion rules. It
+// returns the updated todo and whether a reminder may have been set.
+func (r *TodoRepository) applyUpdate(ctx context.Context, tx *changeTx, tenantID string, current *models.Todo,
+	req *models.UpdateTodoRequest) (*models.Todo, bool, error) {
+	id := current.ID
 	var setParts []string
 	var args []interface{}
 
@@ -82,41 +464,177 @@ func (r *TodoRepository) Update(id int, req *models.UpdateTodoRequest) (*models.
 		setParts = append(setParts, "description = ?")
 		args = append(args, *req.Description)
 	}
-	if req.Completed != nil {
-		setParts = append(setParts, "completed = ?")
-		args = append(args, *req.Completed)
+
+	// The legacy completed flag is mapped onto the workflow from the locked
+	// row, so a concurrent change cannot slip a forbidden transition past.
+	status := req.Status
+	if status == nil && req.Completed != nil {
+		mapped := models.StatusForCompleted(*req.Completed, current.Status)
+		status = &mapped
+	}
+	if status != nil {
+		if !models.CanTransition(current.Status, *status) {
+			return nil, false, &models.TransitionError{From: current.Status, To: *status}
+		}
+		// completed mirrors the workflow for older clients; archiving keeps
+		// whatever it was.
+		setParts = append(setParts, "status = ?")
+		args = append(args, *status)
+		switch *status {
+		case models.StatusDone:
+			setParts = append(setParts, "completed = TRUE", "completed_at = COALESCE(completed_at, ?)")
+			args = append(args, time.Now().UTC())
+		case models.StatusArchived:
+		default:
+			setParts = append(setParts, "completed = FALSE", "completed_at = NULL")
+		}
+	}
+	if req.Priority != nil {
+		setParts = append(setParts, "priority = ?")
+		args = append(args, *req.Priority)
+	}
+	if req.DueAt != nil {
+		setParts = append(setParts, "due_at = ?")
+		args = append(args, timeArg(req.ParsedDueAt))
+	}
+	if req.RemindAt != nil {
+		// A new reminder time re-arms the reminder.
+		setParts = append(setParts, "remind_at = ?", "reminder_sent_at = NULL")
+		args = append(args, timeArg(req.ParsedRemindAt))
+	}
+
+	completing := status != nil && *status == models.StatusDone
+	if completing && current.Status != models.StatusDone && r.completionRules.BlockOpenChildren {
+		open, err := openChildren(ctx, tx, tenantID, id)
+		if err != nil {
+			return nil, false, err
+		}
+		if open > 0 {
+			return nil, false, fmt.Errorf("todo has open subtasks")
+		}
+	}
+
+	// A rule sent along with the completion already applies to the next
+	// occurrence. The completed todo hands the rule on, so reopening and
+	// completing it again does not repeat it twice.
+	series := current.Recurrence
+	if req.Recurrence != nil {
+		series = nil
+		if *req.Recurrence != "" {
+			occurrence := 1
+			if current.Recurrence != nil {
+				occurrence = current.Recurrence.Occurrence
+			}
+			series = &models.Recurrence{Rule: *req.Recurrence, Timezone: req.Timezone, Occurrence: occurrence}
+		}
+	}
+	spawn := completing && current.Status != models.StatusDone && series != nil
+	switch {
+	case spawn:
+		setParts = append(setParts, "recurrence_rule = NULL", "recurrence_tz = NULL")
+	case req.Recurrence != nil:
+		ruleArg, tzArg := recurrenceArgs(*req.Recurrence, req.Timezone)
+		setParts = append(setParts, "recurrence_rule = ?", "recurrence_tz = ?")
+		args = append(args, ruleArg, tzArg)
+	}
+
+	parentID := current.ParentID
+	if req.ParentID != nil {
+		arg, err := parentArg(ctx, tx, tenantID, id, req.ParentID)
+		if err != nil {
+			return nil, false, err
+		}
+		setParts = append(setParts, "parent_id = ?")
+		args = append(args, arg)
+		parentID = nil
+		if arg != nil {
+			parentID = req.ParentID
+		}
+	}
+
+	if req.ListID != nil {
+		listID, err := listArg(ctx, tx, tenantID, req.ListID)
+		if err != nil {
+			return nil, false, err
+		}
+		setParts = append(setParts, "list_id = ?")
+		args = append(args, listID)
 	}
 
+	if req.Tags != nil {
+		if err := setTodoTags(ctx, tx, tenantID, id, *req.Tags); err != nil {
+			return nil, false, err
+		}
+	}
 	if len(setParts) == 0 {
-		return r.GetByID(id)
+		// Retagging alone still counts as a modification.
+		setParts = append(setParts, "updated_at = CURRENT_TIMESTAMP")
 	}
 
-	args = append(args, id)
-	query := fmt.Sprintf("UPDATE todos SET %s WHERE id = ?", strings.Join(setParts, ", "))
+	args = append(args, id, tenantID)
+	query := fmt.Sprintf("UPDATE todos SET %s WHERE id = ? AND tenant_id = ?", strings.Join(setParts, ", "))
 
-	_, err := r.db.Exec(query, args...)
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return nil, false, fmt.Errorf("failed to update todo: %w", err)
+	}
+
+	updated, err := auditUpdate(ctx, tx, tenantID, current)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update todo: %w", err)
+		return nil, false, err
+	}
+
+	reminderSet := req.RemindAt != nil
+	if spawn {
+		hasReminder, err := spawnOccurrence(ctx, tx, tenantID, updated, *series, time.Now().UTC(), r.quotas.Limit(tenantID))
+		if err != nil {
+			return nil, false, err
+		}
+		reminderSet = reminderSet || hasReminder
+	}
+
+	if completing && r.completionRules.AutoCompleteParents {
+		parentReminder, err := r.completeParents(ctx, tx, tenantID, parentID)
+		if err != nil {
+			return nil, false, err
+		}
+		reminderSet = reminderSet || parentReminder
 	}
 
-	return r.GetByID(id)
+	return updated, reminderSet, nil
 }
 
-func (r *TodoRepository) Delete(id int) error {
-	query := `DELETE FROM todos WHERE id = ?`
-	result, err := r.db.Exec(query, id)
+// Delete removes a todo; its subtasks go with it through the parent_id
+// foreign key. Each removed todo gets its own audit entry.
+func (r *TodoRepository) Delete(ctx context.Context, id int) error {
+	tenantID := tenant.IDFromContext(ctx)
+
+	tx, err := r.beginChange(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to delete todo: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	removed, err := queryTodos(ctx, tx, subtreeQuery("id = ?")+` FOR UPDATE`, id, tenantID)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return err
 	}
-
-	if rowsAffected == 0 {
+	if len(removed) == 0 {
 		return fmt.Errorf("todo not found")
 	}
 
+	if _, err := tx.ExecContext(ctx, `DELETE FROM todos WHERE id = ? AND tenant_id = ?`, id, tenantID); err != nil {
+		return fmt.Errorf("failed to delete todo: %w", err)
+	}
+
+	for i := range removed {
+		if err := writeAudit(ctx, tx, tenantID, models.AuditDelete, removed[i].ID, &removed[i], nil); err != nil {
+			return err
+		}
+	}
+
+	if err := r.commitChange(ctx, tx); err != nil {
+		return fmt.Errorf("failed to commit todo delete: %w", err)
+	}
+
 	return nil
 }
@@ -1,14 +1,71 @@
 package repository
 
 import (
+	"context"
+	"database/sql/driver"
+	"errors"
 	"testing"
 	"time"
 
 	"test-server/models"
+	"test-server/tenant"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
 
+var todoRowColumns = []string{"id", "tenant_id", "title", "description", "completed", "created_at", "updated_at", "due_at", "remind_at", "reminder_sent_at", "status", "priority", "completed_at", "list_id", "parent_id", "position", "recurrence_rule", "recurrence_tz", "occurrence", "comment_count", "tags"}
+
+// expectLockTodo expects Update's transaction to open by locking the todo.
+func expectLockTodo(mock sqlmock.Sqlmock, id int, tenantID string, now time.Time) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(id, tenantID).
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(todoRow(id, tenantID, "Test Todo", "", false, now)...))
+}
+
+// expectAudit expects an audit entry for an anonymous change to todoID and,
+// unless it was deleted, its next revision.
+func expectAudit(mock sqlmock.Sqlmock, tenantID string, todoID int, action string) {
+	mock.ExpectExec("INSERT INTO todo_audit").
+		WithArgs(tenantID, todoID, "anonymous", action, sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs(tenantID, sqlmock.AnyArg(), models.EventType(action), todoID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	if action != models.AuditDelete {
+		mock.ExpectExec("INSERT INTO todo_revisions (.+) SELECT (.+) FROM todo_revisions WHERE todo_id = ?").
+			WithArgs(tenantID, todoID, "anonymous", sqlmock.AnyArg(), todoID).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+}
+
+// setColumn sets one column of a todoRow.
+func setColumn(row []driver.Value, column string, value driver.Value) {
+	for i, name := range todoRowColumns {
+		if name == column {
+			row[i] = value
+			return
+		}
+	}
+	panic("unknown column " + column)
+}
+
+// expectFirstPosition expects Create to look up the tenant's first position.
+func expectFirstPosition(mock sqlmock.Sqlmock, tenantID, first string) {
+	mock.ExpectQuery("SELECT position FROM todos WHERE tenant_id = (.+) ORDER BY position LIMIT 1 FOR UPDATE").
+		WithArgs(tenantID).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(first))
+}
+
+// todoRow returns a row for todoRowColumns with unset optional columns.
+func todoRow(id int, tenantID, title, description string, completed bool, now time.Time) []driver.Value {
+	status, completedAt := "todo", driver.Value(nil)
+	if completed {
+		status, completedAt = "done", now
+	}
+	return []driver.Value{id, tenantID, title, description, completed, now, now, nil, nil, nil, status, "medium", completedAt, nil, nil, "V", nil, nil, 1, 0, nil}
+}
+
 func TestCreateTodo(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -25,18 +82,21 @@ func TestCreateTodo(t *testing.T) {
 
 	now := time.Now()
 
+	mock.ExpectBegin()
+	expectFirstPosition(mock, "default", "V")
 	mock.ExpectExec("INSERT INTO todos").
-		WithArgs(req.Title, req.Description).
+		WithArgs("default", req.Title, req.Description, nil, nil, "todo", "medium", false, nil, nil, nil, "U", nil, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	rows := sqlmock.NewRows(todoRowColumns).
+		AddRow(todoRow(1, "default", "Test Todo", "Test Description", false, now)...)
 
-	rows := sqlmock.NewRows([]string{"id", "title", "description", "completed", "created_at", "updated_at"}).
-		AddRow(1, "Test Todo", "Test Description", false, now, now)
-	
-	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = ?").
-		WithArgs(1).
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
 		WillReturnRows(rows)
+	expectAudit(mock, "default", 1, models.AuditCreate)
+	mock.ExpectCommit()
 
-	todo, err := repo.Create(req)
+	todo, err := repo.Create(context.Background(), req)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -61,14 +121,15 @@ func TestGetAllTodos(t *testing.T) {
 
 	now := time.Now()
 
-	rows := sqlmock.NewRows([]string{"id", "title", "description", "completed", "created_at", "updated_at"}).
-		AddRow(1, "Todo 1", "Description 1", false, now, now).
-		AddRow(2, "Todo 2", "Description 2", true, now, now)
+	rows := sqlmock.NewRows(todoRowColumns).
+		AddRow(todoRow(1, "default", "Todo 1", "Description 1", false, now)...).
+		AddRow(todoRow(2, "default", "Todo 2", "Description 2", true, now)...)
 
-	mock.ExpectQuery("SELECT (.+) FROM todos ORDER BY created_at DESC").
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE tenant_id = (.+) ORDER BY position, created_at DESC").
+		WithArgs("default").
 		WillReturnRows(rows)
 
-	todos, err := repo.GetAll()
+	todos, err := repo.GetAll(context.Background(), models.TodoFilter{})
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -93,12 +154,12 @@ func TestGetTodoByID(t *testing.T) {
 
 	now := time.Now()
 
-	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = ?").
-		WithArgs(1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "description", "completed", "created_at", "updated_at"}).
-			AddRow(1, "Test Todo", "Test Description", false, now, now))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).
+			AddRow(todoRow(1, "default", "Test Todo", "Test Description", false, now)...))
 
-	todo, err := repo.GetByID(1)
+	todo, err := repo.GetByID(context.Background(), 1)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -130,16 +191,18 @@ func TestUpdateTodo(t *testing.T) {
 
 	now := time.Now()
 
-	mock.ExpectExec("UPDATE todos SET (.+) WHERE id = ?").
-		WithArgs(title, completed, 1).
+	expectLockTodo(mock, 1, "default", now)
+	mock.ExpectExec("UPDATE todos SET (.+) WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(title, models.StatusDone, sqlmock.AnyArg(), 1, "default").
 		WillReturnResult(sqlmock.NewResult(0, 1))
-
-	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = ?").
-		WithArgs(1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "description", "completed", "created_at", "updated_at"}).
-			AddRow(1, title, "Test Description", completed, now, now))
-
-	todo, err := repo.Update(1, req)
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).
+			AddRow(todoRow(1, "default", title, "Test Description", completed, now)...))
+	expectAudit(mock, "default", 1, models.AuditUpdate)
+	mock.ExpectCommit()
+
+	todo, err := repo.Update(context.Background(), 1, req)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -161,12 +224,126 @@ func TestDeleteTodo(t *testing.T) {
 	defer db.Close()
 
 	repo := NewTodoRepository(db)
+	now := time.Now()
 
-	mock.ExpectExec("DELETE FROM todos WHERE id = ?").
-		WithArgs(1).
+	mock.ExpectBegin()
+	mock.ExpectQuery("WITH RECURSIVE subtree (.+) WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).
+			AddRow(todoRow(1, "default", "Test Todo", "", false, now)...).
+			AddRow(subtaskRow(2, 1, false, now)...))
+	mock.ExpectExec("DELETE FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, "default", 1, models.AuditDelete)
+	expectAudit(mock, "default", 2, models.AuditDelete)
+	mock.ExpectCommit()
+
+	err = repo.Delete(context.Background(), 1)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCreateTodoQuotaExceeded(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db).WithQuotas(tenant.Quotas{PerTenant: map[string]int{"acme": 2}})
+	ctx := tenant.WithID(context.Background(), "acme")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM todos WHERE tenant_id = (.+) FOR UPDATE").
+		WithArgs("acme").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	_, err = repo.Create(ctx, &models.CreateTodoRequest{Title: "One too many"})
+	if err == nil || err.Error() != "todo quota exceeded" {
+		t.Errorf("Expected quota error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetTodoByIDOtherTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	ctx := tenant.WithID(context.Background(), "globex")
+
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "globex").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns))
+
+	_, err = repo.GetByID(ctx, 1)
+	if err == nil || err.Error() != "todo not found" {
+		t.Errorf("Expected todo not found, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetAllVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	updated := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	deleted := updated.Add(time.Minute)
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM todos WHERE tenant_id = (.+) AND status = ?").
+		WithArgs("default", "default", "default", models.StatusDone).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "updated_at", "comments", "comment_id", "change_id", "changed_at"}).
+			AddRow(3, updated, 4, 17, 42, deleted))
+
+	version, err := repo.GetAllVersion(context.Background(), models.TodoFilter{Status: models.StatusDone})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := models.TodoListVersion{Count: 3, LastModified: deleted, ChangeID: 42, Comments: 4, LastCommentID: 17}
+	if *version != want {
+		t.Errorf("Expected %+v, got %+v", want, *version)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetAllTodosOverdue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	dueBefore := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
 
-	err = repo.Delete(1)
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE tenant_id = (.+) AND due_at < (.+) AND due_at < (.+) AND status NOT IN (.+) ORDER BY position, created_at DESC").
+		WithArgs("default", dueBefore, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(todoRowColumns))
+
+	_, err = repo.GetAll(context.Background(), models.TodoFilter{DueBefore: &dueBefore, Overdue: true})
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -175,3 +352,145 @@ func TestDeleteTodo(t *testing.T) {
 		t.Errorf("Unfulfilled expectations: %v", err)
 	}
 }
+
+func TestUpdateTodoRemindAtRearmsReminder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	woken := false
+	repo := NewTodoRepository(db).WithReminderHook(func() { woken = true })
+
+	remindAt := "2026-03-01T09:00:00Z"
+	req := &models.UpdateTodoRequest{RemindAt: &remindAt}
+	if err := req.ParseTimes(); err != nil {
+		t.Fatalf("Failed to parse times: %v", err)
+	}
+
+	now := time.Now()
+	expectLockTodo(mock, 1, "default", now)
+	mock.ExpectExec("UPDATE todos SET remind_at = (.+), reminder_sent_at = NULL WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(*req.ParsedRemindAt, 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(todoRow(1, "default", "Test Todo", "", false, now)...))
+	mock.ExpectCommit()
+
+	if _, err := repo.Update(context.Background(), 1, req); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if !woken {
+		t.Error("Expected reminder hook to run")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateTodoStatusDone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	status := models.StatusDone
+	now := time.Now()
+
+	expectLockTodo(mock, 1, "default", now)
+	mock.ExpectExec("UPDATE todos SET status = (.+), completed = TRUE, completed_at = COALESCE\\(completed_at, (.+)\\) WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(status, sqlmock.AnyArg(), 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(todoRow(1, "default", "Test Todo", "", true, now)...))
+	expectAudit(mock, "default", 1, models.AuditUpdate)
+	mock.ExpectCommit()
+
+	todo, err := repo.Update(context.Background(), 1, &models.UpdateTodoRequest{Status: &status})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !todo.Completed || todo.CompletedAt == nil {
+		t.Errorf("Expected completed todo with completed_at, got %+v", todo)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateTodoRefusesTransitionFromLockedRow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	completed := true
+	now := time.Now()
+	row := todoRow(1, "default", "Test Todo", "", false, now)
+	setColumn(row, "status", models.StatusArchived)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(row...))
+	mock.ExpectRollback()
+
+	_, err = repo.Update(context.Background(), 1, &models.UpdateTodoRequest{Completed: &completed})
+	var transition *models.TransitionError
+	if !errors.As(err, &transition) || transition.From != models.StatusArchived || transition.To != models.StatusDone {
+		t.Errorf("Expected archived to done to be refused, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateTodoLegacyCompletedOnBlockedTodo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	completed := true
+	now := time.Now()
+	row := todoRow(1, "default", "Test Todo", "", false, now)
+	setColumn(row, "status", models.StatusBlocked)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = (.+) FOR UPDATE").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(row...))
+	mock.ExpectExec("UPDATE todos SET status = (.+), completed = TRUE, completed_at = COALESCE\\(completed_at, (.+)\\) WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(models.StatusDone, sqlmock.AnyArg(), 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows(todoRowColumns).AddRow(todoRow(1, "default", "Test Todo", "", true, now)...))
+	expectAudit(mock, "default", 1, models.AuditUpdate)
+	mock.ExpectCommit()
+
+	todo, err := repo.Update(context.Background(), 1, &models.UpdateTodoRequest{Completed: &completed})
+	if err != nil {
+		t.Fatalf("Expected the legacy flag to finish a blocked todo, got %v", err)
+	}
+	if todo.Status != models.StatusDone {
+		t.Errorf("Expected status done, got %q", todo.Status)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,399 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"test-server/models"
+	"test-server/tenant"
+)
+
+// webhookColumns leaves out the secret, which is only read for deliveries.
+const webhookColumns = `id, tenant_id, url, events, active, created_at, updated_at`
+
+const deliveryColumns = `d.id, d.tenant_id, d.webhook_id, w.url, w.secret, d.event_type, d.payload, d.status, d.attempts,
+	d.next_attempt_at, d.last_error, d.response_status, d.created_at, d.delivered_at`
+
+// WithDeliveryHook registers fn to run whenever a delivery is queued for an
+// immediate attempt, so a dispatcher can wake without waiting for its next
+// poll.
+func (r *TodoRepository) WithDeliveryHook(fn func()) *TodoRepository {
+	r.deliveryHook = fn
+	return r
+}
+
+func (r *TodoRepository) deliveryQueued() {
+	if r.deliveryHook != nil {
+		r.deliveryHook()
+	}
+}
+
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	var webhook models.Webhook
+	var events []byte
+	err := row.Scan(&webhook.ID, &webhook.TenantID, &webhook.URL, &events, &webhook.Active,
+		&webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(events, &webhook.Events); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook events: %w", err)
+	}
+	return &webhook, nil
+}
+
+func getWebhook(ctx context.Context, q querier, tenantID string, id int, forUpdate bool) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = ? AND tenant_id = ?`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	webhook, err := scanWebhook(q.QueryRowContext(ctx, query, id, tenantID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("webhook not found")
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// CreateWebhook stores a subscription. The returned webhook carries its
+// secret; later reads leave it out.
+func (r *TodoRepository) CreateWebhook(ctx context.Context, req *models.CreateWebhookRequest) (*models.Webhook, error) {
+	tenantID := tenant.IDFromContext(ctx)
+
+	events, err := json.Marshal(req.Events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode webhook events: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx, `INSERT INTO webhooks (tenant_id, url, events, secret) VALUES (?, ?, ?, ?)`,
+		tenantID, req.URL, events, req.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	webhook, err := getWebhook(ctx, r.db, tenantID, int(id), false)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = req.Secret
+	return webhook, nil
+}
+
+func (r *TodoRepository) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE tenant_id = ? ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, tenant.IDFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+func (r *TodoRepository) GetWebhook(ctx context.Context, id int) (*models.Webhook, error) {
+	return getWebhook(ctx, r.db, tenant.IDFromContext(ctx), id, false)
+}
+
+func (r *TodoRepository) UpdateWebhook(ctx context.Context, id int, req *models.UpdateWebhookRequest) (*models.Webhook, error) {
+	tenantID := tenant.IDFromContext(ctx)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := getWebhook(ctx, tx, tenantID, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := []string{}
+	args := []interface{}{}
+	if req.URL != nil && *req.URL != current.URL {
+		sets = append(sets, "url = ?")
+		args = append(args, *req.URL)
+	}
+	if req.Events != nil {
+		events, err := json.Marshal(*req.Events)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode webhook events: %w", err)
+		}
+		sets = append(sets, "events = ?")
+		args = append(args, events)
+	}
+	if req.Secret != nil {
+		sets = append(sets, "secret = ?")
+		args = append(args, *req.Secret)
+	}
+	if req.Active != nil {
+		sets = append(sets, "active = ?")
+		args = append(args, *req.Active)
+	}
+
+	if len(sets) > 0 {
+		args = append(args, id, tenantID)
+		query := `UPDATE webhooks SET ` + strings.Join(sets, ", ") + ` WHERE id = ? AND tenant_id = ?`
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return nil, fmt.Errorf("failed to update webhook: %w", err)
+		}
+	}
+
+	updated, err := getWebhook(ctx, tx, tenantID, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit webhook update: %w", err)
+	}
+
+	return updated, nil
+}
+
+// DeleteWebhook removes a subscription along with its deliveries.
+func (r *TodoRepository) DeleteWebhook(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ? AND tenant_id = ?`, id, tenant.IDFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+
+	return nil
+}
+
+func scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var payload []byte
+	var lastError sql.NullString
+	var responseStatus sql.NullInt64
+	var deliveredAt sql.NullTime
+	err := row.Scan(&delivery.ID, &delivery.TenantID, &delivery.WebhookID, &delivery.URL, &delivery.Secret,
+		&delivery.EventType, &payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt,
+		&lastError, &responseStatus, &delivery.CreatedAt, &deliveredAt)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	delivery.LastError = lastError.String
+	delivery.ResponseStatus = nullIntPtr(responseStatus)
+	delivery.DeliveredAt = nullTimePtr(deliveredAt)
+	return &delivery, nil
+}
+
+func queryDeliveries(ctx context.Context, q querier, query string, args ...interface{}) ([]models.WebhookDelivery, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// GetDeliveries returns the tenant's deliveries matching filter, newest
+// first. Filtering by status dead gives the dead-letter queue.
+func (r *TodoRepository) GetDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]models.WebhookDelivery, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	where := []string{"d.tenant_id = ?"}
+	args := []interface{}{tenantID}
+
+	if filter.WebhookID != nil {
+		if _, err := getWebhook(ctx, r.db, tenantID, *filter.WebhookID, false); err != nil {
+			return nil, err
+		}
+		where = append(where, "d.webhook_id = ?")
+		args = append(args, *filter.WebhookID)
+	}
+	if filter.Status != "" {
+		where = append(where, "d.status = ?")
+		args = append(args, filter.Status)
+	}
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE ` + strings.Join(where, " AND ") + ` ORDER BY d.id DESC LIMIT ?`
+	args = append(args, filter.Limit)
+	return queryDeliveries(ctx, r.db, query, args...)
+}
+
+// Redeliver queues a delivery for an immediate attempt with a fresh set of
+// retries, whatever its current status.
+func (r *TodoRepository) Redeliver(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	tenantID := tenant.IDFromContext(ctx)
+
+	result, err := r.db.ExecContext(ctx, `UPDATE webhook_deliveries
+		SET status = ?, attempts = 0, next_attempt_at = ?, last_error = NULL, response_status = NULL, delivered_at = NULL
+		WHERE id = ? AND tenant_id = ?`,
+		models.DeliveryPending, time.Now().UTC(), id, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeliver: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("delivery not found")
+	}
+
+	r.deliveryQueued()
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.id = ? AND d.tenant_id = ?`
+	deliveries, err := queryDeliveries(ctx, r.db, query, id, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, fmt.Errorf("delivery not found")
+	}
+	return &deliveries[0], nil
+}
+
+// The delivery queue queries below run on behalf of the dispatcher and
+// therefore span all tenants.
+
+// EnqueueDeliveries queues event for every active webhook of its tenant
+// that subscribes to it, and returns how many deliveries were queued. An
+// event already queued for a webhook is skipped, so republishing an event
+// does not deliver it twice.
+func (r *TodoRepository) EnqueueDeliveries(ctx context.Context, event models.TodoEvent) (int64, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx, `INSERT IGNORE INTO webhook_deliveries
+			(tenant_id, webhook_id, event_id, event_type, payload, next_attempt_at)
+		SELECT tenant_id, id, ?, ?, ?, ? FROM webhooks
+		WHERE tenant_id = ? AND active AND (JSON_CONTAINS(events, JSON_QUOTE(?)) OR JSON_CONTAINS(events, JSON_QUOTE(?)))`,
+		event.ID, event.Type, payload, time.Now().UTC(), event.TenantID, event.Type, models.WebhookAllEvents)
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue deliveries: %w", err)
+	}
+
+	queued, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return queued, nil
+}
+
+func (r *TodoRepository) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = ? AND d.next_attempt_at <= ?
+		ORDER BY d.next_attempt_at, d.id
+		LIMIT ?`
+	return queryDeliveries(ctx, r.db, query, models.DeliveryPending, now, limit)
+}
+
+// ClaimDelivery pushes a due delivery's next attempt out to until and
+// reports whether this caller won it. Several dispatcher instances never
+// attempt the same delivery at once, and a dispatcher that dies mid-attempt
+// leaves the delivery to be retried after until.
+func (r *TodoRepository) ClaimDelivery(ctx context.Context, id int64, now, until time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id = ? AND status = ? AND next_attempt_at <= ?`,
+		until, id, models.DeliveryPending, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim delivery: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *TodoRepository) MarkDelivered(ctx context.Context, id int64, responseStatus int, now time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, response_status = ?, last_error = NULL, delivered_at = ?
+		WHERE id = ?`,
+		models.DeliveryDelivered, responseStatus, now, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark delivery delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed attempt. The delivery is retried at next, or
+// is dead when next is nil. responseStatus is 0 when no response arrived.
+func (r *TodoRepository) MarkFailed(ctx context.Context, id int64, responseStatus int, message string, next *time.Time) error {
+	status := models.DeliveryPending
+	if next == nil {
+		status = models.DeliveryDead
+	}
+	var statusArg interface{}
+	if responseStatus != 0 {
+		statusArg = responseStatus
+	}
+
+	_, err := r.db.ExecContext(ctx, `UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, response_status = ?, last_error = ?,
+			next_attempt_at = COALESCE(?, next_attempt_at)
+		WHERE id = ?`,
+		status, statusArg, message, timeArg(next), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark delivery failed: %w", err)
+	}
+	return nil
+}
+
+func (r *TodoRepository) NextDeliveryAt(ctx context.Context) (*time.Time, error) {
+	var next sql.NullTime
+	err := r.db.QueryRowContext(ctx, `SELECT MIN(next_attempt_at) FROM webhook_deliveries WHERE status = ?`,
+		models.DeliveryPending).Scan(&next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next delivery: %w", err)
+	}
+	return nullTimePtr(next), nil
+}
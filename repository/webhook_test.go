@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"test-server/models"
+	"test-server/tenant"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var webhookRowColumns = []string{"id", "tenant_id", "url", "events", "active", "created_at", "updated_at"}
+
+func TestCreateWebhookReturnsSecretOnce(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+	ctx := tenant.WithID(context.Background(), "acme")
+	now := time.Now()
+
+	mock.ExpectExec("INSERT INTO webhooks").
+		WithArgs("acme", "https://ci.example.com/hook", []byte(`["todo.created"]`), "0123456789abcdef").
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectQuery("SELECT (.+) FROM webhooks WHERE id = (.+) AND tenant_id = ?").
+		WithArgs(4, "acme").
+		WillReturnRows(sqlmock.NewRows(webhookRowColumns).
+			AddRow(4, "acme", "https://ci.example.com/hook", `["todo.created"]`, true, now, now))
+
+	webhook, err := repo.CreateWebhook(ctx, &models.CreateWebhookRequest{
+		URL: "https://ci.example.com/hook", Events: []string{models.EventTodoCreated}, Secret: "0123456789abcdef",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if webhook.Secret != "0123456789abcdef" || !webhook.Subscribes(models.EventTodoCreated) || webhook.Subscribes(models.EventTodoDeleted) {
+		t.Errorf("Unexpected webhook %+v", webhook)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestEnqueueDeliveriesMatchesSubscriptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+
+	mock.ExpectExec("INSERT IGNORE INTO webhook_deliveries (.+) SELECT (.+) FROM webhooks WHERE tenant_id = (.+) AND active").
+		WithArgs("e1", models.EventTodoUpdated, sqlmock.AnyArg(), sqlmock.AnyArg(), "acme", models.EventTodoUpdated, "*").
+		WillReturnResult(sqlmock.NewResult(10, 2))
+
+	queued, err := repo.EnqueueDeliveries(context.Background(), models.TodoEvent{
+		ID: "e1", Type: models.EventTodoUpdated, TenantID: "acme", TodoID: 3,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if queued != 2 {
+		t.Errorf("Expected 2 deliveries, got %d", queued)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRedeliverRequeuesAndWakes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	woken := false
+	repo := NewTodoRepository(db).WithDeliveryHook(func() { woken = true })
+	ctx := tenant.WithID(context.Background(), "acme")
+	now := time.Now()
+
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = (.+), attempts = 0").
+		WithArgs(models.DeliveryPending, sqlmock.AnyArg(), 7, "acme").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM webhook_deliveries d JOIN webhooks w (.+) WHERE d.id = (.+) AND d.tenant_id = ?").
+		WithArgs(7, "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "webhook_id", "url", "secret", "event_type", "payload",
+			"status", "attempts", "next_attempt_at", "last_error", "response_status", "created_at", "delivered_at"}).
+			AddRow(7, "acme", 4, "https://ci.example.com/hook", "0123456789abcdef", models.EventTodoCreated, `{}`,
+				models.DeliveryPending, 0, now, nil, nil, now, nil))
+
+	delivery, err := repo.Redeliver(ctx, 7)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if delivery.Status != models.DeliveryPending || delivery.Attempts != 0 || !woken {
+		t.Errorf("Expected a requeued delivery and a wake-up, got %+v (woken %v)", delivery, woken)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRedeliverUnknownDelivery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+
+	mock.ExpectExec("UPDATE webhook_deliveries SET status").
+		WithArgs(models.DeliveryPending, sqlmock.AnyArg(), 9, "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, err = repo.Redeliver(context.Background(), 9)
+	if err == nil || err.Error() != "delivery not found" {
+		t.Errorf("Expected delivery not found, got %v", err)
+	}
+}
+
+func TestMarkFailedWithoutRetryIsDead(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTodoRepository(db)
+
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = (.+), attempts = attempts \\+ 1").
+		WithArgs(models.DeliveryDead, nil, "connection refused", nil, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.MarkFailed(context.Background(), 7, 0, "connection refused", nil); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
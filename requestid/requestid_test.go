@@ -0,0 +1,30 @@
+package requestid
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	if id := FromContext(context.Background()); id != "" {
+		t.Errorf("Expected no ID, got %s", id)
+	}
+
+	ctx := WithID(context.Background(), "abc")
+	if id := FromContext(ctx); id != "abc" {
+		t.Errorf("Expected abc, got %s", id)
+	}
+}
+
+func TestNewIsValid(t *testing.T) {
+	a, b := New(), New()
+	if !Valid(a) || len(a) != 32 {
+		t.Errorf("Unexpected ID %q", a)
+	}
+	if a == b {
+		t.Error("Expected distinct IDs")
+	}
+	if Valid("bad id\n") || Valid("") {
+		t.Error("Expected IDs with whitespace or no characters to be invalid")
+	}
+}
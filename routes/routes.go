@@ -1,22 +1,169 @@
 package routes
 
 import (
+	"maps"
+	"net/http"
+
+	"test-server/cache"
 	"test-server/handlers"
+	"test-server/middleware"
+	"test-server/outbox"
+	"test-server/realtime"
 	"test-server/repository"
+	"test-server/websocket"
 
 	"github.com/gorilla/mux"
 )
 
-func SetupRouter(repo *repository.TodoRepository) *mux.Router {
+type Config struct {
+	// JWTSecret enables verification of HS256 bearer tokens.
+	JWTSecret   []byte
+	Tenant      middleware.TenantConfig
+	RateLimit   middleware.RateLimitConfig
+	CORS        middleware.CORSConfig
+	Security    middleware.SecurityConfig
+	Compression middleware.CompressionConfig
+	// Idempotency replays responses to POSTs retried with the same
+	// Idempotency-Key.
+	Idempotency middleware.IdempotencyConfig
+	// Events feeds live todo events to streaming clients. Without it,
+	// streams only replay the event log.
+	Events *outbox.Bus
+	// Cache, when set, serves todo reads and takes every write that
+	// touches todos, so that it can invalidate.
+	Cache *cache.TodoRepository
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Tenant:   middleware.TenantConfig{Header: "X-Tenant-ID", Claim: "tenant_id"},
+		CORS:     middleware.DefaultCORSConfig(),
+		Security: middleware.DefaultSecurityConfig(),
+	}
+}
+
+// idempotencyConfig lets imports sent with an Idempotency-Key be as large
+// as the import handler allows, unless cfg says otherwise.
+func idempotencyConfig(cfg middleware.IdempotencyConfig) middleware.IdempotencyConfig {
+	const importRoute = "POST /api/todos/import"
+	if _, ok := cfg.MaxBodies[importRoute]; !ok {
+		cfg.MaxBodies = maps.Clone(cfg.MaxBodies)
+		if cfg.MaxBodies == nil {
+			cfg.MaxBodies = map[string]int64{}
+		}
+		cfg.MaxBodies[importRoute] = handlers.DefaultImportMaxBytes
+	}
+	return cfg
+}
+
+func SetupRouter(repo *repository.TodoRepository, cfg Config) *mux.Router {
 	router := mux.NewRouter()
-	todoHandler := handlers.NewTodoHandler(repo)
+	router.Use(middleware.RequestID)
+	router.Use(middleware.SecurityHeaders(cfg.Security))
+	router.Use(middleware.Compress(cfg.Compression))
+	router.Use(middleware.CORS(cfg.CORS))
+	router.Use(middleware.Authenticate(cfg.JWTSecret))
+	router.Use(middleware.RateLimiter(cfg.RateLimit))
+	router.Use(middleware.Tenant(cfg.Tenant))
+	router.Use(middleware.Idempotency(idempotencyConfig(cfg.Idempotency)))
+
+	// Every route that writes todos goes through the cache, when there is
+	// one, so that its tenant is invalidated before the response is sent.
+	var todos handlers.TodoRepository = repo
+	var comments handlers.CommentRepository = repo
+	var lists handlers.ListRepository = repo
+	var revisions handlers.RevisionRepository = repo
+	var imports handlers.ImportRepository = repo
+	if cfg.Cache != nil {
+		todos = cfg.Cache
+		comments = cfg.Cache.Comments(repo)
+		lists = cfg.Cache.Lists(repo)
+		revisions = cfg.Cache.Revisions(repo)
+		imports = cfg.Cache.Imports(repo)
+	}
+	todoHandler := handlers.NewTodoHandler(todos)
+	tagHandler := handlers.NewTagHandler(repo)
+	listHandler := handlers.NewListHandler(lists, todos)
+	commentHandler := handlers.NewCommentHandler(comments)
+	auditHandler := handlers.NewAuditHandler(repo)
+	revisionHandler := handlers.NewRevisionHandler(revisions)
+	webhookHandler := handlers.NewWebhookHandler(repo)
+	if cfg.Events == nil {
+		cfg.Events = outbox.NewBus()
+	}
+	eventHandler := handlers.NewEventStreamHandler(repo, cfg.Events)
+	exportHandler := handlers.NewExportHandler(repo)
+	importHandler := handlers.NewImportHandler(imports)
+	realtimeHandler := handlers.NewRealtimeHandler(todos, realtime.NewHub(cfg.Events))
+	realtimeHandler.Upgrader.CheckOrigin = func(r *http.Request) bool {
+		return websocket.SameOrigin(r) || cfg.CORS.AllowOrigin(r.Header.Get("Origin"))
+	}
 
 	// Todo routes
 	router.HandleFunc("/api/todos", todoHandler.CreateTodo).Methods("POST")
 	router.HandleFunc("/api/todos", todoHandler.GetAllTodos).Methods("GET")
+	router.HandleFunc("/api/todos/search", todoHandler.SearchTodos).Methods("GET")
+	router.HandleFunc("/api/todos/events", eventHandler.StreamEvents).Methods("GET")
+	router.HandleFunc("/api/todos/export", exportHandler.ExportTodos).Methods("GET")
+	router.HandleFunc("/api/todos/export.csv", exportHandler.ExportCSV).Methods("GET")
+	router.HandleFunc("/api/todos/import", importHandler.ImportTodos).Methods("POST")
 	router.HandleFunc("/api/todos/{id}", todoHandler.GetTodo).Methods("GET")
+	router.HandleFunc("/api/todos/{id}/children", todoHandler.GetTodoChildren).Methods("GET")
+	router.HandleFunc("/api/todos/{id}/tree", todoHandler.GetTodoTree).Methods("GET")
 	router.HandleFunc("/api/todos/{id}", todoHandler.UpdateTodo).Methods("PUT")
 	router.HandleFunc("/api/todos/{id}", todoHandler.DeleteTodo).Methods("DELETE")
+	router.HandleFunc("/api/todos/{id}/move", todoHandler.MoveTodo).Methods("POST")
+	router.HandleFunc("/api/todos/{id}/history", auditHandler.GetTodoHistory).Methods("GET")
+	router.HandleFunc("/api/todos/{id}/revisions", revisionHandler.GetRevisions).Methods("GET")
+	router.HandleFunc("/api/todos/{id}/revisions/diff", revisionHandler.DiffRevisions).Methods("GET")
+	router.HandleFunc("/api/todos/{id}/revisions/{n:[0-9]+}", revisionHandler.GetRevision).Methods("GET")
+	router.HandleFunc("/api/todos/{id}/revert", revisionHandler.Revert).Methods("POST")
+
+	// Comment routes; ?render=html adds body_html to each comment.
+	router.HandleFunc("/api/todos/{id}/comments", commentHandler.CreateComment).Methods("POST")
+	router.HandleFunc("/api/todos/{id}/comments", commentHandler.GetComments).Methods("GET")
+	router.HandleFunc("/api/todos/{id}/comments/{commentID}", commentHandler.GetComment).Methods("GET")
+	router.HandleFunc("/api/todos/{id}/comments/{commentID}", commentHandler.UpdateComment).Methods("PUT")
+	router.HandleFunc("/api/todos/{id}/comments/{commentID}", commentHandler.DeleteComment).Methods("DELETE")
+
+	// List routes; {id} also accepts "inbox".
+	router.HandleFunc("/api/lists", listHandler.CreateList).Methods("POST")
+	router.HandleFunc("/api/lists", listHandler.GetAllLists).Methods("GET")
+	router.HandleFunc("/api/lists/{id}", listHandler.GetList).Methods("GET")
+	router.HandleFunc("/api/lists/{id}", listHandler.UpdateList).Methods("PUT")
+	router.HandleFunc("/api/lists/{id}", listHandler.DeleteList).Methods("DELETE")
+	router.HandleFunc("/api/lists/{id}/todos", listHandler.GetListTodos).Methods("GET")
+	router.HandleFunc("/api/lists/{id}/todos", listHandler.CreateListTodo).Methods("POST")
+
+	// Tag routes
+	router.HandleFunc("/api/tags", tagHandler.GetAllTags).Methods("GET")
+
+	// Audit routes
+	router.Handle("/api/audit", middleware.RequireRole("admin")(http.HandlerFunc(auditHandler.GetAuditLog))).Methods("GET")
+
+	if cfg.Cache != nil {
+		router.Handle("/api/cache/stats", middleware.RequireRole("admin")(http.HandlerFunc(cfg.Cache.ServeStats))).Methods("GET")
+	}
+
+	// Webhook routes, all admin-only
+	webhooks := router.PathPrefix("/api/webhooks").Subrouter()
+	webhooks.Use(middleware.RequireRole("admin"))
+	webhooks.HandleFunc("", webhookHandler.CreateWebhook).Methods("POST")
+	webhooks.HandleFunc("", webhookHandler.GetWebhooks).Methods("GET")
+	webhooks.HandleFunc("/dead-letters", webhookHandler.GetDeadLetters).Methods("GET")
+	webhooks.HandleFunc("/deliveries/{deliveryID:[0-9]+}/redeliver", webhookHandler.Redeliver).Methods("POST")
+	webhooks.HandleFunc("/{id:[0-9]+}", webhookHandler.GetWebhook).Methods("GET")
+	webhooks.HandleFunc("/{id:[0-9]+}", webhookHandler.UpdateWebhook).Methods("PUT")
+	webhooks.HandleFunc("/{id:[0-9]+}", webhookHandler.DeleteWebhook).Methods("DELETE")
+	webhooks.HandleFunc("/{id:[0-9]+}/deliveries", webhookHandler.GetDeliveries).Methods("GET")
+
+	// Realtime channel: subscribe to lists and todos, receive their events
+	// and send updates.
+	router.HandleFunc("/ws", realtimeHandler.Connect).Methods("GET")
+
+	// Routes only match their declared methods, so OPTIONS needs its own
+	// route for CORS preflights to reach the middleware.
+	router.PathPrefix("/").Methods(http.MethodOptions).HandlerFunc(middleware.PreflightHandler)
 
 	return router
 }
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Addr string
+
+	// CertFile and KeyFile enable TLS. The pair is reloaded on SIGHUP and
+	// whenever either file changes on disk.
+	CertFile       string
+	KeyFile        string
+	ReloadInterval time.Duration
+
+	// MinVersion is "1.0", "1.1", "1.2" or "1.3"; defaults to "1.2".
+	MinVersion string
+	// CipherSuites restricts TLS 1.0-1.2 suites by their standard names,
+	// e.g. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". TLS 1.3 suites are not
+	// configurable.
+	CipherSuites []string
+
+	// ClientCAFile enables mutual TLS using the given PEM bundle.
+	// ClientAuth is "require" (default when a CA is set) or "optional".
+	ClientCAFile string
+	ClientAuth   string
+
+	// H2C accepts unencrypted HTTP/2 when TLS is disabled, for deployments
+	// behind a TLS-terminating proxy.
+	H2C bool
+}
+
+type Server struct {
+	*http.Server
+	reloader       *CertReloader
+	reloadInterval time.Duration
+}
+
+func New(cfg Config, handler http.Handler) (*Server, error) {
+	srv := &Server{
+		Server:         &http.Server{Addr: cfg.Addr, Handler: handler},
+		reloadInterval: cfg.ReloadInterval,
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		protocols.SetUnencryptedHTTP2(cfg.H2C)
+		srv.Protocols = protocols
+		return srv, nil
+	}
+
+	protocols.SetHTTP2(true)
+	srv.Protocols = protocols
+
+	tlsConfig, err := buildTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	srv.reloader, err = NewCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.GetCertificate = srv.reloader.GetCertificate
+	srv.TLSConfig = tlsConfig
+
+	return srv, nil
+}
+
+func (s *Server) TLSEnabled() bool {
+	return s.reloader != nil
+}
+
+// ListenAndServe serves TLS when certificates are configured and plain HTTP
+// (optionally with h2c) otherwise.
+func (s *Server) ListenAndServe() error {
+	if s.reloader == nil {
+		return s.Server.ListenAndServe()
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.reloader.Watch(s.reloadInterval, stop)
+
+	return s.Server.ListenAndServeTLS("", "")
+}
+
+func buildTLSConfig(cfg Config) (*tls.Config, error) {
+	minVersion, err := parseTLSVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{MinVersion: minVersion}
+
+	if len(cfg.CipherSuites) > 0 {
+		tlsConfig.CipherSuites, err = parseCipherSuites(cfg.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA bundle %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+
+		switch cfg.ClientAuth {
+		case "", "require":
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		case "optional":
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("invalid client auth mode %q", cfg.ClientAuth)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+func parseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	}
+	return 0, fmt.Errorf("invalid TLS version %q", version)
+}
+
+func parseCipherSuites(names []string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		id, ok := known[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
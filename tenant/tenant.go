@@ -0,0 +1,73 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultID is the tenant used when a request does not identify one and the
+// deployment does not require it.
+const DefaultID = "default"
+
+type contextKey struct{}
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// IDFromContext returns the tenant carried by ctx, falling back to DefaultID.
+func IDFromContext(ctx context.Context) string {
+	if id, ok := FromContext(ctx); ok {
+		return id
+	}
+	return DefaultID
+}
+
+func ValidID(id string) bool {
+	return validID.MatchString(id)
+}
+
+// Quotas caps the number of todos a tenant may own. A limit of zero means
+// unlimited.
+type Quotas struct {
+	Default   int
+	PerTenant map[string]int
+}
+
+func (q Quotas) Limit(id string) int {
+	if limit, ok := q.PerTenant[id]; ok {
+		return limit
+	}
+	return q.Default
+}
+
+// ParseQuotas parses overrides in the form "acme=100,globex=50".
+func ParseQuotas(defaultLimit int, overrides string) (Quotas, error) {
+	q := Quotas{Default: defaultLimit, PerTenant: map[string]int{}}
+	for _, pair := range strings.Split(overrides, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, value, ok := strings.Cut(pair, "=")
+		if !ok || !ValidID(id) {
+			return q, fmt.Errorf("invalid tenant quota %q", pair)
+		}
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return q, fmt.Errorf("invalid tenant quota %q", pair)
+		}
+		q.PerTenant[id] = limit
+	}
+	return q, nil
+}
@@ -0,0 +1,38 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIDFromContext(t *testing.T) {
+	if id := IDFromContext(context.Background()); id != DefaultID {
+		t.Errorf("Expected %s, got %s", DefaultID, id)
+	}
+
+	ctx := WithID(context.Background(), "acme")
+	if id := IDFromContext(ctx); id != "acme" {
+		t.Errorf("Expected acme, got %s", id)
+	}
+}
+
+func TestParseQuotas(t *testing.T) {
+	q, err := ParseQuotas(10, "acme=100, globex=0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if q.Limit("acme") != 100 {
+		t.Errorf("Expected 100, got %d", q.Limit("acme"))
+	}
+	if q.Limit("globex") != 0 {
+		t.Errorf("Expected 0, got %d", q.Limit("globex"))
+	}
+	if q.Limit("initech") != 10 {
+		t.Errorf("Expected 10, got %d", q.Limit("initech"))
+	}
+
+	if _, err := ParseQuotas(0, "acme"); err == nil {
+		t.Error("Expected error for malformed override")
+	}
+}
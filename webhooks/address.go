@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// CheckAddress refuses addresses a webhook must not reach: loopback,
+// private, link-local (including cloud metadata services such as
+// 169.254.169.254), multicast and unspecified ones.
+func CheckAddress(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || addr.IsUnspecified() {
+		return fmt.Errorf("address %s is not public", addr)
+	}
+	// Carrier-grade NAT is shared address space, not the internet.
+	if netip.MustParsePrefix("100.64.0.0/10").Contains(addr) {
+		return fmt.Errorf("address %s is not public", addr)
+	}
+	return nil
+}
+
+// CheckHost refuses hosts that name a non-public address outright: IP
+// literals CheckAddress refuses and localhost. Other names are checked as
+// they are dialed.
+func CheckHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("host %s is not public", host)
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		return CheckAddress(addr)
+	}
+	return nil
+}
+
+// NewClient returns the client the dispatcher uses by default. It checks
+// every address it connects to with CheckAddress after resolution, so a
+// name that resolves to a private address, or a redirect to one, fails
+// rather than reaching it.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			return CheckAddress(addrPort.Addr())
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be dialed instead of the webhook's own address.
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
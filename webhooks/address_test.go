@@ -0,0 +1,65 @@
+package webhooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestCheckAddress(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.215.14":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"0.0.0.0":         false,
+		"100.64.0.1":      false,
+		"::ffff:10.0.0.1": false,
+	}
+	for raw, public := range tests {
+		if err := CheckAddress(netip.MustParseAddr(raw)); (err == nil) != public {
+			t.Errorf("%s: expected public=%v, got %v", raw, public, err)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	for host, public := range map[string]bool{
+		"hooks.example.com": true,
+		"localhost":         false,
+		"LOCALHOST.":        false,
+		"api.localhost":     false,
+		"[::1]":             false,
+		"169.254.169.254":   false,
+	} {
+		if err := CheckHost(host); (err == nil) != public {
+			t.Errorf("%s: expected public=%v, got %v", host, public, err)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	var calls int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer receiver.Close()
+
+	// Also covers redirects, which are dialed by the same transport.
+	resp, err := NewClient(time.Second).Post(receiver.URL, "application/json", nil)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected the loopback receiver to be refused")
+	}
+	if calls != 0 {
+		t.Errorf("Expected no request to reach the receiver, got %d", calls)
+	}
+}
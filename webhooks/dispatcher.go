@@ -0,0 +1,238 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"test-server/models"
+)
+
+// Headers sent with every delivery. The signature is the hex HMAC-SHA256 of
+// the timestamp, a dot and the body, keyed with the webhook's secret and
+// prefixed with "sha256=".
+const (
+	DeliveryHeader  = "X-Webhook-Delivery"
+	EventHeader     = "X-Webhook-Event"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// maxErrorLength bounds the error message stored with a failed attempt.
+const maxErrorLength = 1000
+
+type Store interface {
+	EnqueueDeliveries(ctx context.Context, event models.TodoEvent) (int64, error)
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error)
+	ClaimDelivery(ctx context.Context, id int64, now, until time.Time) (bool, error)
+	MarkDelivered(ctx context.Context, id int64, responseStatus int, now time.Time) error
+	MarkFailed(ctx context.Context, id int64, responseStatus int, message string, next *time.Time) error
+	NextDeliveryAt(ctx context.Context) (*time.Time, error)
+}
+
+// Sign returns the signature header value for a delivery body sent at
+// timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher delivers queued webhook events. The queue lives in the
+// database, so deliveries survive restarts and several dispatchers can share
+// it. A delivery succeeds on any 2xx response; other responses and transport
+// errors are retried with exponential backoff until MaxAttempts, after which
+// the delivery is dead.
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	// MaxAttempts counts the first attempt.
+	MaxAttempts int
+	// BaseBackoff is the wait after the first failure; it doubles with each
+	// further failure up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// MaxSleep bounds how long the dispatcher sleeps without re-reading the
+	// queue, covering deliveries queued by other instances.
+	MaxSleep  time.Duration
+	BatchSize int
+
+	wake chan struct{}
+	now  func() time.Time
+}
+
+// NewDispatcher delivers with client, or by default with NewClient, which
+// refuses to connect to non-public addresses.
+func NewDispatcher(store Store, client *http.Client) *Dispatcher {
+	if client == nil {
+		client = NewClient(10 * time.Second)
+	}
+	return &Dispatcher{
+		store:       store,
+		client:      client,
+		MaxAttempts: 8,
+		BaseBackoff: 30 * time.Second,
+		MaxBackoff:  6 * time.Hour,
+		MaxSleep:    time.Minute,
+		BatchSize:   50,
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+	}
+}
+
+// Publish queues event for the webhooks subscribed to it, making the
+// dispatcher an outbox publisher. Publishing an event again queues nothing
+// new.
+func (d *Dispatcher) Publish(ctx context.Context, event models.TodoEvent) error {
+	queued, err := d.store.EnqueueDeliveries(ctx, event)
+	if err != nil {
+		return err
+	}
+	if queued > 0 {
+		d.Wake()
+	}
+	return nil
+}
+
+// Wake makes the dispatcher check the queue immediately. It never blocks.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		if err := d.deliverDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to deliver webhooks: %v", err)
+		}
+
+		timer := time.NewTimer(d.sleepDuration(ctx))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-d.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	for {
+		now := d.now().UTC()
+		deliveries, err := d.store.DueDeliveries(ctx, now, d.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			claimed, err := d.store.ClaimDelivery(ctx, delivery.ID, now, now.Add(d.lease()))
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+			if err := d.attempt(ctx, delivery); err != nil {
+				return err
+			}
+		}
+
+		if len(deliveries) < d.BatchSize {
+			return nil
+		}
+	}
+}
+
+// lease is how long a claimed delivery is left alone before another
+// dispatcher may assume the attempt was lost.
+func (d *Dispatcher) lease() time.Duration {
+	if d.client.Timeout > 0 {
+		return 2 * d.client.Timeout
+	}
+	return 5 * time.Minute
+}
+
+// attempt sends one delivery and records the outcome. Only failures to
+// record it are returned.
+func (d *Dispatcher) attempt(ctx context.Context, delivery models.WebhookDelivery) error {
+	status, err := d.send(ctx, delivery)
+	if err == nil {
+		return d.store.MarkDelivered(ctx, delivery.ID, status, d.now().UTC())
+	}
+
+	message := err.Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+
+	var next *time.Time
+	if attempts := delivery.Attempts + 1; attempts < d.MaxAttempts {
+		at := d.now().UTC().Add(d.backoff(attempts))
+		next = &at
+	} else {
+		log.Printf("Webhook delivery %d to %s is dead after %d attempts: %s", delivery.ID, delivery.URL, attempts, message)
+	}
+	return d.store.MarkFailed(ctx, delivery.ID, status, message, next)
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.MaxBackoff)
+}
+
+// send posts a delivery and returns the response status, or 0 if there was
+// no response.
+func (d *Dispatcher) send(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) sleepDuration(ctx context.Context) time.Duration {
+	next, err := d.store.NextDeliveryAt(ctx)
+	if err != nil || next == nil {
+		return d.MaxSleep
+	}
+	wait := next.Sub(d.now())
+	if wait < 0 {
+		return 0
+	}
+	return min(wait, d.MaxSleep)
+}
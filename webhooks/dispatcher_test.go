@@ -0,0 +1,224 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"test-server/models"
+)
+
+type memoryStore struct {
+	mu         sync.Mutex
+	webhooks   []models.Webhook
+	deliveries []models.WebhookDelivery
+}
+
+func (s *memoryStore) EnqueueDeliveries(ctx context.Context, event models.TodoEvent) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	var queued int64
+	for _, webhook := range s.webhooks {
+		if webhook.TenantID == event.TenantID && webhook.Active && webhook.Subscribes(event.Type) && !s.queued(webhook.ID, event.ID) {
+			s.deliveries = append(s.deliveries, models.WebhookDelivery{
+				ID: int64(len(s.deliveries) + 1), TenantID: webhook.TenantID, WebhookID: webhook.ID,
+				URL: webhook.URL, Secret: webhook.Secret, EventType: event.Type, Payload: payload,
+				Status: models.DeliveryPending,
+			})
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// queued must be called with s.mu held.
+func (s *memoryStore) queued(webhookID int, eventID string) bool {
+	for _, delivery := range s.deliveries {
+		var event models.TodoEvent
+		json.Unmarshal(delivery.Payload, &event)
+		if delivery.WebhookID == webhookID && event.ID == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []models.WebhookDelivery
+	for _, delivery := range s.deliveries {
+		if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, delivery)
+		}
+	}
+	return due, nil
+}
+
+func (s *memoryStore) ClaimDelivery(ctx context.Context, id int64, now, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delivery := &s.deliveries[id-1]
+	if delivery.Status != models.DeliveryPending || delivery.NextAttemptAt.After(now) {
+		return false, nil
+	}
+	delivery.NextAttemptAt = until
+	return true, nil
+}
+
+func (s *memoryStore) MarkDelivered(ctx context.Context, id int64, responseStatus int, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delivery := &s.deliveries[id-1]
+	delivery.Status = models.DeliveryDelivered
+	delivery.Attempts++
+	delivery.ResponseStatus = &responseStatus
+	delivery.DeliveredAt = &now
+	return nil
+}
+
+func (s *memoryStore) MarkFailed(ctx context.Context, id int64, responseStatus int, message string, next *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delivery := &s.deliveries[id-1]
+	delivery.Attempts++
+	delivery.LastError = message
+	if next == nil {
+		delivery.Status = models.DeliveryDead
+	} else {
+		delivery.NextAttemptAt = *next
+	}
+	return nil
+}
+
+func (s *memoryStore) NextDeliveryAt(ctx context.Context) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next *time.Time
+	for _, delivery := range s.deliveries {
+		if delivery.Status == models.DeliveryPending && (next == nil || delivery.NextAttemptAt.Before(*next)) {
+			at := delivery.NextAttemptAt
+			next = &at
+		}
+	}
+	return next, nil
+}
+
+func (s *memoryStore) delivery(id int64) models.WebhookDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deliveries[id-1]
+}
+
+func TestDispatcherDeliversSignedEvents(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		if got, want := r.Header.Get(SignatureHeader), Sign("s3cret", timestamp, body); got != want {
+			t.Errorf("Expected signature %s, got %s", want, got)
+		}
+		var event models.TodoEvent
+		if err := json.Unmarshal(body, &event); err != nil || event.TodoID != 4 {
+			t.Errorf("Unexpected payload %s", body)
+		}
+		received <- r
+	}))
+	defer receiver.Close()
+
+	store := &memoryStore{webhooks: []models.Webhook{
+		{ID: 1, TenantID: "acme", URL: receiver.URL, Events: []string{models.EventTodoCreated}, Secret: "s3cret", Active: true},
+		{ID: 2, TenantID: "acme", URL: receiver.URL, Events: []string{models.EventTodoDeleted}, Secret: "other", Active: true},
+		{ID: 3, TenantID: "globex", URL: receiver.URL, Events: []string{models.WebhookAllEvents}, Secret: "other", Active: true},
+	}}
+	dispatcher := NewDispatcher(store, receiver.Client())
+
+	event := models.TodoEvent{ID: "e1", Type: models.EventTodoCreated, TenantID: "acme", TodoID: 4}
+	for range 2 {
+		if err := dispatcher.Publish(context.Background(), event); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if len(store.deliveries) != 1 {
+		t.Fatalf("Expected one delivery, got %d", len(store.deliveries))
+	}
+
+	if err := dispatcher.deliverDue(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	r := <-received
+	if r.Header.Get(EventHeader) != models.EventTodoCreated || r.Header.Get(DeliveryHeader) != "1" {
+		t.Errorf("Unexpected headers %v", r.Header)
+	}
+	if delivery := store.delivery(1); delivery.Status != models.DeliveryDelivered || delivery.Attempts != 1 {
+		t.Errorf("Expected delivered after one attempt, got %+v", delivery)
+	}
+}
+
+func TestDispatcherRetriesWithBackoffUntilDead(t *testing.T) {
+	var calls int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	store := &memoryStore{webhooks: []models.Webhook{
+		{ID: 1, TenantID: "acme", URL: receiver.URL, Events: []string{models.WebhookAllEvents}, Active: true},
+	}}
+	now := time.Now()
+	dispatcher := NewDispatcher(store, receiver.Client())
+	dispatcher.MaxAttempts = 3
+	dispatcher.now = func() time.Time { return now }
+
+	dispatcher.Publish(context.Background(), models.TodoEvent{ID: "e1", Type: models.EventTodoUpdated, TenantID: "acme", TodoID: 4})
+
+	for i, wait := range []time.Duration{30 * time.Second, time.Minute} {
+		if err := dispatcher.deliverDue(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		delivery := store.delivery(1)
+		if delivery.Status != models.DeliveryPending || delivery.Attempts != i+1 {
+			t.Fatalf("Attempt %d: unexpected delivery %+v", i+1, delivery)
+		}
+		if !delivery.NextAttemptAt.Equal(now.UTC().Add(wait)) {
+			t.Errorf("Attempt %d: expected retry after %v, got %v", i+1, wait, delivery.NextAttemptAt.Sub(now))
+		}
+
+		// Nothing is due until the backoff has passed.
+		if err := dispatcher.deliverDue(context.Background()); err != nil || calls != i+1 {
+			t.Fatalf("Attempt %d: expected no early retry, got %d calls (%v)", i+1, calls, err)
+		}
+		now = now.Add(wait)
+	}
+
+	if err := dispatcher.deliverDue(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if delivery := store.delivery(1); delivery.Status != models.DeliveryDead || delivery.Attempts != 3 {
+		t.Errorf("Expected dead after three attempts, got %+v", delivery)
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	dispatcher := NewDispatcher(&memoryStore{}, nil)
+	dispatcher.BaseBackoff = time.Second
+	dispatcher.MaxBackoff = 10 * time.Second
+
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 60: 10 * time.Second} {
+		if got := dispatcher.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer echoes messages back until the client closes.
+func echoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var upgrader Upgrader
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		conn.ReadLimit = 1024
+		for {
+			op, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(op, data)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func dial(t *testing.T, server *httptest.Server) *Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { conn.Close(CloseNormal, "") })
+	return conn
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example from RFC 6455 section 1.3.
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected accept key %s", got)
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	server := echoServer(t)
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("Expected status 426, got %d", resp.StatusCode)
+	}
+}
+
+func TestUpgradeRejectsForeignOrigin(t *testing.T) {
+	server := echoServer(t)
+
+	header := http.Header{"Origin": {"https://evil.example"}}
+	_, resp, err := Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), header)
+	if err == nil {
+		t.Fatal("Expected the handshake to fail")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %v", resp)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	conn := dial(t, echoServer(t))
+
+	long := strings.Repeat("x", 300)
+	for _, msg := range []string{"hello", long} {
+		if err := conn.WriteMessage(TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		op, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if op != TextMessage || string(data) != msg {
+			t.Errorf("Expected %q, got %d %q", msg, op, data)
+		}
+	}
+}
+
+func TestFragmentedMessageWithInterleavedPing(t *testing.T) {
+	conn := dial(t, echoServer(t))
+
+	pongs := make(chan string, 1)
+	conn.SetPongHandler(func(data []byte) { pongs <- string(data) })
+
+	writeRaw(t, conn, TextMessage, false, "hel")
+	writeRaw(t, conn, PingMessage, true, "are you there")
+	writeRaw(t, conn, continuationFrame, true, "lo")
+
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("Expected reassembled hello, got %q", data)
+	}
+	if got := <-pongs; got != "are you there" {
+		t.Errorf("Expected pong to echo ping payload, got %q", got)
+	}
+}
+
+func TestOversizedMessageCloses(t *testing.T) {
+	conn := dial(t, echoServer(t))
+
+	conn.WriteMessage(TextMessage, []byte(strings.Repeat("x", 2048)))
+	_, _, err := conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+		t.Errorf("Expected close 1009, got %v", err)
+	}
+}
+
+func TestInvalidUTF8Closes(t *testing.T) {
+	conn := dial(t, echoServer(t))
+
+	conn.WriteMessage(TextMessage, []byte{0xff, 0xfe})
+	_, _, err := conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseInvalidPayload {
+		t.Errorf("Expected close 1007, got %v", err)
+	}
+}
+
+func TestUnmaskedClientFrameCloses(t *testing.T) {
+	conn := dial(t, echoServer(t))
+
+	// Pretend to be a server so the frame goes out unmasked.
+	conn.client = false
+	conn.WriteMessage(TextMessage, []byte("hi"))
+	conn.client = true
+
+	_, _, err := conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseProtocolError {
+		t.Errorf("Expected close 1002, got %v", err)
+	}
+}
+
+func TestCloseHandshake(t *testing.T) {
+	conn := dial(t, echoServer(t))
+
+	conn.writeFrame(CloseMessage, []byte{0x03, 0xe8})
+	_, _, err := conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseNormal {
+		t.Errorf("Expected the server to echo close 1000, got %v", err)
+	}
+}
+
+// writeRaw sends a single masked frame with an explicit FIN bit.
+func writeRaw(t *testing.T, conn *Conn, opcode int, fin bool, payload string) {
+	t.Helper()
+	head := byte(opcode)
+	if fin {
+		head |= 0x80
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{head, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i := range len(payload) {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	if _, err := conn.conn.Write(frame); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+}